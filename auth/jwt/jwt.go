@@ -0,0 +1,173 @@
+// Package jwt authenticates requests with JSON Web Tokens sent as bearer tokens. A Validator
+// verifies HS256, RS256 and ES256 signatures against a KeySet, which can be loaded from a JWKS, and
+// checks the `exp`, `nbf`, `iss` and `aud` claims. The verified claims are what
+// RequestResponse.User returns.
+//
+// A Validator plugs into a transport in one of three ways: as echotransport AuthenticationMiddleware
+// (EchoMiddleware), as the authenticator of a resttransport.SecurityScheme (Authenticator), or by
+// wrapping any Transport (Transport), where routes can require scopes with RequireScopes:
+//
+//	v, err := jwt.New(&jwt.Config{Keys: keys, Issuer: "https://auth.example.com/", Audience: "widgets"})
+//	...
+//	t := v.Transport(echotransport.New(nil))
+//	t.RegisterAuthenticatedHandler("DELETE", "/widgets/{id}", nil, deleteWidget, jwt.RequireScopes("widgets:write"))
+//
+// Missing or invalid tokens are rejected with 401 Unauthorized, and valid tokens that lack a
+// required scope with 403 Forbidden.
+package jwt
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+)
+
+// ScopedClaims are the claims of a token. Custom claim types typically embed Claims.
+type ScopedClaims interface {
+	gojwt.Claims
+	// Scopes returns the scopes granted to the token.
+	Scopes() []string
+}
+
+// Claims are the registered claims and the OAuth2 scopes of a token. Scopes are read from the
+// space delimited `scope` claim, or the `scp` array used by some identity providers.
+type Claims struct {
+	gojwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+}
+
+// Scopes returns the scopes granted to the token.
+func (c *Claims) Scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	return c.Scp
+}
+
+// Config holds configuration for a Validator. Keys is required, Issuer and Audience are only
+// checked if set. Claims returns the value tokens are decoded into, defaults to *Claims. Now
+// defaults to time.Now.
+type Config struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking `exp` and `nbf`.
+	Leeway time.Duration
+	Claims func() ScopedClaims
+	Now    func() time.Time
+}
+
+// Validator verifies tokens.
+type Validator struct {
+	keys      *KeySet
+	newClaims func() ScopedClaims
+	parser    *gojwt.Parser
+}
+
+// New returns a Validator. Tokens must have an expiry.
+func New(c *Config) (*Validator, error) {
+	if c == nil || c.Keys == nil {
+		return nil, errors.New("a key set is required")
+	}
+	newClaims := c.Claims
+	if newClaims == nil {
+		newClaims = func() ScopedClaims { return &Claims{} }
+	}
+
+	opts := []gojwt.ParserOption{
+		gojwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithLeeway(c.Leeway),
+	}
+	if c.Issuer != "" {
+		opts = append(opts, gojwt.WithIssuer(c.Issuer))
+	}
+	if c.Audience != "" {
+		opts = append(opts, gojwt.WithAudience(c.Audience))
+	}
+	if c.Now != nil {
+		opts = append(opts, gojwt.WithTimeFunc(c.Now))
+	}
+
+	return &Validator{
+		keys:      c.Keys,
+		newClaims: newClaims,
+		parser:    gojwt.NewParser(opts...),
+	}, nil
+}
+
+func (v *Validator) keyFunc(t *gojwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	return v.keys.lookup(kid, t.Method.Alg())
+}
+
+// Verify verifies a token and checks it grants the scopes. It returns a 401 resttransport.Error if
+// the token is invalid, and a 403 if it lacks a scope.
+func (v *Validator) Verify(token string, scopes ...string) (ScopedClaims, error) {
+	claims := v.newClaims()
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, &resttransport.Error{
+			Status: http.StatusUnauthorized,
+			Code:   "invalid_token",
+			Detail: "the access token is invalid",
+			Err:    err,
+		}
+	}
+
+	if missing := missingScopes(claims.Scopes(), scopes); len(missing) > 0 {
+		return nil, &resttransport.Error{
+			Status: http.StatusForbidden,
+			Code:   "insufficient_scope",
+			Detail: fmt.Sprintf("the access token is missing the scopes %s", strings.Join(missing, ", ")),
+		}
+	}
+	return claims, nil
+}
+
+func missingScopes(granted, required []string) []string {
+	has := map[string]bool{}
+	for _, s := range granted {
+		has[s] = true
+	}
+	var missing []string
+	for _, s := range required {
+		if !has[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// challenge returns the RFC 6750 `WWW-Authenticate` challenge for an error from Verify.
+func challenge(err error, scopes []string) string {
+	e, ok := resttransport.AsError(err)
+	if !ok || e.Code == "" {
+		return "Bearer"
+	}
+	c := fmt.Sprintf(`Bearer error="%s"`, e.Code)
+	if e.Code == "insufficient_scope" {
+		c += fmt.Sprintf(`, scope="%s"`, strings.Join(scopes, " "))
+	}
+	return c
+}
+
+// bearerToken returns the token from an `Authorization: Bearer` header.
+func bearerToken(h http.Header) (string, bool) {
+	const prefix = "bearer "
+	auth := h.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+func missingToken() *resttransport.Error {
+	return resttransport.NewError(http.StatusUnauthorized, "a bearer token is required")
+}
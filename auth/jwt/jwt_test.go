@@ -0,0 +1,236 @@
+package jwt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/auth/jwt"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/echotransport"
+)
+
+var now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func sign(t *testing.T, method gojwt.SigningMethod, kid string, key interface{}, claims gojwt.MapClaims) string {
+	tok := gojwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func claims(scope string) gojwt.MapClaims {
+	return gojwt.MapClaims{
+		"sub":   "user-1",
+		"iss":   "https://auth",
+		"aud":   "widgets",
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": scope,
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("secret")
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "oct", "kid": "hmac", "alg": "HS256", "k": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"}
+	]}`, b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()), b64(ecKey.X.Bytes()), b64(ecKey.Y.Bytes()), b64(secret))
+	keys, err := jwt.ParseJWKS([]byte(jwks))
+	require.NoError(t, err)
+
+	v, err := jwt.New(&jwt.Config{
+		Keys:     keys,
+		Issuer:   "https://auth",
+		Audience: "widgets",
+		Now:      func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"RS256": sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, claims("widgets:read widgets:write")),
+		"ES256": sign(t, gojwt.SigningMethodES256, "ec", ecKey, claims("widgets:read widgets:write")),
+		"HS256": sign(t, gojwt.SigningMethodHS256, "hmac", secret, claims("widgets:read widgets:write")),
+		// a single HS256 key doesn't need a key ID
+		"no kid": sign(t, gojwt.SigningMethodHS256, "", secret, claims("widgets:read widgets:write")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := v.Verify(token, "widgets:write")
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", c.(*jwt.Claims).Subject)
+			assert.Equal(t, []string{"widgets:read", "widgets:write"}, c.Scopes())
+		})
+	}
+
+	status := func(token string, scopes ...string) int {
+		_, err := v.Verify(token, scopes...)
+		e, ok := resttransport.AsError(err)
+		require.True(t, ok, "expected an Error, got %v", err)
+		return e.Status
+	}
+
+	expired := claims("")
+	expired["exp"] = now.Add(-time.Minute).Unix()
+	notYet := claims("")
+	notYet["nbf"] = now.Add(time.Minute).Unix()
+	wrongAud := claims("")
+	wrongAud["aud"] = "gadgets"
+	wrongIss := claims("")
+	wrongIss["iss"] = "https://evil"
+	noExp := claims("")
+	delete(noExp, "exp")
+
+	assert.Equal(t, http.StatusForbidden, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, claims("widgets:read")), "widgets:write"))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, expired)))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, notYet)))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, wrongAud)))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, wrongIss)))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodRS256, "rsa", rsaKey, noExp)))
+	// signed with the wrong key, or with a key ID for another algorithm
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodHS256, "hmac", []byte("guess"), claims(""))))
+	assert.Equal(t, http.StatusUnauthorized, status(sign(t, gojwt.SigningMethodHS256, "rsa", secret, claims(""))))
+	assert.Equal(t, http.StatusUnauthorized, status("not.a.token"))
+}
+
+func newValidator(t *testing.T, secret []byte) *jwt.Validator {
+	keys, err := jwt.NewKeySet(jwt.Key{ID: "k", Key: secret})
+	require.NoError(t, err)
+	v, err := jwt.New(&jwt.Config{Keys: keys, Now: func() time.Time { return now }})
+	require.NoError(t, err)
+	return v
+}
+
+func TestTransport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	secret := []byte("secret")
+	e := echo.New()
+	dt := doctransport.New(echotransport.New(&echotransport.Config{Echo: e}))
+	tr := newValidator(t, secret).Transport(dt)
+	require.NoError(tr.RegisterAuthenticatedHandler(http.MethodDelete, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Body(http.StatusOK, r.User().(*jwt.Claims).Subject)
+	}, jwt.RequireScopes("widgets:write")))
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/widgets/1", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("")
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+	assert.Equal("Bearer", rec.Header().Get("WWW-Authenticate"))
+
+	rec = do(sign(t, gojwt.SigningMethodHS256, "k", secret, claims("widgets:read")))
+	assert.Equal(http.StatusForbidden, rec.Code)
+	assert.Equal(`Bearer error="insufficient_scope", scope="widgets:write"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = do(sign(t, gojwt.SigningMethodHS256, "k", secret, claims("widgets:write")))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`"user-1"`, rec.Body.String())
+
+	// the wrapped transports know the route is authenticated
+	s, err := dt.Generate()
+	require.NoError(err)
+	assert.NotEmpty(s.Paths.Paths["/widgets/{id}"].Delete.Security)
+}
+
+func TestTransport_WebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	secret := []byte("secret")
+	e := echo.New()
+	tr := newValidator(t, secret).Transport(echotransport.New(&echotransport.Config{Echo: e}))
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(tr, "/events", func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		return conn.Write(conn.User().(*jwt.Claims).Subject)
+	}, jwt.RequireScopes("events:read")))
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events"
+
+	read := func(token string) (string, error) {
+		header := http.Header{}
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		require.NoError(err)
+		defer conn.Close()
+		var m string
+		err = conn.ReadJSON(&m)
+		return m, err
+	}
+
+	_, err := read("")
+	assert.True(websocket.IsCloseError(err, 4401), "unexpected error %v", err)
+
+	_, err = read(sign(t, gojwt.SigningMethodHS256, "k", secret, claims("events:write")))
+	assert.True(websocket.IsCloseError(err, 4403), "unexpected error %v", err)
+
+	m, err := read(sign(t, gojwt.SigningMethodHS256, "k", secret, claims("events:read")))
+	require.NoError(err)
+	assert.Equal("user-1", m)
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := []byte("secret")
+	e := echo.New()
+	e.GET("/me", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("user").(*jwt.Claims).Subject)
+	}, newValidator(t, secret).EchoMiddleware("user"))
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("invalid")
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+	assert.Equal(`Bearer error="invalid_token"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = do(sign(t, gojwt.SigningMethodHS256, "k", secret, claims("")))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("user-1", rec.Body.String())
+}
@@ -0,0 +1,180 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Key is a verification key. Key holds a []byte for HS256, an *rsa.PublicKey for RS256 or an
+// *ecdsa.PublicKey on P-256 for ES256.
+type Key struct {
+	// ID matches the `kid` header of tokens signed with the key.
+	ID string
+	// Algorithm restricts the key to one signing algorithm, it is inferred from Key if empty.
+	Algorithm string
+	Key       interface{}
+}
+
+func (k Key) algorithm() (string, error) {
+	if k.Algorithm != "" {
+		return k.Algorithm, nil
+	}
+	switch key := k.Key.(type) {
+	case []byte:
+		return "HS256", nil
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", errors.Errorf("key '%s' is not on the P-256 curve", k.ID)
+		}
+		return "ES256", nil
+	}
+	return "", errors.Errorf("unsupported type %T for key '%s'", k.Key, k.ID)
+}
+
+// KeySet holds the keys tokens are verified with.
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet returns a static KeySet.
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	ks := &KeySet{}
+	for _, k := range keys {
+		alg, err := k.algorithm()
+		if err != nil {
+			return nil, err
+		}
+		k.Algorithm = alg
+		ks.keys = append(ks.keys, k)
+	}
+	return ks, nil
+}
+
+// lookup returns the key for a token's `kid` and `alg` headers. Tokens without a key ID are only
+// accepted when a single key matches the algorithm.
+func (ks *KeySet) lookup(kid, alg string) (interface{}, error) {
+	var found []Key
+	for _, k := range ks.keys {
+		if k.Algorithm != alg || (kid != "" && k.ID != kid) {
+			continue
+		}
+		found = append(found, k)
+	}
+	switch len(found) {
+	case 0:
+		return nil, errors.Errorf("no %s key '%s'", alg, kid)
+	case 1:
+		return found[0].Key, nil
+	}
+	return nil, errors.Errorf("token has no key ID and there are %d %s keys", len(found), alg)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// symmetric
+	K string `json:"k"`
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (j jwk) key() (interface{}, error) {
+	switch j.Kty {
+	case "oct":
+		return decodeSegment(j.K)
+	case "RSA":
+		n, err := decodeInt(j.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid modulus")
+		}
+		e, err := decodeInt(j.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported curve '%s'", j.Crv)
+		}
+		x, err := decodeInt(j.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid x coordinate")
+		}
+		y, err := decodeInt(j.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid y coordinate")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, errors.Errorf("unsupported key type '%s'", j.Kty)
+}
+
+// ParseJWKS parses an RFC 7517 JSON Web Key Set. Encryption keys and keys for other algorithms than
+// HS256, RS256 and ES256 are skipped.
+func ParseJWKS(b []byte) (*KeySet, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, errors.Wrap(err, "unable to parse JWKS")
+	}
+
+	var keys []Key
+	for i, j := range set.Keys {
+		if j.Use == "enc" {
+			continue
+		}
+		switch j.Alg {
+		case "", "HS256", "RS256", "ES256":
+		default:
+			continue
+		}
+		key, err := j.key()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse key %d '%s'", i, j.Kid)
+		}
+		keys = append(keys, Key{ID: j.Kid, Algorithm: j.Alg, Key: key})
+	}
+	return NewKeySet(keys...)
+}
+
+// LoadJWKS reads a JSON Web Key Set from a file.
+func LoadJWKS(file string) (*KeySet, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read JWKS %s", file)
+	}
+	return ParseJWKS(b)
+}
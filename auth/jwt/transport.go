@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Scheme returns a bearer SecurityScheme for JWTs, to use with resttransport.Security and
+// Authenticator.
+func Scheme(name string) *resttransport.SecurityScheme {
+	return &resttransport.SecurityScheme{
+		Name:         name,
+		Type:         resttransport.BearerAuth,
+		BearerFormat: "JWT",
+	}
+}
+
+// Authenticator returns a resttransport.Authenticator that verifies tokens and the scopes of the
+// security requirement.
+func (v *Validator) Authenticator() resttransport.Authenticator {
+	return func(ctx context.Context, scheme *resttransport.SecurityScheme, credential string, scopes []string) (interface{}, error) {
+		return v.Verify(credential, scopes...)
+	}
+}
+
+// EchoMiddleware returns echotransport AuthenticationMiddleware that verifies tokens, and requires
+// scopes of all the authenticated routes. The claims are stored in the context under userKey,
+// which must match echotransport.Config.UserContextKey.
+func (v *Validator) EchoMiddleware(userKey string, scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := bearerToken(c.Request().Header)
+			if !ok {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, missingToken().Detail)
+			}
+			claims, err := v.Verify(token, scopes...)
+			if err != nil {
+				e, _ := resttransport.AsError(err)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge(err, scopes))
+				he := echo.NewHTTPError(e.Status, e.Detail)
+				he.Internal = err
+				return he
+			}
+			c.Set(userKey, claims)
+			return next(c)
+		}
+	}
+}
+
+type scopesKey struct{}
+
+// RequireScopes is a route option requiring scopes for an authenticated handler registered on the
+// Transport wrapper.
+func RequireScopes(scopes ...string) resttransport.RouteOption {
+	return resttransport.WithRouteValue(scopesKey{}, scopes)
+}
+
+type jwtTransport struct {
+	v     *Validator
+	inner resttransport.Transport
+}
+
+// Transport wraps any Transport to verify tokens for authenticated handlers. They are still
+// registered on inner as authenticated handlers, so the transports it wraps document and scope them
+// as such, which means inner should not authenticate them itself (for example with
+// AuthenticationMiddleware). The claims are returned from RequestResponse.User.
+func (v *Validator) Transport(inner resttransport.Transport) resttransport.Transport {
+	return &jwtTransport{
+		v:     v,
+		inner: inner,
+	}
+}
+
+func (t *jwtTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterHandler(httpMethod, path, consumes, h, opts...)
+}
+
+func (t *jwtTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	scopes, _ := resttransport.NewRoute(opts...).Value(scopesKey{}).([]string)
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(scopes, h), opts...)
+}
+
+func (t *jwtTransport) wrapHandler(scopes []string, inner resttransport.Handler) resttransport.Handler {
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		token, ok := bearerToken(reqres.RequestHeader())
+		if !ok {
+			reqres.ResponseHeader().Set("WWW-Authenticate", "Bearer")
+			return missingToken()
+		}
+		claims, err := t.v.Verify(token, scopes...)
+		if err != nil {
+			reqres.ResponseHeader().Set("WWW-Authenticate", challenge(err, scopes))
+			return err
+		}
+		return inner(resttransport.WithPrincipal(ctx, claims), &jwtRequestResponse{
+			RequestResponse: reqres,
+			claims:          claims,
+		})
+	}
+}
+
+type jwtRequestResponse struct {
+	resttransport.RequestResponse
+	claims ScopedClaims
+}
+
+// User returns the verified claims.
+func (reqres *jwtRequestResponse) User() interface{} {
+	return reqres.claims
+}
+
+// RegisterWebSocketHandler registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport.
+func (t *jwtTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers the handler on the inner transport as
+// authenticated, verifying the token of the upgrade request. As the connection is already upgraded
+// when the handler runs, a missing or invalid token closes it with code 4401, or 4403 for missing
+// scopes, instead of a WWW-Authenticate challenge.
+func (t *jwtTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	scopes, _ := resttransport.NewRoute(opts...).Value(scopesKey{}).([]string)
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, t.wrapWebSocketHandler(scopes, h), opts...)
+}
+
+func (t *jwtTransport) wrapWebSocketHandler(scopes []string, inner resttransport.WebSocketHandler) resttransport.WebSocketHandler {
+	return func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		token, ok := bearerToken(conn.RequestHeader())
+		if !ok {
+			return missingToken()
+		}
+		claims, err := t.v.Verify(token, scopes...)
+		if err != nil {
+			return err
+		}
+		return inner(resttransport.WithPrincipal(ctx, claims), &jwtWebSocketConn{
+			WebSocketConn: conn,
+			claims:        claims,
+		})
+	}
+}
+
+type jwtWebSocketConn struct {
+	resttransport.WebSocketConn
+	claims ScopedClaims
+}
+
+// User returns the verified claims.
+func (conn *jwtWebSocketConn) User() interface{} {
+	return conn.claims
+}
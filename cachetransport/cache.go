@@ -0,0 +1,336 @@
+// Package cachetransport adds entity tags and conditional requests to the GET and HEAD handlers of
+// a resttransport, and can cache their responses in memory.
+//
+// Responses sent with Body get a strong `ETag` computed from the payload encoded in the media type
+// negotiated from `Accept`, and `If-None-Match` and `If-Modified-Since` (when the handler sets
+// `Last-Modified`, or the response is cached) are answered with 304 Not Modified. Routes registered
+// with the TTL option keep their responses in an LRU cache, keyed by the route, the request path,
+// the negotiated media type and the query and header values selected with VaryQuery and Vary, so
+// the handler only runs again once the TTL expires:
+//
+//	t := cachetransport.New(echotransport.New(nil), &cachetransport.Config{CacheSize: 1000})
+//	t.RegisterHandler("GET", "/widgets", nil, listWidgets, cachetransport.TTL(time.Minute),
+//		cachetransport.VaryQuery("page"), cachetransport.Vary("Accept-Language"))
+//
+// Handlers must not modify a payload after sending it, as cached payloads are sent again as is, along
+// with the response headers the handler set. Responses setting cookies are not cached.
+//
+// The responses of authenticated handlers, and of routes with the resttransport.Security option, are
+// also keyed by the resttransport.PrincipalID of the user, and are not cached for users without
+// one. Other routes don't cache responses to requests with an `Authorization` header. The user is
+// only known once the request is authenticated, so the cache must wrap transports that authenticate
+// in a handler, such as jwt.Transport, rather than be wrapped by them:
+//
+//	t := cachetransport.New(validator.Transport(echotransport.New(nil)), nil)
+package cachetransport
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+	"github.com/paultyng/resttransport/routename"
+)
+
+type optionKey int
+
+const (
+	ttlKey optionKey = iota
+	varyKey
+	varyQueryKey
+)
+
+// TTL caches the responses of a route for d. It has no effect unless Config.CacheSize is set.
+func TTL(d time.Duration) resttransport.RouteOption {
+	return resttransport.WithRouteValue(ttlKey, d)
+}
+
+// Vary adds request headers that select the representation, for example `Accept-Language`. They
+// are part of the cache key and the entity tag, and are listed in the `Vary` response header, which
+// also lists `Accept` when more than one codec is registered.
+func Vary(headers ...string) resttransport.RouteOption {
+	return appendValues(varyKey, headers)
+}
+
+// VaryQuery adds query parameters to the cache key. Other query parameters are ignored when looking
+// up cached responses.
+func VaryQuery(params ...string) resttransport.RouteOption {
+	return appendValues(varyQueryKey, params)
+}
+
+func appendValues(key optionKey, values []string) resttransport.RouteOption {
+	return func(r *resttransport.Route) {
+		existing, _ := r.Value(key).([]string)
+		resttransport.WithRouteValue(key, append(append([]string(nil), existing...), values...))(r)
+	}
+}
+
+// Config holds configuration for the caching Transport. CacheSize is the number of responses kept in
+// memory, 0 disables caching but still adds entity tags. Codecs negotiate the media type responses
+// are tagged and cached in, and must match those of the inner transport; they default to
+// codec.Default().
+type Config struct {
+	CacheSize int
+	Codecs    *codec.Registry
+}
+
+type cacheTransport struct {
+	inner  resttransport.Transport
+	namer  routename.Namer
+	cache  *lru
+	codecs *codec.Registry
+}
+
+// New returns a new instance of a resttransport that adds entity tags and caching.
+func New(inner resttransport.Transport, c *Config) resttransport.Transport {
+	if c == nil {
+		c = &Config{}
+	}
+	codecs := c.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+	t := &cacheTransport{
+		inner:  inner,
+		namer:  routename.New(),
+		codecs: codecs,
+	}
+	if c.CacheSize > 0 {
+		t.cache = newLRU(c.CacheSize)
+	}
+	return t
+}
+
+func (t *cacheTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h, opts = t.wrapHandler(false, httpMethod, path, h, opts)
+	return t.inner.RegisterHandler(httpMethod, path, consumes, h, opts...)
+}
+
+func (t *cacheTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h, opts = t.wrapHandler(true, httpMethod, path, h, opts)
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, h, opts...)
+}
+
+// RegisterWebSocketHandler registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport. WebSocket connections are not cached.
+func (t *cacheTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers the authenticated handler on the inner
+// transport, see RegisterWebSocketHandler.
+func (t *cacheTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// route holds the caching options of a route.
+type route struct {
+	operation string
+	auth      bool
+	codecs    *codec.Registry
+	ttl       time.Duration
+	vary      []string
+	varyQuery []string
+}
+
+// key identifies a cached response: the operation, the path, the negotiated media type, the selected
+// query and header values and the user of authenticated routes. Requests that can't be cached return
+// false.
+func (r *route) key(reqres resttransport.RequestResponse) (string, bool) {
+	enc, ok := r.codecs.Negotiate(reqres.RequestHeader().Get("Accept"))
+	if !ok {
+		return "", false
+	}
+	u := reqres.URL()
+	parts := []string{r.operation, u.Path, enc.ContentType()}
+	q := u.Query()
+	for _, name := range r.varyQuery {
+		parts = append(parts, name+"="+strings.Join(q[name], ","))
+	}
+	for _, name := range r.vary {
+		parts = append(parts, name+":"+strings.Join(reqres.RequestHeader().Values(name), ","))
+	}
+	if r.auth {
+		id := resttransport.PrincipalID(reqres.User())
+		if id == "" {
+			return "", false
+		}
+		parts = append(parts, "user:"+id)
+	} else if reqres.RequestHeader().Get("Authorization") != "" {
+		// the credentials may select the response, see RFC 9111 section 3.5
+		return "", false
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// varyHeader returns the `Vary` response header, or "" if the representation doesn't vary.
+func (r *route) varyHeader() string {
+	vary := r.vary
+	if len(r.codecs.ContentTypes()) > 1 {
+		vary = []string{"Accept"}
+		for _, name := range r.vary {
+			if http.CanonicalHeaderKey(name) != "Accept" {
+				vary = append(vary, name)
+			}
+		}
+	}
+	return strings.Join(vary, ", ")
+}
+
+// wrapHandler adds entity tags to GET and HEAD handlers, declaring the ETag header and 304 response
+// to the inner transport.
+func (t *cacheTransport) wrapHandler(auth bool, httpMethod, path string, inner resttransport.Handler, opts []resttransport.RouteOption) (resttransport.Handler, []resttransport.RouteOption) {
+	if httpMethod != http.MethodGet && httpMethod != http.MethodHead {
+		return inner, opts
+	}
+
+	rt := resttransport.NewRoute(opts...)
+	r := &route{
+		operation: t.namer.Name(httpMethod, path),
+		auth:      auth || len(rt.Security) > 0,
+		codecs:    t.codecs,
+	}
+	r.ttl, _ = rt.Value(ttlKey).(time.Duration)
+	r.vary, _ = rt.Value(varyKey).([]string)
+	r.varyQuery, _ = rt.Value(varyQueryKey).([]string)
+	sort.Strings(r.varyQuery)
+	cache := t.cache
+	if r.ttl <= 0 {
+		cache = nil
+	}
+
+	opts = append(opts,
+		resttransport.Response(http.StatusNotModified, nil),
+		resttransport.ResponseHeader(http.StatusOK, "ETag", "Entity tag of the response, for If-None-Match"),
+		resttransport.ResponseHeader(http.StatusNotModified, "ETag", "Entity tag of the response"),
+	)
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		rr := &cacheRequestResponse{
+			RequestResponse: reqres,
+			route:           r,
+			header:          reqres.ResponseHeader().Clone(),
+		}
+		if cache != nil {
+			if key, ok := r.key(reqres); ok {
+				if e, ok := cache.get(key); ok {
+					return send(reqres, r, e)
+				}
+				rr.cache, rr.key = cache, key
+			}
+		}
+		return inner(ctx, rr)
+	}, opts
+}
+
+type cacheRequestResponse struct {
+	resttransport.RequestResponse
+	route *route
+	cache *lru
+	key   string
+	// header is the response header before the handler ran
+	header http.Header
+}
+
+// Body tags successful responses, caching them if the route has a TTL.
+func (reqres *cacheRequestResponse) Body(status int, body interface{}) error {
+	if status != http.StatusOK {
+		return reqres.RequestResponse.Body(status, body)
+	}
+
+	enc, ok := reqres.route.codecs.Negotiate(reqres.RequestHeader().Get("Accept"))
+	if !ok {
+		// the inner transport can't encode it either
+		return reqres.RequestResponse.Body(status, body)
+	}
+	b := []byte(enc.ContentType() + "\x00")
+	buf := bytes.NewBuffer(b)
+	if err := enc.Encode(buf, body); err != nil {
+		return errors.Wrap(err, "unable to encode body for entity tag")
+	}
+	b = buf.Bytes()
+	// representations selected by request headers need their own tags
+	for _, name := range reqres.route.vary {
+		b = append(b, 0)
+		b = append(b, strings.Join(reqres.RequestHeader().Values(name), ",")...)
+	}
+
+	e := &entry{
+		key:    reqres.key,
+		status: status,
+		body:   body,
+		header: reqres.handlerHeader(),
+		etag:   resttransport.ETag(b),
+	}
+	if lm, err := http.ParseTime(reqres.ResponseHeader().Get("Last-Modified")); err == nil {
+		e.lastModified = lm
+	}
+	if reqres.cache != nil && len(e.header.Values("Set-Cookie")) == 0 {
+		now := reqres.cache.now()
+		if e.lastModified.IsZero() {
+			e.lastModified = now
+		}
+		e.expires = now.Add(reqres.route.ttl)
+		reqres.cache.add(e)
+	}
+	return send(reqres.RequestResponse, reqres.route, e)
+}
+
+// handlerHeader returns the response headers set by the handler, leaving out those set by the
+// transports it is wrapped in and those send sets.
+func (reqres *cacheRequestResponse) handlerHeader() http.Header {
+	h := http.Header{}
+	for name, values := range reqres.ResponseHeader() {
+		switch name {
+		case "Etag", "Last-Modified", "Vary":
+			continue
+		}
+		if !reflect.DeepEqual(reqres.header[name], values) {
+			h[name] = append([]string(nil), values...)
+		}
+	}
+	return h
+}
+
+// send responds with an entry, or 304 Not Modified if the request's preconditions show the client
+// has it.
+func send(reqres resttransport.RequestResponse, r *route, e *entry) error {
+	h := reqres.ResponseHeader()
+	for name, values := range e.header {
+		h[name] = append([]string(nil), values...)
+	}
+	h.Set("ETag", e.etag)
+	if !e.lastModified.IsZero() {
+		h.Set("Last-Modified", e.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if vary := r.varyHeader(); vary != "" {
+		h.Set("Vary", vary)
+	}
+	if notModified(reqres.RequestHeader(), e) {
+		return reqres.NoBody(http.StatusNotModified)
+	}
+	return reqres.Body(e.status, e.body)
+}
+
+// notModified evaluates `If-None-Match`, or `If-Modified-Since` if it is absent, per RFC 9110.
+func notModified(h http.Header, e *entry) bool {
+	if inm := h.Get("If-None-Match"); inm != "" {
+		return resttransport.ETagMatches(inm, e.etag)
+	}
+	if e.lastModified.IsZero() {
+		return false
+	}
+	ims, err := http.ParseTime(h.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !e.lastModified.Truncate(time.Second).After(ims)
+}
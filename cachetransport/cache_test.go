@@ -0,0 +1,311 @@
+package cachetransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/auth/jwt"
+	"github.com/paultyng/resttransport/codec"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/echotransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := doctransport.New(tt)
+	ct := New(dt, nil)
+
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	name := "a"
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		r.ResponseHeader().Set("Last-Modified", modified.Format(http.TimeFormat))
+		return r.Body(http.StatusOK, map[string]string{"name": name})
+	}))
+
+	get := func(h http.Header) *testtransport.Response {
+		resp, err := tt.Do(http.MethodGet, "/widgets/{id}", &testtransport.Request{Path: map[string]string{"id": "1"}, Header: h})
+		require.NoError(err)
+		return resp
+	}
+
+	resp := get(nil)
+	assert.Equal(http.StatusOK, resp.Status)
+	tag := resp.Header.Get("ETag")
+	assert.Regexp(`^"[0-9a-f]{32}"$`, tag)
+
+	assert.Equal(http.StatusNotModified, get(http.Header{"If-None-Match": {`"other", ` + tag}}).Status)
+	assert.Equal(http.StatusNotModified, get(http.Header{"If-None-Match": {"W/" + tag}}).Status)
+	assert.Equal(http.StatusNotModified, get(http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}).Status)
+	assert.Equal(http.StatusOK, get(http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}).Status)
+	// If-None-Match takes precedence
+	assert.Equal(http.StatusOK, get(http.Header{
+		"If-None-Match":     {`"other"`},
+		"If-Modified-Since": {modified.Format(http.TimeFormat)},
+	}).Status)
+
+	name = "b"
+	resp = get(http.Header{"If-None-Match": {tag}})
+	assert.Equal(http.StatusOK, resp.Status)
+	assert.NotEqual(tag, resp.Header.Get("ETag"))
+
+	s, err := dt.Generate()
+	require.NoError(err)
+	op := s.Paths.Paths["/widgets/{id}"].Get
+	require.Contains(op.Responses.StatusCodeResponses, http.StatusNotModified)
+	assert.Contains(op.Responses.StatusCodeResponses[http.StatusNotModified].Headers, "ETag")
+	// the observed response keeps the declared header
+	assert.NotNil(op.Responses.StatusCodeResponses[http.StatusOK].Schema)
+	assert.Contains(op.Responses.StatusCodeResponses[http.StatusOK].Headers, "ETag")
+}
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	ct := New(tt, &Config{CacheSize: 2}).(*cacheTransport)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ct.cache.now = func() time.Time { return now }
+
+	calls := 0
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		r.ResponseHeader().Set("X-Total-Count", strconv.Itoa(calls))
+		return r.Body(http.StatusOK, calls)
+	}, TTL(time.Minute), VaryQuery("page"), Vary("Accept")))
+	require.NoError(ct.RegisterHandler(http.MethodPost, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Body(http.StatusOK, nil)
+	}, TTL(time.Minute)))
+
+	get := func(page, accept string) *testtransport.Response {
+		resp, err := tt.Do(http.MethodGet, "/widgets", &testtransport.Request{
+			Query:  url.Values{"page": {page}, "sort": {page}},
+			Header: http.Header{"Accept": {accept}},
+		})
+		require.NoError(err)
+		return resp
+	}
+
+	first := get("1", "application/json")
+	assert.Equal(1, first.Body)
+	assert.Equal("Accept", first.Header.Get("Vary"))
+	assert.Equal(now.Format(http.TimeFormat), first.Header.Get("Last-Modified"))
+	cached := get("1", "application/json")
+	assert.Equal(1, cached.Body)
+	assert.Equal("1", cached.Header.Get("X-Total-Count"))
+	assert.Equal(2, get("2", "application/json").Body)
+	assert.Equal(3, get("1", "application/xml").Body)
+	assert.Equal(3, calls)
+
+	// the least recently used response was evicted
+	assert.Equal(4, get("1", "application/json").Body)
+
+	now = now.Add(time.Minute)
+	assert.Equal(5, get("1", "application/json").Body)
+
+	resp, err := tt.Do(http.MethodPost, "/widgets", nil)
+	require.NoError(err)
+	assert.Empty(resp.Header.Get("ETag"))
+}
+
+func TestCache_MediaTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	e := echo.New()
+	ct := New(echotransport.New(&echotransport.Config{Echo: e}), &Config{CacheSize: 10})
+	type widget struct {
+		Name string `json:"name" xml:"name"`
+	}
+	calls := 0
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/widget", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		return r.Body(http.StatusOK, widget{Name: "a"})
+	}, TTL(time.Minute)))
+
+	get := func(accept, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/widget", nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("If-None-Match", inm)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	j := get("application/json", "")
+	assert.Equal("application/json", j.Header().Get("Content-Type"))
+	assert.Equal("Accept", j.Header().Get("Vary"))
+	x := get("application/xml", "")
+	assert.Equal("application/xml", x.Header().Get("Content-Type"))
+	assert.Equal("Accept", x.Header().Get("Vary"))
+	assert.Contains(x.Body.String(), "<name>a</name>")
+	assert.NotEqual(j.Header().Get("ETag"), x.Header().Get("ETag"))
+	assert.Equal(2, calls)
+
+	// each media type is cached and validated on its own
+	assert.JSONEq(`{"name":"a"}`, get("application/json", "").Body.String())
+	assert.Equal(http.StatusNotModified, get("application/xml", x.Header().Get("ETag")).Code)
+	assert.Equal(http.StatusOK, get("application/json", x.Header().Get("ETag")).Code)
+	assert.Equal(2, calls)
+
+	// a single codec doesn't vary
+	e = echo.New()
+	codecs := codec.NewRegistry(codec.JSON)
+	ct = New(echotransport.New(&echotransport.Config{Echo: e, Codecs: codecs}), &Config{Codecs: codecs})
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/widget", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Body(http.StatusOK, widget{Name: "a"})
+	}))
+	j = get("application/json", "")
+	assert.NotEmpty(j.Header().Get("ETag"))
+	assert.Empty(j.Header().Get("Vary"))
+}
+
+func TestCache_Authenticated(t *testing.T) {
+	secret := []byte("secret")
+	keys, err := jwt.NewKeySet(jwt.Key{ID: "k", Key: secret})
+	require.NoError(t, err)
+	v, err := jwt.New(&jwt.Config{Keys: keys})
+	require.NoError(t, err)
+	token := func(sub string, iat time.Time) string {
+		tok := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+			"sub": sub,
+			"iat": iat.Unix(),
+			"exp": iat.Add(time.Hour).Unix(),
+		})
+		tok.Header["kid"] = "k"
+		s, err := tok.SignedString(secret)
+		require.NoError(t, err)
+		return s
+	}
+
+	for _, c := range []struct {
+		name   string
+		wrap   func(resttransport.Transport) resttransport.Transport
+		cached bool
+	}{
+		{"outside", func(inner resttransport.Transport) resttransport.Transport {
+			return New(v.Transport(inner), &Config{CacheSize: 10})
+		}, true},
+		// the cache is consulted before the token is verified, so it must not be used
+		{"inside", func(inner resttransport.Transport) resttransport.Transport {
+			return v.Transport(New(inner, &Config{CacheSize: 10}))
+		}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			e := echo.New()
+			calls := 0
+			require.NoError(c.wrap(echotransport.New(&echotransport.Config{Echo: e})).RegisterAuthenticatedHandler(http.MethodGet, "/me", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+				calls++
+				return r.Body(http.StatusOK, r.User().(*jwt.Claims).Subject)
+			}, TTL(time.Minute)))
+
+			get := func(token string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/me", nil)
+				if token != "" {
+					req.Header.Set("Authorization", "Bearer "+token)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				return rec
+			}
+
+			now := time.Now()
+			assert.JSONEq(`"alice"`, get(token("alice", now)).Body.String())
+			assert.JSONEq(`"bob"`, get(token("bob", now)).Body.String())
+			assert.Equal(http.StatusUnauthorized, get("").Code)
+			assert.Equal(http.StatusUnauthorized, get("invalid").Code)
+
+			// a refreshed token is the same user
+			assert.JSONEq(`"alice"`, get(token("alice", now.Add(-time.Minute))).Body.String())
+			if c.cached {
+				assert.Equal(2, calls)
+			} else {
+				assert.Equal(3, calls)
+			}
+		})
+	}
+}
+
+func TestCache_Security(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	ct := New(tt, &Config{CacheSize: 10})
+	calls := 0
+	scheme := &resttransport.SecurityScheme{Name: "key", Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInHeader, ParamName: "X-Key"}
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/me", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		return r.Body(http.StatusOK, calls)
+	}, TTL(time.Minute), resttransport.Security(scheme)))
+	require.NoError(ct.RegisterHandler(http.MethodGet, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		r.ResponseHeader().Add("Set-Cookie", "seen=1")
+		return r.Body(http.StatusOK, calls)
+	}, TTL(time.Minute)))
+
+	get := func(path string, user interface{}) interface{} {
+		resp, err := tt.Do(http.MethodGet, path, &testtransport.Request{User: user})
+		require.NoError(err)
+		return resp.Body
+	}
+
+	assert.Equal(1, get("/me", "alice"))
+	assert.Equal(1, get("/me", "alice"))
+	assert.Equal(2, get("/me", "bob"))
+
+	// users without an id aren't cached
+	assert.Equal(3, get("/me", struct{}{}))
+	assert.Equal(4, get("/me", struct{}{}))
+
+	// nor are responses setting cookies
+	assert.Equal(5, get("/widgets", nil))
+	assert.Equal(6, get("/widgets", nil))
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	ct := New(tt, nil)
+
+	reply := func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		var m string
+		if err := conn.Read(&m); err != nil {
+			return err
+		}
+		return conn.Write(m)
+	}
+	require.NoError(resttransport.RegisterWebSocketHandler(ct, "/rooms", reply))
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(ct, "/private", reply))
+
+	for path, user := range map[string]interface{}{"/rooms": nil, "/private": "alice"} {
+		client, err := tt.WebSocket(path, &testtransport.Request{User: user})
+		require.NoError(err)
+		require.NoError(client.Send("hi"))
+		var m string
+		require.NoError(client.Receive(&m))
+		assert.Equal("hi", m)
+		code, _, _ := client.Wait()
+		assert.Equal(resttransport.CloseNormal, code)
+	}
+}
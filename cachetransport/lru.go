@@ -0,0 +1,72 @@
+package cachetransport
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// entry is a response sent with Body.
+type entry struct {
+	key          string
+	status       int
+	body         interface{}
+	header       http.Header
+	etag         string
+	lastModified time.Time
+	expires      time.Time
+}
+
+// lru holds the most recently used entries, up to size.
+type lru struct {
+	sync.Mutex
+	size  int
+	now   func() time.Time
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		now:   time.Now,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *lru) get(key string) (*entry, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e, true
+}
+
+func (c *lru) add(e *entry) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+	c.items[e.key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
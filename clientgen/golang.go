@@ -0,0 +1,466 @@
+// Package clientgen generates typed API clients from the Swagger specs produced by doctransport.
+// Operations are named by their operation IDs, which doctransport assigns with routename.Namer, so
+// `GET /foos/{id}` becomes `GetFoo`. To generate a client from live registrations, use the spec
+// from SwaggerTransport.Generate.
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// GoConfig holds configuration for Go.
+type GoConfig struct {
+	// Package is the name of the generated package, defaults to `client`.
+	Package string
+}
+
+// Go generates the source of a Go client package for the spec. The package has a struct for each
+// definition, path and query parameter structs for each operation, and a Client with one method per
+// operation that sends a bearer token for authenticated operations. Operations that upload files
+// are not supported and are skipped.
+func Go(s *spec.Swagger, c *GoConfig) ([]byte, error) {
+	if c == nil {
+		c = &GoConfig{}
+	}
+	pkg := c.Package
+	if pkg == "" {
+		pkg = "client"
+	}
+
+	g := &goGenerator{imports: map[string]bool{
+		"bytes":         true,
+		"context":       true,
+		"encoding/json": true,
+		"fmt":           true,
+		"io":            true,
+		"net/http":      true,
+		"net/url":       true,
+		"strings":       true,
+	}}
+
+	for _, name := range sortedDefinitions(s) {
+		if err := g.definition(name, s.Definitions[name]); err != nil {
+			return nil, errors.Wrapf(err, "unable to generate definition %s", name)
+		}
+	}
+	for _, op := range operations(s) {
+		if err := g.operation(op); err != nil {
+			return nil, errors.Wrapf(err, "unable to generate %s %s", op.Method, op.Path)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by restgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "// Package %s is a client for the API.\n", pkg)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for i := range g.imports {
+		imports = append(imports, i)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, i := range imports {
+		fmt.Fprintf(&out, "\t%q\n", i)
+	}
+	out.WriteString(")\n\n")
+	out.WriteString(goClientSource)
+	out.Write(g.types.Bytes())
+	out.Write(g.methods.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to format generated source")
+	}
+	return src, nil
+}
+
+type goGenerator struct {
+	imports map[string]bool
+	types   bytes.Buffer
+	methods bytes.Buffer
+}
+
+// goType returns the Go type for a schema.
+// nolint: gocyclo
+func (g *goGenerator) goType(s *spec.Schema) (string, error) {
+	if s == nil {
+		return "json.RawMessage", nil
+	}
+	if s.Ref.String() != "" {
+		return exportedName(refName(s.Ref)), nil
+	}
+
+	t := ""
+	if len(s.Type) > 0 {
+		t = s.Type[0]
+	}
+	switch t {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "binary", "byte":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "file":
+		return "[]byte", nil
+	case "array":
+		if s.Items == nil || s.Items.Schema == nil {
+			return "[]json.RawMessage", nil
+		}
+		items, err := g.goType(s.Items.Schema)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + items, nil
+	case "object":
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			values, err := g.goType(s.AdditionalProperties.Schema)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + values, nil
+		}
+		if len(s.Properties) > 0 {
+			var b bytes.Buffer
+			if err := g.fields(&b, *s); err != nil {
+				return "", err
+			}
+			return "struct {\n" + b.String() + "}", nil
+		}
+		return "map[string]json.RawMessage", nil
+	}
+	return "json.RawMessage", nil
+}
+
+// optional returns the type used for a field that may be absent.
+func optional(goType string) string {
+	if strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") || goType == "json.RawMessage" {
+		return goType
+	}
+	return "*" + goType
+}
+
+func (g *goGenerator) fields(b *bytes.Buffer, s spec.Schema) error {
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	for _, name := range sortedProperties(s) {
+		prop := s.Properties[name]
+		t, err := g.goType(&prop)
+		if err != nil {
+			return errors.Wrapf(err, "unable to map property %s", name)
+		}
+		tag := name
+		if !required[name] || isNullable(prop) {
+			t = optional(t)
+			tag += ",omitempty"
+		}
+		if prop.Description != "" {
+			fmt.Fprintf(b, "\t// %s\n", prop.Description)
+		}
+		fmt.Fprintf(b, "\t%s %s `json:%q`\n", exportedName(name), t, tag)
+	}
+	return nil
+}
+
+func (g *goGenerator) definition(name string, s spec.Schema) error {
+	goName := exportedName(name)
+	if s.Description != "" {
+		fmt.Fprintf(&g.types, "// %s %s\n", goName, s.Description)
+	} else {
+		fmt.Fprintf(&g.types, "// %s is the %s definition.\n", goName, name)
+	}
+
+	if !s.Type.Contains("object") || len(s.Properties) == 0 {
+		t, err := g.goType(&s)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.types, "type %s %s\n\n", goName, t)
+		return nil
+	}
+
+	fmt.Fprintf(&g.types, "type %s struct {\n", goName)
+	if err := g.fields(&g.types, s); err != nil {
+		return err
+	}
+	g.types.WriteString("}\n\n")
+	return nil
+}
+
+func (g *goGenerator) paramType(p spec.Parameter) (string, error) {
+	s := &spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{p.Type}, Format: p.Format}}
+	if p.Items != nil {
+		s.Items = &spec.SchemaOrArray{Schema: &spec.Schema{
+			SchemaProps: spec.SchemaProps{Type: []string{p.Items.Type}, Format: p.Items.Format},
+		}}
+	}
+	return g.goType(s)
+}
+
+// paramStruct writes a struct for the path or query parameters of an operation.
+func (g *goGenerator) paramStruct(name, in string, params []spec.Parameter) error {
+	fmt.Fprintf(&g.types, "// %s holds the %s parameters of %s.\n", name+exportedName(in), in, name)
+	fmt.Fprintf(&g.types, "type %s%s struct {\n", name, exportedName(in))
+	for _, p := range params {
+		t, err := g.paramType(p)
+		if err != nil {
+			return errors.Wrapf(err, "unable to map parameter %s", p.Name)
+		}
+		if !p.Required && in != "path" {
+			t = optional(t)
+		}
+		if p.Description != "" {
+			fmt.Fprintf(&g.types, "\t// %s\n", p.Description)
+		}
+		fmt.Fprintf(&g.types, "\t%s %s\n", exportedName(p.Name), t)
+	}
+	g.types.WriteString("}\n\n")
+	return nil
+}
+
+// nolint: gocyclo
+func (g *goGenerator) operation(op operation) error {
+	name := exportedName(op.ID)
+	if op.ID == "" {
+		return errors.New("operation has no ID")
+	}
+	if op.hasFiles() {
+		fmt.Fprintf(&g.methods, "// %s (%s %s) is not generated, file uploads are not supported.\n\n", name, op.Method, op.Path)
+		return nil
+	}
+
+	pathParams, queryParams, body := op.params("path"), op.params("query"), op.body()
+	if len(pathParams) > 0 {
+		if err := g.paramStruct(name, "path", pathParams); err != nil {
+			return err
+		}
+	}
+	if len(queryParams) > 0 {
+		if err := g.paramStruct(name, "query", queryParams); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"ctx context.Context"}
+	if len(pathParams) > 0 {
+		args = append(args, fmt.Sprintf("path %sPath", name))
+	}
+	if len(queryParams) > 0 {
+		args = append(args, fmt.Sprintf("query %sQuery", name))
+	}
+	if body != nil {
+		t, err := g.goType(body.Schema)
+		if err != nil {
+			return errors.Wrap(err, "unable to map body")
+		}
+		args = append(args, "body "+t)
+	}
+
+	_, success := op.success()
+	result := ""
+	if success != nil && success.Schema != nil {
+		t, err := g.goType(success.Schema)
+		if err != nil {
+			return errors.Wrap(err, "unable to map response")
+		}
+		result = t
+	}
+
+	w := &g.methods
+	doc := op.Summary
+	if doc == "" {
+		doc = op.Description
+	}
+	if doc != "" {
+		fmt.Fprintf(w, "// %s %s\n", name, doc)
+	} else {
+		fmt.Fprintf(w, "// %s calls %s %s.\n", name, op.Method, op.Path)
+	}
+	if op.Deprecated {
+		fmt.Fprintf(w, "//\n// Deprecated: the operation is deprecated.\n")
+	}
+	returns := "error"
+	if result != "" {
+		returns = fmt.Sprintf("(%s, error)", result)
+	}
+	fmt.Fprintf(w, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), returns)
+
+	zero := ""
+	if result != "" {
+		fmt.Fprintf(w, "\tvar result %s\n", result)
+		zero = "result, "
+	}
+
+	// path
+	fmt.Fprintf(w, "\tp := %q\n", op.Path)
+	for _, p := range pathParams {
+		fmt.Fprintf(w, "\tp = strings.Replace(p, %q, url.PathEscape(fmt.Sprint(path.%s)), 1)\n", "{"+p.Name+"}", exportedName(p.Name))
+	}
+
+	// query
+	fmt.Fprintf(w, "\tq := url.Values{}\n")
+	for _, p := range queryParams {
+		field := "query." + exportedName(p.Name)
+		t, err := g.paramType(p)
+		if err != nil {
+			return err
+		}
+		isSlice := strings.HasPrefix(t, "[]")
+		switch {
+		case isSlice:
+			fmt.Fprintf(w, "\tfor _, v := range %s {\n\t\tq.Add(%q, fmt.Sprint(v))\n\t}\n", field, p.Name)
+		case !p.Required:
+			fmt.Fprintf(w, "\tif %s != nil {\n\t\tq.Set(%q, fmt.Sprint(*%s))\n\t}\n", field, p.Name, field)
+		default:
+			fmt.Fprintf(w, "\tq.Set(%q, fmt.Sprint(%s))\n", p.Name, field)
+		}
+	}
+
+	bodyArg := "nil"
+	if body != nil {
+		bodyArg = "body"
+	}
+	out := "nil"
+	if result != "" {
+		out = "&result"
+	}
+	fmt.Fprintf(w, "\terr := c.do(ctx, %q, p, q, %s, %s, %t)\n", op.Method, bodyArg, out, op.authenticated())
+	fmt.Fprintf(w, "\treturn %serr\n", zero)
+	w.WriteString("}\n\n")
+	return nil
+}
+
+// goClientSource is the fixed part of the generated package.
+const goClientSource = `// Client calls the API.
+type Client struct {
+	// BaseURL is the URL the operation paths are relative to, for example https://api.example.com/v1.
+	BaseURL string
+	// HTTPClient sends requests, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Token is sent as a bearer token to operations that require authentication.
+	Token string
+}
+
+// NewClient returns a Client for the API at baseURL.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		Token:   token,
+	}
+}
+
+// ResponseFieldError describes a problem with a single request field.
+type ResponseFieldError struct {
+	Field  string ` + "`json:\"field\"`" + `
+	In     string ` + "`json:\"in,omitempty\"`" + `
+	Detail string ` + "`json:\"detail\"`" + `
+}
+
+// ResponseError is returned for responses that are not successful. The API renders errors as RFC
+// 9457 problem details, which are decoded when present.
+type ResponseError struct {
+	StatusCode int                  ` + "`json:\"-\"`" + `
+	Title      string               ` + "`json:\"title\"`" + `
+	Detail     string               ` + "`json:\"detail\"`" + `
+	Code       string               ` + "`json:\"code\"`" + `
+	Errors     []ResponseFieldError ` + "`json:\"errors\"`" + `
+	// Body is the raw response body.
+	Body []byte ` + "`json:\"-\"`" + `
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}, auth bool) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to marshal request body: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth && c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &ResponseError{StatusCode: resp.StatusCode, Body: b}
+		_ = json.Unmarshal(b, e)
+		return e
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = b
+		return nil
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("unable to unmarshal response body: %w", err)
+	}
+	return nil
+}
+
+`
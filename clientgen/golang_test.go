@@ -0,0 +1,118 @@
+package clientgen
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type Widget struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Color     *string           `json:"color"`
+	Tags      []string          `json:"tags"`
+	Labels    map[string]string `json:"labels"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type widgetPath struct {
+	ID string `path:"id"`
+}
+
+type widgetsQuery struct {
+	Page   int     `query:"page"`
+	Filter *string `query:"filter"`
+}
+
+func testSpec(t *testing.T) *spec.Swagger {
+	dt := doctransport.New(testtransport.New())
+	require.NoError(t, resttransport.Register(dt, http.MethodGet, "/widgets", nil,
+		func(ctx context.Context, _ struct{}, q widgetsQuery, _ struct{}) ([]Widget, error) {
+			return nil, nil
+		}))
+	require.NoError(t, resttransport.RegisterAuthenticated(dt, http.MethodPut, "/widgets/{id}", nil,
+		func(ctx context.Context, p widgetPath, _ struct{}, w Widget) (Widget, error) {
+			return w, nil
+		}))
+	require.NoError(t, resttransport.RegisterAuthenticated(dt, http.MethodDelete, "/widgets/{id}", nil,
+		func(ctx context.Context, p widgetPath, _ struct{}, _ struct{}) (struct{}, error) {
+			return struct{}{}, nil
+		}))
+	s, err := dt.Generate()
+	require.NoError(t, err)
+	return s
+}
+
+func TestGo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src, err := Go(testSpec(t), &GoConfig{Package: "widgets"})
+	require.NoError(err)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "client.go", src, parser.ParseComments)
+	require.NoError(err, string(src))
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("widgets", fset, []*ast.File{f}, nil)
+	require.NoError(err, string(src))
+
+	signature := func(name string) string {
+		client := pkg.Scope().Lookup("Client").Type().(*types.Named)
+		for i := 0; i < client.NumMethods(); i++ {
+			if m := client.Method(i); m.Name() == name {
+				return types.TypeString(m.Type(), types.RelativeTo(pkg))
+			}
+		}
+		return ""
+	}
+	assert.Equal("func(ctx context.Context, query GetWidgetsQuery) ([]Widget, error)", signature("GetWidgets"))
+	assert.Equal("func(ctx context.Context, path UpdateWidgetPath, body Widget) (Widget, error)", signature("UpdateWidget"))
+	assert.Equal("func(ctx context.Context, path DeleteWidgetPath) error", signature("DeleteWidget"))
+
+	widget := pkg.Scope().Lookup("Widget").Type().Underlying().(*types.Struct)
+	fields := map[string]string{}
+	for i := 0; i < widget.NumFields(); i++ {
+		fields[widget.Field(i).Name()] = types.TypeString(widget.Field(i).Type(), types.RelativeTo(pkg))
+	}
+	assert.Equal(map[string]string{
+		"ID":        "string",
+		"Name":      "string",
+		"Color":     "*string",
+		"Tags":      "[]string",
+		"Labels":    "map[string]string",
+		"CreatedAt": "time.Time",
+	}, fields)
+
+	query := pkg.Scope().Lookup("GetWidgetsQuery").Type().Underlying().(*types.Struct)
+	assert.Equal("*string", query.Field(1).Type().String())
+}
+
+func TestExportedName(t *testing.T) {
+	for in, expected := range map[string]string{
+		"getAccountSearchTokens": "GetAccountSearchTokens",
+		"dry_run":                "DryRun",
+		"id":                     "ID",
+		"accountId":              "AccountID",
+		"accountID":              "AccountID",
+		"HTTPServer":             "HTTPServer",
+		"2fa":                    "X2fa",
+	} {
+		assert.Equal(t, expected, exportedName(in), in)
+	}
+}
@@ -0,0 +1,217 @@
+package clientgen
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/paultyng/resttransport/doctransport/openapi"
+)
+
+var initialisms = map[string]bool{
+	"API":  true,
+	"HTML": true,
+	"HTTP": true,
+	"ID":   true,
+	"JSON": true,
+	"JWT":  true,
+	"URI":  true,
+	"URL":  true,
+	"UUID": true,
+	"XML":  true,
+}
+
+// words splits an identifier like `search-tokens`, `dry_run` or `accountID` into words.
+func words(s string) []string {
+	var (
+		result []string
+		cur    []rune
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			result = append(result, string(cur))
+			cur = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(cur) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return result
+}
+
+// exportedName converts a name to an exported Go identifier, for example `accountId` to `AccountID`.
+func exportedName(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		upper := strings.ToUpper(w)
+		if initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + strings.ToLower(w[1:]))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+// camelName converts a name to lower camel case, for example `AccountID` to `accountId`.
+func camelName(s string) string {
+	var b strings.Builder
+	for i, w := range words(s) {
+		w = strings.ToLower(w)
+		if i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		b.WriteString(w)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "x" + name
+	}
+	return name
+}
+
+// refName returns the definition name of a `#/definitions/Name` reference.
+func refName(ref spec.Ref) string {
+	s := ref.String()
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// operation is a spec operation with its location.
+type operation struct {
+	Method string
+	Path   string
+	*spec.Operation
+}
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH"}
+
+// operations returns the operations of a spec sorted by path and method.
+func operations(s *spec.Swagger) []operation {
+	var ops []operation
+	if s.Paths == nil {
+		return ops
+	}
+	paths := make([]string, 0, len(s.Paths.Paths))
+	for p := range s.Paths.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		pi := s.Paths.Paths[p]
+		for _, m := range methods {
+			var op *spec.Operation
+			switch m {
+			case "GET":
+				op = pi.Get
+			case "PUT":
+				op = pi.Put
+			case "POST":
+				op = pi.Post
+			case "DELETE":
+				op = pi.Delete
+			case "OPTIONS":
+				op = pi.Options
+			case "HEAD":
+				op = pi.Head
+			case "PATCH":
+				op = pi.Patch
+			}
+			if op != nil {
+				ops = append(ops, operation{Method: m, Path: p, Operation: op})
+			}
+		}
+	}
+	return ops
+}
+
+func (op operation) params(in string) []spec.Parameter {
+	var params []spec.Parameter
+	for _, p := range op.Parameters {
+		if p.In == in {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+func (op operation) body() *spec.Parameter {
+	for i, p := range op.Parameters {
+		if p.In == "body" && p.Type != "file" {
+			return &op.Parameters[i]
+		}
+	}
+	return nil
+}
+
+func (op operation) hasFiles() bool {
+	for _, p := range op.Parameters {
+		if p.Type == "file" {
+			return true
+		}
+	}
+	return false
+}
+
+func (op operation) authenticated() bool {
+	return len(op.Security) > 0
+}
+
+// success returns the lowest 2xx status and its response.
+func (op operation) success() (int, *spec.Response) {
+	if op.Responses == nil {
+		return 0, nil
+	}
+	best := 0
+	for status := range op.Responses.StatusCodeResponses {
+		if status >= 200 && status < 300 && (best == 0 || status < best) {
+			best = status
+		}
+	}
+	if best == 0 {
+		return 0, nil
+	}
+	r := op.Responses.StatusCodeResponses[best]
+	return best, &r
+}
+
+func sortedProperties(s spec.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for n := range s.Properties {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedDefinitions(s *spec.Swagger) []string {
+	names := make([]string, 0, len(s.Definitions))
+	for n := range s.Definitions {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isNullable(s spec.Schema) bool {
+	v, _ := s.Extensions.GetBool(openapi.NullableExtension)
+	return v
+}
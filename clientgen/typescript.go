@@ -0,0 +1,350 @@
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// TypeScriptConfig holds configuration for TypeScript.
+type TypeScriptConfig struct{}
+
+// TypeScript generates a TypeScript module for the spec, with an interface for each definition and
+// a fetch based client created by `createClient`, with one function per operation. Properties that
+// are not required are optional and nullable (pointer) properties accept null. Date-time strings are
+// typed as DateTime, an RFC 3339 string that can be parsed with `new Date(value)`. Operations that
+// upload files are skipped.
+func TypeScript(s *spec.Swagger, c *TypeScriptConfig) ([]byte, error) {
+	g := &tsGenerator{}
+
+	for _, name := range sortedDefinitions(s) {
+		if err := g.definition(name, s.Definitions[name]); err != nil {
+			return nil, errors.Wrapf(err, "unable to generate definition %s", name)
+		}
+	}
+	for _, op := range operations(s) {
+		if err := g.operation(op); err != nil {
+			return nil, errors.Wrapf(err, "unable to generate %s %s", op.Method, op.Path)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by restgen. DO NOT EDIT.\n\n")
+	out.WriteString(tsClientSource)
+	out.Write(g.types.Bytes())
+	out.WriteString("export function createClient(options: ClientOptions) {\n")
+	out.WriteString("  const request = newRequester(options);\n")
+	out.WriteString("  return {\n")
+	out.Write(g.functions.Bytes())
+	out.WriteString("  };\n}\n\n")
+	out.WriteString("export type Client = ReturnType<typeof createClient>;\n")
+	return out.Bytes(), nil
+}
+
+type tsGenerator struct {
+	types     bytes.Buffer
+	functions bytes.Buffer
+}
+
+// tsType returns the TypeScript type for a schema.
+// nolint: gocyclo
+func tsType(s *spec.Schema) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref.String() != "" {
+		return exportedName(refName(s.Ref))
+	}
+
+	t := ""
+	if len(s.Type) > 0 {
+		t = s.Type[0]
+	}
+	switch t {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "DateTime"
+		case "binary":
+			return "Blob"
+		}
+		if len(s.Enum) > 0 {
+			return tsEnum(s.Enum)
+		}
+		return "string"
+	case "integer", "number":
+		if len(s.Enum) > 0 {
+			return tsEnum(s.Enum)
+		}
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "file":
+		return "Blob"
+	case "array":
+		if s.Items == nil || s.Items.Schema == nil {
+			return "unknown[]"
+		}
+		items := tsType(s.Items.Schema)
+		if strings.Contains(items, " ") {
+			items = "(" + items + ")"
+		}
+		return items + "[]"
+	case "object":
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			return "Record<string, " + tsType(s.AdditionalProperties.Schema) + ">"
+		}
+		if len(s.Properties) > 0 {
+			var b bytes.Buffer
+			b.WriteString("{\n")
+			tsFields(&b, *s, "  ")
+			b.WriteString("}")
+			return b.String()
+		}
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+func tsEnum(values []interface{}) string {
+	literals := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			literals = append(literals, fmt.Sprintf("%q", s))
+			continue
+		}
+		literals = append(literals, fmt.Sprint(v))
+	}
+	return strings.Join(literals, " | ")
+}
+
+// tsProperty quotes property names that are not valid identifiers.
+func tsProperty(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9')) {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
+
+func tsFields(b *bytes.Buffer, s spec.Schema, indent string) {
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	for _, name := range sortedProperties(s) {
+		prop := s.Properties[name]
+		t := tsType(&prop)
+		if isNullable(prop) {
+			t += " | null"
+		}
+		optional := ""
+		if !required[name] {
+			optional = "?"
+		}
+		if prop.Description != "" {
+			fmt.Fprintf(b, "%s/** %s */\n", indent, prop.Description)
+		}
+		fmt.Fprintf(b, "%s%s%s: %s;\n", indent, tsProperty(name), optional, t)
+	}
+}
+
+func (g *tsGenerator) definition(name string, s spec.Schema) error {
+	tsName := exportedName(name)
+	if s.Description != "" {
+		fmt.Fprintf(&g.types, "/** %s */\n", s.Description)
+	}
+	if !s.Type.Contains("object") || len(s.Properties) == 0 {
+		fmt.Fprintf(&g.types, "export type %s = %s;\n\n", tsName, tsType(&s))
+		return nil
+	}
+	fmt.Fprintf(&g.types, "export interface %s {\n", tsName)
+	tsFields(&g.types, s, "  ")
+	g.types.WriteString("}\n\n")
+	return nil
+}
+
+func paramSchema(p spec.Parameter) *spec.Schema {
+	s := &spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{p.Type}, Format: p.Format, Enum: p.Enum}}
+	if p.Items != nil {
+		s.Items = &spec.SchemaOrArray{Schema: &spec.Schema{
+			SchemaProps: spec.SchemaProps{Type: []string{p.Items.Type}, Format: p.Items.Format, Enum: p.Items.Enum},
+		}}
+	}
+	return s
+}
+
+func (g *tsGenerator) paramInterface(name, in string, params []spec.Parameter) {
+	fmt.Fprintf(&g.types, "export interface %s%s {\n", name, exportedName(in))
+	for _, p := range params {
+		optional := ""
+		if !p.Required && in != "path" {
+			optional = "?"
+		}
+		if p.Description != "" {
+			fmt.Fprintf(&g.types, "  /** %s */\n", p.Description)
+		}
+		fmt.Fprintf(&g.types, "  %s%s: %s;\n", tsProperty(p.Name), optional, tsType(paramSchema(p)))
+	}
+	g.types.WriteString("}\n\n")
+}
+
+func (g *tsGenerator) operation(op operation) error {
+	if op.ID == "" {
+		return errors.New("operation has no ID")
+	}
+	fn := camelName(op.ID)
+	typeName := exportedName(op.ID)
+	w := &g.functions
+
+	if op.hasFiles() {
+		fmt.Fprintf(w, "    // %s (%s %s) is not generated, file uploads are not supported.\n", fn, op.Method, op.Path)
+		return nil
+	}
+
+	pathParams, queryParams, body := op.params("path"), op.params("query"), op.body()
+	args := []string{}
+	pathArg, queryArg, bodyArg := "undefined", "undefined", "undefined"
+	if len(pathParams) > 0 {
+		g.paramInterface(typeName, "path", pathParams)
+		args = append(args, fmt.Sprintf("path: %sPath", typeName))
+		pathArg = "path"
+	}
+	if len(queryParams) > 0 {
+		g.paramInterface(typeName, "query", queryParams)
+		optional := ""
+		if !anyRequired(queryParams) {
+			optional = "?"
+		}
+		args = append(args, fmt.Sprintf("query%s: %sQuery", optional, typeName))
+		queryArg = "query"
+	}
+	if body != nil {
+		args = append(args, "body: "+tsType(body.Schema))
+		bodyArg = "body"
+	}
+	args = append(args, "init?: RequestInit")
+
+	result := "void"
+	if _, success := op.success(); success != nil && success.Schema != nil {
+		result = tsType(success.Schema)
+	}
+
+	doc := op.Summary
+	if doc == "" {
+		doc = fmt.Sprintf("Calls %s %s.", op.Method, op.Path)
+	}
+	fmt.Fprintf(w, "    /** %s", doc)
+	if op.Deprecated {
+		w.WriteString(" @deprecated")
+	}
+	w.WriteString(" */\n")
+	fmt.Fprintf(w, "    %s(%s): Promise<%s> {\n", fn, strings.Join(args, ", "), result)
+	fmt.Fprintf(w, "      return request<%s>(%q, %q, %s, %s, %s, %t, init);\n", result, op.Method, op.Path, pathArg, queryArg, bodyArg, op.authenticated())
+	w.WriteString("    },\n")
+	return nil
+}
+
+func anyRequired(params []spec.Parameter) bool {
+	for _, p := range params {
+		if p.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// tsClientSource is the fixed part of the generated module.
+const tsClientSource = `/** An RFC 3339 date-time string, parse it with ` + "`new Date(value)`" + `. */
+export type DateTime = string;
+
+export interface ClientOptions {
+  /** The URL the operation paths are relative to, for example https://api.example.com/v1. */
+  baseUrl: string;
+  /** A bearer token, or a function returning one, sent to operations that require authentication. */
+  token?: string | (() => string | Promise<string>);
+  /** Defaults to the global fetch. */
+  fetch?: typeof fetch;
+}
+
+export interface ProblemDetails {
+  type?: string;
+  title: string;
+  status: number;
+  detail?: string;
+  code?: string;
+  errors?: { field: string; in?: string; detail: string }[];
+}
+
+/** Thrown for responses that are not successful. */
+export class ApiError extends Error {
+  constructor(
+    readonly status: number,
+    readonly problem: ProblemDetails | undefined,
+    readonly body: string,
+  ) {
+    super(problem?.detail ? ` + "`${status} ${problem.detail}`" + ` : ` + "`${status} ${problem?.title ?? \"error\"}`" + `);
+    this.name = "ApiError";
+  }
+}
+
+function newRequester(options: ClientOptions) {
+  const doFetch = options.fetch ?? fetch;
+  return async function request<T>(
+    method: string,
+    path: string,
+    pathParams: object | undefined,
+    query: object | undefined,
+    body: unknown,
+    auth: boolean,
+    init?: RequestInit,
+  ): Promise<T> {
+    let url = options.baseUrl.replace(/\/$/, "") + path.replace(/\{([^}]+)\}/g, (_, name: string) => encodeURIComponent(String((pathParams as Record<string, unknown>)[name])));
+    const search = new URLSearchParams();
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value === undefined || value === null) {
+        continue;
+      }
+      for (const v of Array.isArray(value) ? value : [value]) {
+        search.append(name, String(v));
+      }
+    }
+    if ([...search].length > 0) {
+      url += "?" + search.toString();
+    }
+
+    const headers = new Headers(init?.headers);
+    headers.set("Accept", "application/json");
+    if (body !== undefined) {
+      headers.set("Content-Type", "application/json");
+    }
+    if (auth && options.token) {
+      const token = typeof options.token === "function" ? await options.token() : options.token;
+      headers.set("Authorization", "Bearer " + token);
+    }
+
+    const resp = await doFetch(url, {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await resp.text();
+    if (!resp.ok) {
+      let problem: ProblemDetails | undefined;
+      try {
+        problem = JSON.parse(text);
+      } catch {
+        problem = undefined;
+      }
+      throw new ApiError(resp.status, problem, text);
+    }
+    return (text === "" ? undefined : JSON.parse(text)) as T;
+  };
+}
+
+`
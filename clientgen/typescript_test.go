@@ -0,0 +1,34 @@
+package clientgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeScript(t *testing.T) {
+	assert := assert.New(t)
+
+	src, err := TypeScript(testSpec(t), nil)
+	require.NoError(t, err)
+	ts := string(src)
+
+	assert.Contains(ts, `export interface Widget {
+  color?: string | null;
+  created_at: DateTime;
+  id: string;
+  labels: Record<string, string>;
+  name: string;
+  tags: string[];
+}`)
+	assert.Contains(ts, `export interface GetWidgetsQuery {
+  page: number;
+  filter?: string;
+}`)
+	assert.Contains(ts, `getWidgets(query: GetWidgetsQuery, init?: RequestInit): Promise<Widget[]> {
+      return request<Widget[]>("GET", "/widgets", undefined, query, undefined, false, init);`)
+	assert.Contains(ts, `updateWidget(path: UpdateWidgetPath, body: Widget, init?: RequestInit): Promise<Widget> {
+      return request<Widget>("PUT", "/widgets/{id}", path, undefined, body, true, init);`)
+	assert.Contains(ts, `deleteWidget(path: DeleteWidgetPath, init?: RequestInit): Promise<void> {`)
+}
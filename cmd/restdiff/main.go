@@ -0,0 +1,110 @@
+// Command restdiff compares two Swagger specs generated by doctransport and reports breaking
+// changes. It exits with status 1 if any are found, so it can fail a CI build when a generated spec
+// breaks clients of the committed one:
+//
+//	restdiff [-format text|json] [-all] committed.json generated.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport/doctransport"
+)
+
+const (
+	exitBreaking = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("restdiff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format, text or json")
+	all := flags.Bool("all", false, "also report non-breaking changes")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: restdiff [-format text|json] [-all] old.json new.json")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 2 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return exitError
+	}
+
+	old, err := load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	next, err := load(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	changes := doctransport.Diff(old, next)
+	breaking := changes.Breaking()
+	if !*all {
+		changes = breaking
+	}
+
+	if err := write(stdout, *format, changes); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if len(breaking) > 0 {
+		return exitBreaking
+	}
+	return 0
+}
+
+func load(file string) (*spec.Swagger, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", file)
+	}
+	s := &spec.Swagger{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", file)
+	}
+	return s, nil
+}
+
+func write(w io.Writer, format string, changes doctransport.Changes) error {
+	if format == "json" {
+		if changes == nil {
+			changes = doctransport.Changes{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no breaking changes")
+		return err
+	}
+	for _, c := range changes {
+		label := "info    "
+		if c.Breaking {
+			label = "BREAKING"
+		}
+		if _, err := fmt.Fprintf(w, "%s %-24s %s\n", label, c.Kind, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type widget struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// writeSpec generates the spec of a single GET /widgets operation responding with body, and writes
+// it to a file.
+func writeSpec(t *testing.T, name string, body interface{}, withDelete bool) string {
+	noop := func(ctx context.Context, r resttransport.RequestResponse) error { return nil }
+	dt := doctransport.New(testtransport.New())
+	require.NoError(t, dt.RegisterHandler(http.MethodGet, "/widgets", nil, noop, resttransport.Response(http.StatusOK, body)))
+	if withDelete {
+		require.NoError(t, dt.RegisterHandler(http.MethodDelete, "/widgets", nil, noop))
+	}
+	s, err := dt.Generate()
+	require.NoError(t, err)
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, b, 0o644))
+	return file
+}
+
+func TestRun(t *testing.T) {
+	committed := writeSpec(t, "committed.json", widget{}, true)
+	// adds a required response property, which is safe
+	compatible := func() string {
+		type widget struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		return writeSpec(t, "compatible.json", widget{}, true)
+	}()
+	// removes the DELETE operation
+	breaking := writeSpec(t, "breaking.json", widget{}, false)
+
+	for _, c := range []struct {
+		name     string
+		args     []string
+		exit     int
+		contains string
+	}{
+		{"same", []string{committed, committed}, 0, "no breaking changes\n"},
+		{"compatible", []string{committed, compatible}, 0, "no breaking changes\n"},
+		{"compatible all", []string{"-all", committed, compatible}, 0, "info     property-required"},
+		{"breaking", []string{committed, breaking}, exitBreaking, "BREAKING operation-removed        DELETE /widgets: operation removed\n"},
+		{"breaking json", []string{"-format", "json", committed, breaking}, exitBreaking, `"kind": "operation-removed"`},
+		{"no changes json", []string{"-format", "json", committed, committed}, 0, "[]\n"},
+		{"missing file", []string{committed, filepath.Join(t.TempDir(), "missing.json")}, exitError, ""},
+		{"one file", []string{committed}, exitError, ""},
+		{"bad format", []string{"-format", "xml", committed, committed}, exitError, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var stdout, stderr bytes.Buffer
+			assert.Equal(c.exit, run(c.args, &stdout, &stderr))
+			if c.exit == exitError {
+				assert.NotEmpty(stderr.String())
+				return
+			}
+			assert.Contains(stdout.String(), c.contains)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Command restgen generates a typed API client from a Swagger spec generated by doctransport, in Go
+// or TypeScript:
+//
+//	restgen [-lang go|typescript] [-package client] [-o client.go] spec.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport/clientgen"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("restgen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	lang := flags.String("lang", "go", "client language, go or typescript")
+	pkg := flags.String("package", "client", "name of the generated Go package")
+	out := flags.String("o", "", "output file, defaults to stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("a spec file is required")
+	}
+
+	b, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", flags.Arg(0))
+	}
+	s := &spec.Swagger{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return errors.Wrapf(err, "unable to parse %s", flags.Arg(0))
+	}
+
+	var src []byte
+	switch *lang {
+	case "go":
+		src, err = clientgen.Go(s, &clientgen.GoConfig{Package: *pkg})
+	case "typescript", "ts":
+		src, err = clientgen.TypeScript(s, nil)
+	default:
+		return errors.Errorf("unsupported language '%s'", *lang)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0644)
+}
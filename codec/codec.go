@@ -0,0 +1,94 @@
+// Package codec provides the pluggable body encodings used by transports for content
+// negotiation.
+package codec
+
+import (
+	"io"
+	"sync"
+
+	"github.com/paultyng/resttransport/mediatype"
+)
+
+// Codec encodes and decodes bodies of a single content type.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Registry holds codecs in order of server preference.
+type Registry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// NewRegistry returns a Registry with the given codecs, the first being the default.
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{
+		codecs: codecs,
+	}
+}
+
+// Default returns a Registry with the built in JSON, XML, MessagePack and CBOR codecs, preferring
+// JSON.
+func Default() *Registry {
+	return NewRegistry(JSON, XML, MessagePack, CBOR)
+}
+
+// Register adds a codec, replacing any codec already registered for the same content type.
+func (r *Registry) Register(c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.codecs {
+		if existing.ContentType() == c.ContentType() {
+			r.codecs[i] = c
+			return
+		}
+	}
+	r.codecs = append(r.codecs, c)
+}
+
+// ContentTypes returns the content types of the registered codecs in order of preference.
+func (r *Registry) ContentTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cts := make([]string, 0, len(r.codecs))
+	for _, c := range r.codecs {
+		cts = append(cts, c.ContentType())
+	}
+	return cts
+}
+
+// Negotiate returns the codec that best satisfies an Accept header.
+func (r *Registry) Negotiate(accept string) (Codec, bool) {
+	ct, ok := mediatype.Negotiate(accept, r.ContentTypes())
+	if !ok {
+		return nil, false
+	}
+	return r.lookup(ct)
+}
+
+// ForContentType returns the codec for a request Content-Type. If allowed is not empty, the content
+// type must also be matched by one of the allowed media types.
+func (r *Registry) ForContentType(contentType string, allowed []string) (Codec, bool) {
+	if len(allowed) > 0 && !mediatype.Matches(allowed, contentType) {
+		return nil, false
+	}
+	for _, ct := range r.ContentTypes() {
+		if mediatype.Matches([]string{ct}, contentType) {
+			return r.lookup(ct)
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) lookup(contentType string) (Codec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.codecs {
+		if c.ContentType() == contentType {
+			return c, true
+		}
+	}
+	return nil, false
+}
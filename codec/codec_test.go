@@ -0,0 +1,92 @@
+package codec_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport/codec"
+)
+
+type foo struct {
+	ID   int64    `json:"id" xml:"id"`
+	Name string   `json:"name" xml:"name"`
+	Tags []string `json:"tags" xml:"tags"`
+}
+
+func TestBuiltinRoundTrip(t *testing.T) {
+	for _, c := range codec.Default().ContentTypes() {
+		t.Run(c, func(t *testing.T) {
+			require := require.New(t)
+
+			cod, ok := codec.Default().ForContentType(c, nil)
+			require.True(ok)
+
+			expected := foo{ID: 1, Name: "bar", Tags: []string{"a", "b"}}
+			buf := &bytes.Buffer{}
+			require.NoError(cod.Encode(buf, expected))
+
+			actual := foo{}
+			require.NoError(cod.Decode(buf, &actual))
+			require.Equal(expected, actual)
+		})
+	}
+}
+
+func TestRegistryNegotiate(t *testing.T) {
+	r := codec.Default()
+
+	for i, c := range []struct {
+		expected string
+		accept   string
+	}{
+		{"application/json", ""},
+		{"application/json", "*/*"},
+		{"application/xml", "text/xml, application/xml"},
+		{"application/msgpack", "application/msgpack"},
+		{"application/cbor", "application/json;q=0.1, application/cbor"},
+		{"", "text/html"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.accept), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, ok := r.Negotiate(c.accept)
+			if c.expected == "" {
+				assert.False(ok)
+				return
+			}
+			assert.True(ok)
+			assert.Equal(c.expected, actual.ContentType())
+		})
+	}
+}
+
+func TestRegistryForContentType(t *testing.T) {
+	r := codec.Default()
+
+	for i, c := range []struct {
+		expected    string
+		contentType string
+		allowed     []string
+	}{
+		{"application/json", "application/json; charset=utf-8", nil},
+		{"application/json", "application/json", []string{"application/*"}},
+		{"", "application/json", []string{"application/xml"}},
+		{"", "text/plain", nil},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.contentType), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, ok := r.ForContentType(c.contentType, c.allowed)
+			if c.expected == "" {
+				assert.False(ok)
+				return
+			}
+			assert.True(ok)
+			assert.Equal(c.expected, actual.ContentType())
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Package contracttransport checks handlers against a committed Swagger 2.0 document while tests
+// run, turning existing API tests into contract tests. It wraps another transport, typically a
+// testtransport:
+//
+//	s, err := contracttransport.LoadFile("testdata/swagger.json")
+//	...
+//	tt := testtransport.New()
+//	api.Register(contracttransport.New(t, tt, s))
+//
+// Registering a route that isn't in the document is reported, as is any request bound or response
+// sent through Body, NoBody, Blob, Attachment or Redirect that doesn't match it: undeclared status
+// codes, undeclared path or query parameters, and request or response bodies that don't match
+// their schema. Body mismatches are located with JSON pointers. Errors returned by handlers are not
+// checked.
+package contracttransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Load reads a Swagger 2.0 document in JSON or YAML.
+func Load(r io.Reader) (*spec.Swagger, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read contract")
+	}
+	if !json.Valid(raw) {
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, errors.Wrap(err, "unable to parse contract")
+		}
+		raw, err = json.Marshal(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert contract to JSON")
+		}
+	}
+
+	s := &spec.Swagger{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, errors.Wrap(err, "unable to parse contract")
+	}
+	if s.Swagger != "2.0" {
+		return nil, errors.Errorf("unsupported contract version '%s', expected Swagger 2.0", s.Swagger)
+	}
+	return s, nil
+}
+
+// LoadFile reads a Swagger 2.0 document in JSON or YAML from a file.
+func LoadFile(file string) (*spec.Swagger, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open contract %s", file)
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+type contractTransport struct {
+	t     testing.TB
+	inner resttransport.Transport
+	spec  *spec.Swagger
+}
+
+// New returns a Transport that reports contract violations of the handlers registered on inner to
+// t. Handlers still run and respond normally, so the tests' own assertions are unaffected.
+func New(t testing.TB, inner resttransport.Transport, s *spec.Swagger) resttransport.Transport {
+	return &contractTransport{
+		t:     t,
+		inner: inner,
+		spec:  s,
+	}
+}
+
+func (t *contractTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
+}
+
+func (t *contractTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
+}
+
+func (t *contractTransport) operation(httpMethod, path string) *spec.Operation {
+	if t.spec.Paths == nil {
+		return nil
+	}
+	pi, ok := t.spec.Paths.Paths[path]
+	if !ok {
+		return nil
+	}
+	switch httpMethod {
+	case http.MethodGet:
+		return pi.Get
+	case http.MethodPut:
+		return pi.Put
+	case http.MethodPost:
+		return pi.Post
+	case http.MethodDelete:
+		return pi.Delete
+	case http.MethodOptions:
+		return pi.Options
+	case http.MethodHead:
+		return pi.Head
+	case http.MethodPatch:
+		return pi.Patch
+	}
+	return nil
+}
+
+func (t *contractTransport) wrapHandler(httpMethod, path string, inner resttransport.Handler) resttransport.Handler {
+	c := &contract{
+		t:        t.t,
+		spec:     t.spec,
+		route:    fmt.Sprintf("%s %s", httpMethod, path),
+		location: fmt.Sprintf("#/paths/%s/%s", pointerToken(path), strings.ToLower(httpMethod)),
+		op:       t.operation(httpMethod, path),
+	}
+	if c.op == nil {
+		c.errorf("operation is not declared in the contract")
+	}
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		if c.op == nil {
+			return inner(ctx, reqres)
+		}
+		return inner(ctx, &contractRequestResponse{
+			RequestResponse: reqres,
+			contract:        c,
+		})
+	}
+}
+
+// contract checks the traffic of a single operation.
+type contract struct {
+	t    testing.TB
+	spec *spec.Swagger
+	// route is the registered method and path, used to prefix failures
+	route string
+	// location is the JSON pointer to the operation in the contract
+	location string
+	op       *spec.Operation
+}
+
+func (c *contract) errorf(format string, args ...interface{}) {
+	c.t.Helper()
+	c.t.Errorf("contract %s (%s): %s", c.route, c.location, fmt.Sprintf(format, args...))
+}
+
+// check validates value against s, reporting each mismatch prefixed with what was checked.
+func (c *contract) check(what string, s *spec.Schema, value interface{}) {
+	c.t.Helper()
+	doc, err := toJSON(value)
+	if err != nil {
+		c.errorf("%s: unable to marshal: %v", what, err)
+		return
+	}
+	v := &validator{definitions: c.spec.Definitions}
+	if err := v.validate("", s, doc); err != nil {
+		c.errorf("%s: %v", what, err)
+		return
+	}
+	for _, m := range v.mismatches {
+		c.errorf("%s %s", what, m)
+	}
+}
+
+// response returns the declared response for status. The default response only covers error
+// statuses, as doctransport uses it for problem details, so undeclared successes are still caught.
+func (c *contract) response(status int) (*spec.Response, bool) {
+	if c.op.Responses == nil {
+		return nil, false
+	}
+	if r, ok := c.op.Responses.StatusCodeResponses[status]; ok {
+		return &r, true
+	}
+	if c.op.Responses.Default != nil && status >= http.StatusBadRequest {
+		return c.op.Responses.Default, true
+	}
+	return nil, false
+}
+
+// checkStatus reports undeclared statuses and returns the declared response.
+func (c *contract) checkStatus(status int) (*spec.Response, bool) {
+	c.t.Helper()
+	r, ok := c.response(status)
+	if !ok {
+		c.errorf("response status %d is not declared", status)
+	}
+	return r, ok
+}
+
+func (c *contract) checkParameters(in string, v interface{}) {
+	c.t.Helper()
+	declared := map[string]spec.Parameter{}
+	for _, p := range c.op.Parameters {
+		if p.In == in {
+			declared[p.Name] = p
+		}
+	}
+
+	for name, value := range boundFields(v, in) {
+		p, ok := declared[name]
+		if !ok {
+			c.errorf("%s parameter '%s' is not declared", in, name)
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		c.check(fmt.Sprintf("%s parameter '%s'", in, name), parameterSchema(p), value)
+	}
+}
+
+type contractRequestResponse struct {
+	resttransport.RequestResponse
+	*contract
+}
+
+func (reqres *contractRequestResponse) BindQuery(v interface{}) error {
+	err := reqres.RequestResponse.BindQuery(v)
+	if err == nil {
+		reqres.checkParameters("query", v)
+	}
+	return err
+}
+
+func (reqres *contractRequestResponse) BindPath(v interface{}) error {
+	err := reqres.RequestResponse.BindPath(v)
+	if err == nil {
+		reqres.checkParameters("path", v)
+	}
+	return err
+}
+
+func (reqres *contractRequestResponse) BindBody(v interface{}) error {
+	err := reqres.RequestResponse.BindBody(v)
+	if err != nil {
+		return err
+	}
+
+	var body *spec.Parameter
+	for i, p := range reqres.op.Parameters {
+		if p.In == "body" {
+			body = &reqres.op.Parameters[i]
+		}
+	}
+	switch {
+	case body != nil && body.Schema != nil:
+		reqres.check("request body", body.Schema, v)
+	case body == nil && !hasFormParameters(reqres.op):
+		reqres.errorf("request body is not declared")
+	}
+	return nil
+}
+
+func hasFormParameters(op *spec.Operation) bool {
+	for _, p := range op.Parameters {
+		if p.In == "formData" {
+			return true
+		}
+	}
+	return false
+}
+
+func (reqres *contractRequestResponse) FormFile(name string) (*multipart.FileHeader, error) {
+	found := false
+	for _, p := range reqres.op.Parameters {
+		if p.In == "formData" && p.Name == name {
+			found = true
+		}
+	}
+	if !found {
+		reqres.errorf("form file '%s' is not declared", name)
+	}
+	return reqres.RequestResponse.FormFile(name)
+}
+
+func (reqres *contractRequestResponse) Body(status int, body interface{}) error {
+	if r, ok := reqres.checkStatus(status); ok {
+		if r.Schema == nil {
+			reqres.errorf("response %d has a body but none is declared", status)
+		} else {
+			reqres.check(fmt.Sprintf("response %d", status), r.Schema, body)
+		}
+	}
+	return reqres.RequestResponse.Body(status, body)
+}
+
+func (reqres *contractRequestResponse) Blob(status int, contentType string, b []byte) error {
+	reqres.checkStatus(status)
+	return reqres.RequestResponse.Blob(status, contentType, b)
+}
+
+func (reqres *contractRequestResponse) Stream(status int, contentType string, r io.Reader) error {
+	reqres.checkStatus(status)
+	return reqres.RequestResponse.Stream(status, contentType, r)
+}
+
+func (reqres *contractRequestResponse) Events(ctx context.Context) resttransport.EventSink {
+	reqres.checkStatus(http.StatusOK)
+	return reqres.RequestResponse.Events(ctx)
+}
+
+func (reqres *contractRequestResponse) NoBody(status int) error {
+	if r, ok := reqres.checkStatus(status); ok && r.Schema != nil {
+		reqres.errorf("response %d has no body but one is declared", status)
+	}
+	return reqres.RequestResponse.NoBody(status)
+}
+
+func (reqres *contractRequestResponse) Attachment(file, name, contentType string) error {
+	reqres.checkStatus(http.StatusOK)
+	return reqres.RequestResponse.Attachment(file, name, contentType)
+}
+
+func (reqres *contractRequestResponse) Redirect(status int, location string) error {
+	reqres.checkStatus(status)
+	return reqres.RequestResponse.Redirect(status, location)
+}
@@ -0,0 +1,158 @@
+package contracttransport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/contracttransport"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+type widget struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name" validate:"max=10"`
+	Tags      []string          `json:"tags"`
+	Labels    map[string]string `json:"labels"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type widgetQuery struct {
+	Page int `query:"page"`
+}
+
+type widgetPath struct {
+	ID string `path:"id"`
+}
+
+func contract(t *testing.T) []byte {
+	dt := doctransport.New(testtransport.New())
+	require.NoError(t, dt.RegisterHandler(http.MethodGet, "/widgets", nil, nil,
+		resttransport.QueryParams(widgetQuery{}),
+		resttransport.Response(http.StatusOK, []widget{}),
+	))
+	require.NoError(t, dt.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, nil,
+		resttransport.PathParams(widgetPath{}),
+		resttransport.RequestBody(widget{}),
+		resttransport.Response(http.StatusOK, widget{}),
+	))
+	s, err := dt.Generate()
+	require.NoError(t, err)
+	raw, err := json.Marshal(s)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestContract(t *testing.T) {
+	s, err := contracttransport.Load(bytes.NewReader(contract(t)))
+	require.NoError(t, err)
+
+	rec := &recorder{TB: t}
+	tt := testtransport.New()
+	ct := contracttransport.New(rec, tt, s)
+
+	// the response has drifted from the contract
+	require.NoError(t, ct.RegisterHandler(http.MethodGet, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		q := struct {
+			Page  int    `query:"page"`
+			Order string `query:"order"`
+		}{}
+		if err := r.BindQuery(&q); err != nil {
+			return err
+		}
+		return r.Body(http.StatusOK, []map[string]interface{}{
+			{"id": "1", "name": "a", "tags": []interface{}{"x", 2}, "labels": map[string]string{}, "created_at": "yesterday", "color": "red"},
+		})
+	}))
+	require.NoError(t, ct.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		p := widgetPath{}
+		if err := r.BindPath(&p); err != nil {
+			return err
+		}
+		w := widget{}
+		if err := r.BindBody(&w); err != nil {
+			return err
+		}
+		w.ID = p.ID
+		if w.Name == "created" {
+			return r.NoBody(http.StatusCreated)
+		}
+		return r.Body(http.StatusOK, w)
+	}))
+	require.NoError(t, ct.RegisterHandler(http.MethodDelete, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.NoBody(http.StatusNoContent)
+	}))
+
+	assert.Equal(t, []string{
+		"contract DELETE /widgets/{id} (#/paths/~1widgets~1{id}/delete): operation is not declared in the contract",
+	}, rec.failures)
+
+	rec.failures = nil
+	_, err = tt.Do(http.MethodGet, "/widgets", &testtransport.Request{Query: url.Values{"page": {"2"}, "order": {"asc"}}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"contract GET /widgets (#/paths/~1widgets/get): query parameter 'order' is not declared",
+		"contract GET /widgets (#/paths/~1widgets/get): response 200 /0/color: property is not declared",
+		`contract GET /widgets (#/paths/~1widgets/get): response 200 /0/created_at: "yesterday" is not an RFC 3339 date-time`,
+		"contract GET /widgets (#/paths/~1widgets/get): response 200 /0/tags/1: expected string, got integer",
+	}, rec.failures)
+
+	rec.failures = nil
+	resp, err := tt.Do(http.MethodPut, "/widgets/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "1"},
+		Body: widget{Name: "ok", Tags: []string{}, Labels: map[string]string{}, CreatedAt: time.Now()},
+	})
+	require.NoError(t, err)
+	resp.Expect(t).Status(http.StatusOK)
+	assert.Empty(t, rec.failures)
+
+	_, err = tt.Do(http.MethodPut, "/widgets/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "1"},
+		Body: widget{Name: "created"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"contract PUT /widgets/{id} (#/paths/~1widgets~1{id}/put): request body /labels: expected object, got null",
+		"contract PUT /widgets/{id} (#/paths/~1widgets~1{id}/put): request body /tags: expected array, got null",
+		"contract PUT /widgets/{id} (#/paths/~1widgets~1{id}/put): response status 201 is not declared",
+	}, rec.failures)
+}
+
+func TestLoadYAML(t *testing.T) {
+	s, err := contracttransport.Load(bytes.NewBufferString(`
+swagger: "2.0"
+paths:
+  /widgets:
+    get:
+      responses:
+        "200":
+          description: OK
+`))
+	require.NoError(t, err)
+	assert.Contains(t, s.Paths.Paths, "/widgets")
+
+	_, err = contracttransport.Load(bytes.NewBufferString(`{"openapi": "3.1.0"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,339 @@
+package contracttransport
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/paultyng/resttransport/doctransport/openapi"
+)
+
+// mismatch is a single difference between a value and its schema.
+type mismatch struct {
+	// Pointer is the RFC 6901 JSON pointer to the value, the empty string for the root.
+	Pointer string
+	Reason  string
+}
+
+func (m mismatch) String() string {
+	p := m.Pointer
+	if p == "" {
+		p = "/"
+	}
+	return fmt.Sprintf("%s: %s", p, m.Reason)
+}
+
+// pointerToken escapes a reference token per RFC 6901.
+func pointerToken(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// toJSON round trips v through JSON so it is checked the way a client receives it. Numbers are kept
+// as json.Number to tell integers apart.
+func toJSON(v interface{}) (interface{}, error) {
+	raw, ok := v.([]byte)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// validator checks JSON values against the subset of JSON Schema used by Swagger 2.0 documents
+// generated by doctransport: types and formats, references to definitions, required and unknown
+// properties, additional properties, items, enums and the validation keywords.
+type validator struct {
+	definitions spec.Definitions
+	mismatches  []mismatch
+}
+
+func (v *validator) fail(ptr, reason string, args ...interface{}) {
+	v.mismatches = append(v.mismatches, mismatch{Pointer: ptr, Reason: fmt.Sprintf(reason, args...)})
+}
+
+func (v *validator) resolve(s *spec.Schema) (*spec.Schema, error) {
+	for depth := 0; s.Ref.String() != ""; depth++ {
+		if depth > 32 {
+			return nil, fmt.Errorf("reference cycle at %s", s.Ref.String())
+		}
+		ref := s.Ref.String()
+		const prefix = "#/definitions/"
+		if !strings.HasPrefix(ref, prefix) {
+			return nil, fmt.Errorf("unsupported reference %s", ref)
+		}
+		def, ok := v.definitions[strings.TrimPrefix(ref, prefix)]
+		if !ok {
+			return nil, fmt.Errorf("unknown reference %s", ref)
+		}
+		s = &def
+	}
+	return s, nil
+}
+
+func typeOf(value interface{}) string {
+	switch x := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func nullable(s *spec.Schema) bool {
+	n, _ := s.Extensions.GetBool(openapi.NullableExtension)
+	return n
+}
+
+// nolint: gocyclo
+func (v *validator) validate(ptr string, s *spec.Schema, value interface{}) error {
+	s, err := v.resolve(s)
+	if err != nil {
+		return err
+	}
+
+	actual := typeOf(value)
+	if actual == "null" {
+		if !nullable(s) && len(s.Type) > 0 {
+			v.fail(ptr, "expected %s, got null", s.Type[0])
+		}
+		return nil
+	}
+	if len(s.Type) > 0 && !s.Type.Contains(actual) && !(actual == "integer" && s.Type.Contains("number")) {
+		if s.Type.Contains("file") {
+			return nil
+		}
+		v.fail(ptr, "expected %s, got %s", strings.Join(s.Type, " or "), actual)
+		return nil
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(ptr, "%v is not one of %v", value, s.Enum)
+		}
+	}
+
+	switch x := value.(type) {
+	case string:
+		v.validateString(ptr, s, x)
+	case json.Number:
+		f, _ := x.Float64()
+		if s.Minimum != nil && (f < *s.Minimum || (s.ExclusiveMinimum && f == *s.Minimum)) {
+			v.fail(ptr, "%v is less than the minimum %v", x, *s.Minimum)
+		}
+		if s.Maximum != nil && (f > *s.Maximum || (s.ExclusiveMaximum && f == *s.Maximum)) {
+			v.fail(ptr, "%v is greater than the maximum %v", x, *s.Maximum)
+		}
+		if s.MultipleOf != nil && *s.MultipleOf != 0 && math.Mod(f, *s.MultipleOf) != 0 {
+			v.fail(ptr, "%v is not a multiple of %v", x, *s.MultipleOf)
+		}
+	case []interface{}:
+		if s.MinItems != nil && int64(len(x)) < *s.MinItems {
+			v.fail(ptr, "has %d items, expected at least %d", len(x), *s.MinItems)
+		}
+		if s.MaxItems != nil && int64(len(x)) > *s.MaxItems {
+			v.fail(ptr, "has %d items, expected at most %d", len(x), *s.MaxItems)
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range x {
+				if err := v.validate(fmt.Sprintf("%s/%d", ptr, i), s.Items.Schema, item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		return v.validateObject(ptr, s, x)
+	}
+	return nil
+}
+
+func (v *validator) validateString(ptr string, s *spec.Schema, x string) {
+	n := int64(utf8.RuneCountInString(x))
+	if s.MinLength != nil && n < *s.MinLength {
+		v.fail(ptr, "has length %d, expected at least %d", n, *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		v.fail(ptr, "has length %d, expected at most %d", n, *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(x) {
+			v.fail(ptr, "%q does not match pattern %s", x, s.Pattern)
+		}
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, x); err != nil {
+			v.fail(ptr, "%q is not an RFC 3339 date-time", x)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", x); err != nil {
+			v.fail(ptr, "%q is not a date", x)
+		}
+	}
+}
+
+// validateObject checks properties. Properties that are not declared are reported unless the
+// schema allows additional properties, as they are undocumented parts of the contract.
+func (v *validator) validateObject(ptr string, s *spec.Schema, x map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := x[name]; !ok {
+			v.fail(ptr+"/"+pointerToken(name), "required property is missing")
+		}
+	}
+
+	names := make([]string, 0, len(x))
+	for name := range x {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := ptr + "/" + pointerToken(name)
+		if prop, ok := s.Properties[name]; ok {
+			if err := v.validate(p, &prop, x[name]); err != nil {
+				return err
+			}
+			continue
+		}
+		switch ap := s.AdditionalProperties; {
+		case ap != nil && ap.Schema != nil:
+			if err := v.validate(p, ap.Schema, x[name]); err != nil {
+				return err
+			}
+		case ap != nil && ap.Allows:
+		case ap == nil && len(s.Properties) == 0:
+			// a free form object
+		default:
+			v.fail(p, "property is not declared")
+		}
+	}
+
+	if s.MinProperties != nil && int64(len(x)) < *s.MinProperties {
+		v.fail(ptr, "has %d properties, expected at least %d", len(x), *s.MinProperties)
+	}
+	if s.MaxProperties != nil && int64(len(x)) > *s.MaxProperties {
+		v.fail(ptr, "has %d properties, expected at most %d", len(x), *s.MaxProperties)
+	}
+	return nil
+}
+
+// parameterSchema returns the schema of a simple (non body) parameter.
+func parameterSchema(p spec.Parameter) *spec.Schema {
+	s := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:             spec.StringOrArray{p.Type},
+			Format:           p.Format,
+			Enum:             p.Enum,
+			Minimum:          p.Minimum,
+			Maximum:          p.Maximum,
+			ExclusiveMinimum: p.ExclusiveMinimum,
+			ExclusiveMaximum: p.ExclusiveMaximum,
+			MinLength:        p.MinLength,
+			MaxLength:        p.MaxLength,
+			Pattern:          p.Pattern,
+			MinItems:         p.MinItems,
+			MaxItems:         p.MaxItems,
+		},
+	}
+	if p.Items != nil {
+		s.Items = &spec.SchemaOrArray{Schema: &spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type:   spec.StringOrArray{p.Items.Type},
+				Format: p.Items.Format,
+				Enum:   p.Items.Enum,
+			},
+		}}
+	}
+	return s
+}
+
+// boundFields returns the values of the exported fields of a bound struct keyed by the name in tag,
+// flattening embedded structs. Zero values are omitted as they can't be told apart from missing
+// parameters.
+func boundFields(v interface{}, tag string) map[string]interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+	var walk func(reflect.Value)
+	walk = func(rv reflect.Value) {
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				fv := rv.Field(i)
+				for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+					fv = fv.Elem()
+				}
+				if fv.Kind() == reflect.Struct {
+					walk(fv)
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Tag.Get(tag)
+			if i := strings.Index(name, ","); i >= 0 {
+				name = name[:i]
+			}
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fv := rv.Field(i)
+			if fv.IsZero() {
+				fields[name] = nil
+				continue
+			}
+			fields[name] = fv.Interface()
+		}
+	}
+	walk(rv)
+	return fields
+}
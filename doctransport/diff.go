@@ -0,0 +1,449 @@
+package doctransport
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// ChangeKind identifies the kind of difference between two specs.
+type ChangeKind string
+
+// Kinds of changes reported by Diff.
+const (
+	PathRemoved          ChangeKind = "path-removed"
+	OperationRemoved     ChangeKind = "operation-removed"
+	ParameterRequired    ChangeKind = "parameter-required"
+	ParameterTypeChanged ChangeKind = "parameter-type-changed"
+	ResponseRemoved      ChangeKind = "response-removed"
+	DefinitionRemoved    ChangeKind = "definition-removed"
+	PropertyRemoved      ChangeKind = "property-removed"
+	PropertyRequired     ChangeKind = "property-required"
+	TypeChanged          ChangeKind = "type-changed"
+	EnumNarrowed         ChangeKind = "enum-narrowed"
+	PathAdded            ChangeKind = "path-added"
+	OperationAdded       ChangeKind = "operation-added"
+	ParameterAdded       ChangeKind = "parameter-added"
+	ResponseAdded        ChangeKind = "response-added"
+	DefinitionAdded      ChangeKind = "definition-added"
+	PropertyAdded        ChangeKind = "property-added"
+	EnumWidened          ChangeKind = "enum-widened"
+	OperationDeprecated  ChangeKind = "operation-deprecated"
+	ParameterRemoved     ChangeKind = "parameter-removed"
+)
+
+// Change is a single difference found by Diff.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Breaking is true if clients of the old spec may fail against the new one.
+	Breaking bool `json:"breaking"`
+	// Location is where the change is, for example `GET /foos/{id}` or `definitions.Foo.name`.
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Location, c.Message)
+}
+
+// Changes is the result of Diff.
+type Changes []Change
+
+// Breaking returns only the breaking changes.
+func (cs Changes) Breaking() Changes {
+	var breaking Changes
+	for _, c := range cs {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// Diff compares two generated specs and reports the changes from old to next. Breaking changes are
+// removed paths, operations, response codes, definitions and properties, new required parameters,
+// changed types, and for request parameters and bodies, new required properties and narrowed enums.
+// Responses may gain required properties and narrow their enums without breaking clients, so
+// definitions are checked according to whether they are used by requests, responses or both.
+func Diff(old, next *spec.Swagger) Changes {
+	d := &differ{}
+	d.paths(paths(old), paths(next))
+
+	usage := definitionUsage(old)
+	for name, u := range definitionUsage(next) {
+		usage[name] |= u
+	}
+	d.definitions(old.Definitions, next.Definitions, usage)
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		if d.changes[i].Breaking != d.changes[j].Breaking {
+			return d.changes[i].Breaking
+		}
+		return d.changes[i].Location < d.changes[j].Location
+	})
+	return d.changes
+}
+
+type differ struct {
+	changes Changes
+}
+
+// usage is whether a schema is sent by clients in requests, returned to them in responses, or
+// both.
+type usage int
+
+const (
+	request usage = 1 << iota
+	response
+)
+
+// definitionUsage finds the usage of each definition reachable from the operations of s.
+func definitionUsage(s *spec.Swagger) map[string]usage {
+	u := map[string]usage{}
+	for _, pi := range paths(s) {
+		for _, m := range methods {
+			op := getOperation(pi, m)
+			if op == nil {
+				continue
+			}
+			for _, p := range op.Parameters {
+				markUsage(s.Definitions, u, p.Schema, request)
+			}
+			if op.Responses == nil {
+				continue
+			}
+			if op.Responses.Default != nil {
+				markUsage(s.Definitions, u, op.Responses.Default.Schema, response)
+			}
+			for _, r := range op.Responses.StatusCodeResponses {
+				markUsage(s.Definitions, u, r.Schema, response)
+			}
+		}
+	}
+	return u
+}
+
+func markUsage(defs spec.Definitions, u map[string]usage, s *spec.Schema, how usage) {
+	if s == nil {
+		return
+	}
+	if ref := s.Ref.String(); ref != "" {
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		if u[name]&how != 0 {
+			return
+		}
+		u[name] |= how
+		def, ok := defs[name]
+		if ok {
+			markUsage(defs, u, &def, how)
+		}
+		return
+	}
+	if s.Items != nil {
+		markUsage(defs, u, s.Items.Schema, how)
+	}
+	if s.AdditionalProperties != nil {
+		markUsage(defs, u, s.AdditionalProperties.Schema, how)
+	}
+	for _, p := range s.Properties {
+		p := p
+		markUsage(defs, u, &p, how)
+	}
+}
+
+func (d *differ) add(kind ChangeKind, breaking bool, location, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Kind:     kind,
+		Breaking: breaking,
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func paths(s *spec.Swagger) map[string]spec.PathItem {
+	if s.Paths == nil {
+		return nil
+	}
+	return s.Paths.Paths
+}
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH"}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *differ) paths(old, next map[string]spec.PathItem) {
+	for _, p := range sortedKeys(old) {
+		npi, ok := next[p]
+		if !ok {
+			d.add(PathRemoved, true, p, "path removed")
+			continue
+		}
+		opi := old[p]
+		for _, m := range methods {
+			oop, nop := getOperation(opi, m), getOperation(npi, m)
+			loc := m + " " + p
+			switch {
+			case oop == nil && nop != nil:
+				d.add(OperationAdded, false, loc, "operation added")
+			case oop != nil && nop == nil:
+				d.add(OperationRemoved, true, loc, "operation removed")
+			case oop != nil:
+				d.operation(loc, oop, nop)
+			}
+		}
+	}
+	for _, p := range sortedKeys(next) {
+		if _, ok := old[p]; !ok {
+			d.add(PathAdded, false, p, "path added")
+		}
+	}
+}
+
+func paramKey(p spec.Parameter) string {
+	return p.In + " " + p.Name
+}
+
+func (d *differ) operation(loc string, old, next *spec.Operation) {
+	if next.Deprecated && !old.Deprecated {
+		d.add(OperationDeprecated, false, loc, "operation deprecated")
+	}
+
+	oldParams := map[string]spec.Parameter{}
+	for _, p := range old.Parameters {
+		oldParams[paramKey(p)] = p
+	}
+	newParams := map[string]spec.Parameter{}
+	for _, p := range next.Parameters {
+		newParams[paramKey(p)] = p
+	}
+
+	for _, k := range sortedKeys(newParams) {
+		np := newParams[k]
+		ploc := fmt.Sprintf("%s parameter %s (%s)", loc, np.Name, np.In)
+		op, ok := oldParams[k]
+		switch {
+		case !ok && np.Required:
+			d.add(ParameterRequired, true, ploc, "new required parameter")
+		case !ok:
+			d.add(ParameterAdded, false, ploc, "new optional parameter")
+		default:
+			if np.Required && !op.Required {
+				d.add(ParameterRequired, true, ploc, "parameter is now required")
+			}
+			if np.In == "body" {
+				d.schema(ploc, op.Schema, np.Schema, request)
+				continue
+			}
+			if ot, nt := typeName([]string{op.Type}, op.Format), typeName([]string{np.Type}, np.Format); ot != nt {
+				d.add(ParameterTypeChanged, true, ploc, "type changed from %s to %s", ot, nt)
+			}
+			d.enum(ploc, op.Enum, np.Enum, request)
+		}
+	}
+	for _, k := range sortedKeys(oldParams) {
+		if _, ok := newParams[k]; !ok {
+			op := oldParams[k]
+			d.add(ParameterRemoved, false, fmt.Sprintf("%s parameter %s (%s)", loc, op.Name, op.In), "parameter removed")
+		}
+	}
+
+	d.responses(loc, old.Responses, next.Responses)
+}
+
+func (d *differ) responses(loc string, old, next *spec.Responses) {
+	oldCodes, newCodes := map[int]spec.Response{}, map[int]spec.Response{}
+	if old != nil {
+		oldCodes = old.StatusCodeResponses
+	}
+	if next != nil {
+		newCodes = next.StatusCodeResponses
+	}
+
+	for _, code := range sortedCodes(oldCodes) {
+		rloc := fmt.Sprintf("%s response %d", loc, code)
+		nr, ok := newCodes[code]
+		if !ok {
+			d.add(ResponseRemoved, true, rloc, "response removed")
+			continue
+		}
+		d.schema(rloc, oldCodes[code].Schema, nr.Schema, response)
+	}
+	for _, code := range sortedCodes(newCodes) {
+		if _, ok := oldCodes[code]; !ok {
+			d.add(ResponseAdded, false, fmt.Sprintf("%s response %d", loc, code), "response added")
+		}
+	}
+}
+
+func sortedCodes(m map[int]spec.Response) []int {
+	codes := make([]int, 0, len(m))
+	for c := range m {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// definitions compares the definitions of two specs. A definition that isn't used by any operation
+// is compared as if used by both requests and responses.
+func (d *differ) definitions(old, next spec.Definitions, usage map[string]usage) {
+	for _, name := range sortedKeys(old) {
+		loc := "definitions." + name
+		ns, ok := next[name]
+		if !ok {
+			d.add(DefinitionRemoved, true, loc, "definition removed")
+			continue
+		}
+		u := usage[name]
+		if u == 0 {
+			u = request | response
+		}
+		prev := old[name]
+		d.schema(loc, &prev, &ns, u)
+	}
+	for _, name := range sortedKeys(next) {
+		if _, ok := old[name]; !ok {
+			d.add(DefinitionAdded, false, "definitions."+name, "definition added")
+		}
+	}
+}
+
+func typeName(types []string, format string) string {
+	t := strings.Trim(strings.Join(types, "|"), "|")
+	if t == "" {
+		t = "any"
+	}
+	if format != "" {
+		t += "(" + format + ")"
+	}
+	return t
+}
+
+func schemaTypeName(s *spec.Schema) string {
+	if ref := s.Ref.String(); ref != "" {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	return typeName(s.Type, s.Format)
+}
+
+// schema compares two schemas used as u, definitions are compared separately so references are
+// compared by name only.
+func (d *differ) schema(loc string, old, next *spec.Schema, u usage) {
+	switch {
+	case old == nil && next == nil:
+		return
+	case old == nil || next == nil:
+		d.add(TypeChanged, true, loc, "body changed from %s to %s", nullableTypeName(old), nullableTypeName(next))
+		return
+	}
+
+	if ot, nt := schemaTypeName(old), schemaTypeName(next); ot != nt {
+		d.add(TypeChanged, true, loc, "type changed from %s to %s", ot, nt)
+		return
+	}
+	d.enum(loc, old.Enum, next.Enum, u)
+
+	if old.Items != nil && old.Items.Schema != nil && next.Items != nil {
+		d.schema(loc+"[]", old.Items.Schema, next.Items.Schema, u)
+	}
+	if old.AdditionalProperties != nil && next.AdditionalProperties != nil {
+		d.schema(loc+"{}", old.AdditionalProperties.Schema, next.AdditionalProperties.Schema, u)
+	}
+
+	oldRequired := map[string]bool{}
+	for _, r := range old.Required {
+		oldRequired[r] = true
+	}
+	for _, name := range sortedKeys(next.Properties) {
+		ploc := loc + "." + name
+		np := next.Properties[name]
+		required := contains(next.Required, name)
+		op, ok := old.Properties[name]
+		switch {
+		case !ok && required:
+			// clients must now send it, but can ignore it in responses
+			d.add(PropertyRequired, u&request != 0, ploc, "new required property")
+		case !ok:
+			d.add(PropertyAdded, false, ploc, "new optional property")
+		default:
+			if required && !oldRequired[name] {
+				d.add(PropertyRequired, u&request != 0, ploc, "property is now required")
+			}
+			d.schema(ploc, &op, &np, u)
+		}
+	}
+	for _, name := range sortedKeys(old.Properties) {
+		if _, ok := next.Properties[name]; !ok {
+			d.add(PropertyRemoved, true, loc+"."+name, "property removed")
+		}
+	}
+}
+
+func nullableTypeName(s *spec.Schema) string {
+	if s == nil {
+		return "none"
+	}
+	return schemaTypeName(s)
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// enum compares the enums of values used as u. Narrowing an enum rejects values clients may send,
+// but only restricts the values they receive.
+func (d *differ) enum(loc string, old, next []interface{}, u usage) {
+	if len(old) == 0 && len(next) == 0 {
+		return
+	}
+	breaking := u&request != 0
+	if len(next) == 0 {
+		d.add(EnumWidened, false, loc, "enum removed")
+		return
+	}
+	if len(old) == 0 {
+		d.add(EnumNarrowed, breaking, loc, "enum added")
+		return
+	}
+
+	var removed, added []string
+	for _, v := range old {
+		if !containsValue(next, v) {
+			removed = append(removed, fmt.Sprint(v))
+		}
+	}
+	for _, v := range next {
+		if !containsValue(old, v) {
+			added = append(added, fmt.Sprint(v))
+		}
+	}
+	if len(removed) > 0 {
+		d.add(EnumNarrowed, breaking, loc, "enum values removed: %s", strings.Join(removed, ", "))
+	}
+	if len(added) > 0 {
+		d.add(EnumWidened, false, loc, "enum values added: %s", strings.Join(added, ", "))
+	}
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
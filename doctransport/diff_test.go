@@ -0,0 +1,143 @@
+package doctransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func generate(t *testing.T, register func(resttransport.Transport)) *spec.Swagger {
+	dt := New(testtransport.New())
+	register(dt)
+	s, err := dt.Generate()
+	require.NoError(t, err)
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	noop := func(ctx context.Context, r resttransport.RequestResponse) error { return nil }
+
+	old := generate(t, func(tr resttransport.Transport) {
+		type widget struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Status string `json:"status" validate:"enum=open|closed|archived"`
+		}
+		require.NoError(t, tr.RegisterHandler(http.MethodGet, "/widgets", nil, noop,
+			resttransport.Response(http.StatusOK, []widget{}),
+			resttransport.ErrorResponses(http.StatusNotFound)))
+		require.NoError(t, tr.RegisterHandler(http.MethodDelete, "/widgets", nil, noop))
+		require.NoError(t, tr.RegisterHandler(http.MethodGet, "/gadgets", nil, noop))
+	})
+	next := generate(t, func(tr resttransport.Transport) {
+		type widget struct {
+			ID     int64   `json:"id"`
+			Status string  `json:"status" validate:"enum=open|closed"`
+			Color  *string `json:"color"`
+		}
+		require.NoError(t, tr.RegisterHandler(http.MethodGet, "/widgets", nil, noop,
+			resttransport.QueryParams(struct {
+				Page int `query:"page"`
+			}{}),
+			resttransport.Response(http.StatusOK, []widget{})))
+		require.NoError(t, tr.RegisterHandler(http.MethodPost, "/widgets", nil, noop))
+	})
+
+	changes := Diff(old, next)
+
+	breaking := map[ChangeKind][]string{}
+	for _, c := range changes.Breaking() {
+		breaking[c.Kind] = append(breaking[c.Kind], c.Location)
+	}
+	assert.Equal(map[ChangeKind][]string{
+		PathRemoved:       {"/gadgets"},
+		OperationRemoved:  {"DELETE /widgets"},
+		ParameterRequired: {"GET /widgets parameter page (query)"},
+		ResponseRemoved:   {"GET /widgets response 404"},
+		TypeChanged:       {"definitions.widget.id"},
+		PropertyRemoved:   {"definitions.widget.name"},
+	}, breaking)
+
+	var added []ChangeKind
+	for _, c := range changes {
+		if !c.Breaking {
+			added = append(added, c.Kind)
+		}
+	}
+	assert.Contains(added, OperationAdded)
+	assert.Contains(added, PropertyAdded)
+	// widget is only returned, so clients can't send a removed value
+	assert.Contains(added, EnumNarrowed)
+
+	assert.Empty(Diff(old, old))
+}
+
+func TestDiff_RequestsAndResponses(t *testing.T) {
+	assert := assert.New(t)
+
+	noop := func(ctx context.Context, r resttransport.RequestResponse) error { return nil }
+
+	old := generate(t, func(tr resttransport.Transport) {
+		type label struct {
+			Key string `json:"key"`
+		}
+		type gadgetInput struct {
+			Name   string  `json:"name"`
+			Size   string  `json:"size" validate:"enum=s|m|l"`
+			Labels []label `json:"labels"`
+		}
+		type gadget struct {
+			ID     string  `json:"id"`
+			Status string  `json:"status" validate:"enum=open|closed|archived"`
+			Labels []label `json:"labels"`
+		}
+		require.NoError(t, tr.RegisterHandler(http.MethodPost, "/gadgets", nil, noop,
+			resttransport.RequestBody(gadgetInput{}),
+			resttransport.Response(http.StatusCreated, gadget{})))
+	})
+	next := generate(t, func(tr resttransport.Transport) {
+		type label struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		type gadgetInput struct {
+			Name   string  `json:"name"`
+			Size   string  `json:"size" validate:"enum=s|m"`
+			Color  string  `json:"color"`
+			Labels []label `json:"labels"`
+		}
+		type gadget struct {
+			ID     string  `json:"id"`
+			Status string  `json:"status" validate:"enum=open|closed"`
+			Owner  string  `json:"owner"`
+			Labels []label `json:"labels"`
+		}
+		require.NoError(t, tr.RegisterHandler(http.MethodPost, "/gadgets", nil, noop,
+			resttransport.RequestBody(gadgetInput{}),
+			resttransport.Response(http.StatusCreated, gadget{})))
+	})
+
+	breaking := map[string]bool{}
+	for _, c := range Diff(old, next) {
+		breaking[string(c.Kind)+" "+c.Location] = c.Breaking
+	}
+	assert.Equal(map[string]bool{
+		// sent by clients
+		"property-required definitions.gadgetInput.color": true,
+		"enum-narrowed definitions.gadgetInput.size":      true,
+		// returned to clients
+		"property-required definitions.gadget.owner": false,
+		"enum-narrowed definitions.gadget.status":    false,
+		// both
+		"property-required definitions.label.value": true,
+	}, breaking)
+}
@@ -1,20 +1,27 @@
 // Package doctransport observes API registrations and traffic and generates
 // Swagger. Ideally you could use this with unit tests which simulate API
-// traffic.
+// traffic. Types and statuses declared with route options (see
+// resttransport.Route) are documented at registration, so endpoints that
+// aren't exercised are documented too.
 package doctransport
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"reflect"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-openapi/spec"
 	"github.com/pkg/errors"
 
 	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport/openapi"
 	"github.com/paultyng/resttransport/routename"
 )
 
@@ -24,6 +31,9 @@ type docTransport struct {
 	spec             *spec.Swagger
 	referenceStructs map[reflect.Type]bool
 	namer            routename.Namer
+	securitySchemes  map[string]*resttransport.SecurityScheme
+	// rev is incremented whenever the spec changes, see changed
+	rev uint64
 }
 
 type docRequestResponse struct {
@@ -36,6 +46,8 @@ type docRequestResponse struct {
 type SwaggerTransport interface {
 	resttransport.Transport
 	Generate() (*spec.Swagger, error)
+	// GenerateOpenAPI generates an OpenAPI 3.1 document from the same recorded operations.
+	GenerateOpenAPI() (*openapi.Document, error)
 }
 
 // New returns an resttransport middleware that records interactions for documenting.
@@ -44,7 +56,6 @@ func New(inner resttransport.Transport) SwaggerTransport {
 		inner: inner,
 		spec: &spec.Swagger{
 			SwaggerProps: spec.SwaggerProps{
-				// TODO: swagger 3?
 				Swagger: "2.0",
 				// TODO: other types?
 				Consumes: []string{"application/json"},
@@ -52,6 +63,7 @@ func New(inner resttransport.Transport) SwaggerTransport {
 			},
 		},
 		referenceStructs: map[reflect.Type]bool{},
+		securitySchemes:  map[string]*resttransport.SecurityScheme{},
 		namer:            routename.New(),
 	}
 }
@@ -59,18 +71,20 @@ func New(inner resttransport.Transport) SwaggerTransport {
 func (t *docTransport) Generate() (*spec.Swagger, error) {
 	t.Lock()
 	defer t.Unlock()
-	swagger := *t.spec
 
-	// TODO: support additional auth types
-	swagger.SecurityDefinitions = spec.SecurityDefinitions{
-		bearerTokenAuthorizationName: &spec.SecurityScheme{
-			SecuritySchemeProps: spec.SecuritySchemeProps{
-				Description: "Requires a bearer token",
-				In:          "header",
-				Name:        "Authorization",
-				Type:        "apiKey",
-			},
-		},
+	// handlers keep documenting traffic into t.spec, so return a deep copy that shares nothing
+	// with it
+	b, err := json.Marshal(t.spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to copy swagger")
+	}
+	swagger := spec.Swagger{}
+	if err := json.Unmarshal(b, &swagger); err != nil {
+		return nil, errors.Wrap(err, "unable to copy swagger")
+	}
+
+	if len(t.securitySchemes) > 0 {
+		swagger.SecurityDefinitions = t.securityDefinitions()
 	}
 
 	if swagger.Definitions == nil {
@@ -88,11 +102,20 @@ func (t *docTransport) Generate() (*spec.Swagger, error) {
 	return &swagger, nil
 }
 
+func (t *docTransport) GenerateOpenAPI() (*openapi.Document, error) {
+	swagger, err := t.Generate()
+	if err != nil {
+		return nil, err
+	}
+	return openapi.FromSwagger(swagger)
+}
+
 func addStructs(structs map[reflect.Type]bool, t reflect.Type) {
 	switch t.Kind() {
 	case reflect.Ptr,
 		reflect.Slice,
-		reflect.Array:
+		reflect.Array,
+		reflect.Map:
 		addStructs(structs, t.Elem())
 		return
 	case reflect.Struct:
@@ -114,7 +137,11 @@ func addStructs(structs map[reflect.Type]bool, t reflect.Type) {
 }
 
 func (t *docTransport) addReferenceStruct(ref reflect.Type) {
+	n := len(t.referenceStructs)
 	addStructs(t.referenceStructs, ref)
+	if len(t.referenceStructs) != n {
+		t.changed()
+	}
 }
 
 func getOperation(pi spec.PathItem, httpMethod string) *spec.Operation {
@@ -159,7 +186,32 @@ func setOperation(pi *spec.PathItem, httpMethod string, op *spec.Operation) {
 	}
 }
 
-func (t *docTransport) wrapHandler(auth bool, httpMethod, path string, consumes []string, inner resttransport.Handler) resttransport.Handler {
+func (t *docTransport) wrapHandler(auth bool, httpMethod, path string, consumes []string, inner resttransport.Handler, opts []resttransport.RouteOption) (resttransport.Handler, error) {
+	op, err := t.declareOperation(auth, httpMethod, path, consumes, resttransport.NewRoute(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		wrapper := &docRequestResponse{
+			inner:        reqres,
+			op:           op,
+			docTransport: t,
+		}
+
+		err := inner(ctx, wrapper)
+		if e, ok := resttransport.AsError(err); ok && e.Status != 0 {
+			t.Lock()
+			defer t.Unlock()
+			t.addProblemResponse(op, e.Status)
+		}
+		return err
+	}, nil
+}
+
+// declareOperation adds the operation for a route to the spec if it is new, and documents the
+// route's options on it.
+func (t *docTransport) declareOperation(auth bool, httpMethod, path string, consumes []string, route *resttransport.Route) (*spec.Operation, error) {
 	if t.spec.Paths == nil {
 		t.spec.Paths = &spec.Paths{
 			Paths: map[string]spec.PathItem{},
@@ -179,17 +231,19 @@ func (t *docTransport) wrapHandler(auth bool, httpMethod, path string, consumes
 				Parameters:  []spec.Parameter{},
 				Responses: &spec.Responses{
 					ResponsesProps: spec.ResponsesProps{
+						Default:             t.problemResponse(0),
 						StatusCodeResponses: map[int]spec.Response{},
 					},
 				},
 			},
 		}
-		if auth {
-			op.Security = append(op.OperationProps.Security, map[string][]string{"Bearer": []string{}})
-			op.Responses.StatusCodeResponses[http.StatusUnauthorized] = spec.Response{
-				ResponseProps: spec.ResponseProps{
-					Description: http.StatusText(http.StatusUnauthorized),
-				},
+		if len(consumes) > 0 {
+			t.addProblemResponse(op, http.StatusUnsupportedMediaType)
+		}
+		if auth && len(route.Security) == 0 {
+			err := t.declareSecurity(op, []resttransport.SecurityRequirement{{Scheme: defaultBearerScheme}})
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to document %s %s", httpMethod, path)
 			}
 		}
 		setOperation(&pi, httpMethod, op)
@@ -197,31 +251,170 @@ func (t *docTransport) wrapHandler(auth bool, httpMethod, path string, consumes
 
 	t.spec.Paths.Paths[path] = pi
 
-	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
-		wrapper := &docRequestResponse{
-			inner:        reqres,
-			op:           op,
-			docTransport: t,
+	t.changed()
+	if err := t.declareRoute(op, route); err != nil {
+		return nil, errors.Wrapf(err, "unable to document %s %s", httpMethod, path)
+	}
+	switch httpMethod {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if route.RequirePreconditions || route.CurrentVersion != nil {
+			t.declarePreconditions(op, route.RequirePreconditions)
 		}
+	}
+	return op, nil
+}
+
+// declareRoute documents the types and statuses declared by route options (see
+// resttransport.Route), so the operation is complete straight after registration. Traffic observed
+// by the wrapped handler is merged on top: parameters and responses it finds that were not declared
+// are added, and responses it sends replace the declared response for that status, keeping its
+// declared headers.
+func (t *docTransport) declareRoute(op *spec.Operation, r *resttransport.Route) error {
+	applyOperationOptions(op, r)
+
+	if len(r.Security) > 0 {
+		if err := t.declareSecurity(op, r.Security); err != nil {
+			return err
+		}
+	}
 
-		return inner(ctx, wrapper)
+	if r.PathParams != nil {
+		t.addProblemResponse(op, http.StatusBadRequest)
+		if err := t.appendSimpleSchemaParameters(op, "path", r.PathParams); err != nil {
+			return err
+		}
 	}
+	if r.QueryParams != nil {
+		t.addProblemResponse(op, http.StatusBadRequest)
+		if err := t.appendSimpleSchemaParameters(op, "query", r.QueryParams); err != nil {
+			return err
+		}
+	}
+	if r.RequestBody != nil {
+		t.addProblemResponse(op, http.StatusBadRequest)
+		if err := t.appendBodyParameter(op, r.RequestBody); err != nil {
+			return errors.Wrap(err, "unable to append body parameter")
+		}
+	}
+	for status, typ := range r.Responses {
+		if typ == nil {
+			t.setNoBodyResponse(op, status)
+			continue
+		}
+		if err := t.setBodyResponse(op, status, typ); err != nil {
+			return err
+		}
+	}
+	for status, contentType := range r.Streams {
+		t.setStreamResponse(op, status, contentType)
+	}
+	for status, headers := range r.ResponseHeaders {
+		for name, description := range headers {
+			t.addResponseHeader(op, status, name, description)
+		}
+	}
+	return nil
+}
+
+// declarePreconditions documents the precondition headers and responses of a route registered with
+// resttransport.RequirePreconditions or resttransport.CurrentVersion.
+func (t *docTransport) declarePreconditions(op *spec.Operation, required bool) {
+	description := "Only perform the request if the resource has not changed"
+	if required {
+		description = "Either If-Match or If-Unmodified-Since is required"
+	}
+	for _, name := range []string{"If-Match", "If-Unmodified-Since"} {
+		if hasParameter(op, "header", name) {
+			continue
+		}
+		t.changed()
+		op.Parameters = append(op.Parameters, spec.Parameter{
+			SimpleSchema: spec.SimpleSchema{
+				Type: "string",
+			},
+			ParamProps: spec.ParamProps{
+				In:          "header",
+				Name:        name,
+				Description: description,
+			},
+		})
+	}
+	t.addProblemResponse(op, http.StatusPreconditionFailed)
+	if required {
+		t.addProblemResponse(op, http.StatusPreconditionRequired)
+	}
+}
+
+func hasParameter(op *spec.Operation, in, name string) bool {
+	for _, p := range op.Parameters {
+		if p.In == in && p.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
-func (t *docTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
+func (t *docTransport) revision() uint64 {
+	return atomic.LoadUint64(&t.rev)
+}
+
+// changed records a change to the spec, invalidating specs cached by revision. It must be called
+// with t locked, whenever the spec is modified.
+func (t *docTransport) changed() {
+	atomic.AddUint64(&t.rev, 1)
+}
+
+// problemResponse returns a response documenting resttransport.Problem for an error status, or
+// the default response if status is 0.
+func (t *docTransport) problemResponse(status int) *spec.Response {
+	t.addReferenceStruct(problemType)
+
+	// Problem is always a struct, so this can't fail
+	sch, _ := refSchema(problemType)
+
+	description := "Error"
+	if status != 0 {
+		description = http.StatusText(status)
+	}
+	return &spec.Response{
+		ResponseProps: spec.ResponseProps{
+			Description: description,
+			Schema:      &sch,
+		},
+	}
+}
+
+// addProblemResponse documents an error status if it is not already documented.
+func (t *docTransport) addProblemResponse(op *spec.Operation, status int) {
+	if _, ok := op.Responses.StatusCodeResponses[status]; ok {
+		return
+	}
+	t.changed()
+	op.Responses.StatusCodeResponses[status] = *t.problemResponse(status)
+}
+
+func (t *docTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
 	t.Lock()
 	defer t.Unlock()
-	return t.inner.RegisterHandler(httpMethod, path, consumes, t.wrapHandler(false, httpMethod, path, consumes, h))
+	wrapped, err := t.wrapHandler(false, httpMethod, path, consumes, h, opts)
+	if err != nil {
+		return err
+	}
+	return t.inner.RegisterHandler(httpMethod, path, consumes, wrapped, opts...)
 }
 
-func (t *docTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
+func (t *docTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
 	t.Lock()
 	defer t.Unlock()
-	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(true, httpMethod, path, consumes, h))
+	wrapped, err := t.wrapHandler(true, httpMethod, path, consumes, h, opts)
+	if err != nil {
+		return err
+	}
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, wrapped, opts...)
 }
 
-func (reqres *docRequestResponse) hasBodyParameter() bool {
-	for _, p := range reqres.op.Parameters {
+func hasBodyParameter(op *spec.Operation) bool {
+	for _, p := range op.Parameters {
 		if p.In == "body" {
 			return true
 		}
@@ -229,23 +422,23 @@ func (reqres *docRequestResponse) hasBodyParameter() bool {
 	return false
 }
 
-func (reqres *docRequestResponse) appendBodyParameter(v interface{}) error {
+func (t *docTransport) appendBodyParameter(op *spec.Operation, typ reflect.Type) error {
 	const in = "body"
 
-	if reqres.hasBodyParameter() {
+	if hasBodyParameter(op) {
 		//short circuit if body param exists
 		return nil
 	}
 
-	t := reflect.TypeOf(v)
-	reqres.addReferenceStruct(t)
+	t.addReferenceStruct(typ)
 
-	typeSchema, err := schema(t, true)
+	typeSchema, err := schema(typ, true)
 	if err != nil {
 		return errors.Wrap(err, "unable to map type for schema")
 	}
 
-	reqres.op.Parameters = append(reqres.op.Parameters, spec.Parameter{
+	t.changed()
+	op.Parameters = append(op.Parameters, spec.Parameter{
 		ParamProps: spec.ParamProps{
 			In:          in,
 			Name:        in,
@@ -261,11 +454,32 @@ func (reqres *docRequestResponse) RequestHeader() http.Header {
 	return reqres.inner.RequestHeader()
 }
 
+func (reqres *docRequestResponse) RemoteAddr() string {
+	return reqres.inner.RemoteAddr()
+}
+
+func (reqres *docRequestResponse) URL() *url.URL {
+	return reqres.inner.URL()
+}
+
+func (reqres *docRequestResponse) RawBody() ([]byte, error) {
+	return reqres.inner.RawBody()
+}
+
+func (reqres *docRequestResponse) Preconditions() resttransport.Preconditions {
+	return reqres.inner.Preconditions()
+}
+
+func (reqres *docRequestResponse) ResponseHeader() http.Header {
+	return reqres.inner.ResponseHeader()
+}
+
 func (reqres *docRequestResponse) BindBody(v interface{}) error {
 	err := func() error {
 		reqres.Lock()
 		defer reqres.Unlock()
-		return reqres.appendBodyParameter(v)
+		reqres.addProblemResponse(reqres.op, http.StatusBadRequest)
+		return reqres.appendBodyParameter(reqres.op, reflect.TypeOf(v))
 	}()
 	if err != nil {
 		return errors.Wrap(err, "unable to append body parameter")
@@ -278,7 +492,8 @@ func (reqres *docRequestResponse) BindQuery(v interface{}) error {
 	err := func() error {
 		reqres.Lock()
 		defer reqres.Unlock()
-		return reqres.appendSimpleSchemaParameters(in, v)
+		reqres.addProblemResponse(reqres.op, http.StatusBadRequest)
+		return reqres.appendSimpleSchemaParameters(reqres.op, in, reflect.TypeOf(v))
 	}()
 	if err != nil {
 		return err
@@ -291,7 +506,8 @@ func (reqres *docRequestResponse) BindPath(v interface{}) error {
 	err := func() error {
 		reqres.Lock()
 		defer reqres.Unlock()
-		return reqres.appendSimpleSchemaParameters(in, v)
+		reqres.addProblemResponse(reqres.op, http.StatusBadRequest)
+		return reqres.appendSimpleSchemaParameters(reqres.op, in, reflect.TypeOf(v))
 	}()
 	if err != nil {
 		return err
@@ -299,26 +515,27 @@ func (reqres *docRequestResponse) BindPath(v interface{}) error {
 	return reqres.inner.BindPath(v)
 }
 
-func (reqres *docRequestResponse) appendSimpleSchemaParameters(in string, v interface{}) error {
-	t := reflect.TypeOf(v)
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+func (t *docTransport) appendSimpleSchemaParameters(op *spec.Operation, in string, typ reflect.Type) error {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
 	}
-	if t.Kind() != reflect.Struct {
-		return fmt.Errorf("type %s in %s is not a struct", t.Name(), in)
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("type %s in %s is not a struct", typ.Name(), in)
 	}
-	return eachStructField(t, in, func(name string, required bool, s spec.Schema) error {
+	return eachStructField(typ, in, func(name string, required bool, s spec.Schema) error {
 		st := ""
 		if len(s.Type) > 0 {
 			st = s.Type[0]
 		}
-		for _, p := range reqres.op.Parameters {
+		for _, p := range op.Parameters {
 			if p.Name == name {
 				//already exists
 				return nil
 			}
 		}
-		reqres.op.Parameters = append(reqres.op.Parameters, spec.Parameter{
+		t.changed()
+		op.Parameters = append(op.Parameters, spec.Parameter{
+			CommonValidations: commonValidations(s),
 			SimpleSchema: spec.SimpleSchema{
 				Type:    st,
 				Format:  s.Format,
@@ -344,6 +561,10 @@ func (reqres *docRequestResponse) FormFile(name string) (*multipart.FileHeader,
 	err := func() error {
 		reqres.Lock()
 		defer reqres.Unlock()
+		if hasParameter(reqres.op, "body", name) {
+			return nil
+		}
+		reqres.changed()
 		reqres.op.Parameters = append(reqres.op.Parameters, spec.Parameter{
 			SimpleSchema: spec.SimpleSchema{
 				Type: "file",
@@ -393,7 +614,7 @@ func (reqres *docRequestResponse) Attachment(file, name, contentType string) err
 	func() {
 		reqres.Lock()
 		defer reqres.Unlock()
-		reqres.op.Responses.StatusCodeResponses[200] = resp
+		reqres.setResponse(reqres.op, http.StatusOK, resp)
 	}()
 	return reqres.inner.Attachment(file, name, contentType)
 }
@@ -416,34 +637,115 @@ func (reqres *docRequestResponse) Redirect(status int, location string) error {
 	func() {
 		reqres.Lock()
 		defer reqres.Unlock()
-		reqres.op.Responses.StatusCodeResponses[status] = resp
+		reqres.setResponse(reqres.op, status, resp)
 	}()
 	return reqres.inner.Redirect(status, location)
 }
 
-func (reqres *docRequestResponse) Body(status int, v interface{}) error {
-	t := reflect.TypeOf(v)
+func (t *docTransport) setBodyResponse(op *spec.Operation, status int, typ reflect.Type) error {
+	t.addReferenceStruct(typ)
 
-	err := func() error {
-		reqres.Lock()
-		defer reqres.Unlock()
+	typeSchema, err := schema(typ, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to map type for operation request")
+	}
 
-		reqres.addReferenceStruct(t)
+	t.setResponse(op, status, spec.Response{
+		ResponseProps: spec.ResponseProps{
+			Description: http.StatusText(status),
+			Schema:      &typeSchema,
+		},
+	})
+	return nil
+}
 
-		typeSchema, err := schema(t, true)
-		if err != nil {
-			return errors.Wrap(err, "unable to map type for operation request")
-		}
+func (t *docTransport) setNoBodyResponse(op *spec.Operation, status int) {
+	t.setResponse(op, status, spec.Response{
+		ResponseProps: spec.ResponseProps{
+			Description: http.StatusText(status),
+		},
+	})
+}
 
-		resp := spec.Response{
-			ResponseProps: spec.ResponseProps{
-				Description: http.StatusText(status),
-				Schema:      &typeSchema,
+// setStreamResponse documents a streamed response as a file with its content type, adding the
+// content type to those the operation produces.
+func (t *docTransport) setStreamResponse(op *spec.Operation, status int, contentType string) {
+	description := http.StatusText(status)
+	if contentType == resttransport.EventStreamContentType {
+		description = "Server-Sent Events"
+	}
+	resp := spec.Response{
+		ResponseProps: spec.ResponseProps{
+			Description: description,
+			Schema: &spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: spec.StringOrArray([]string{"file"}),
+				},
 			},
+		},
+	}
+	resp.AddExtension(openapi.ContentTypeExtension, contentType)
+	t.setResponse(op, status, resp)
+
+	if len(op.Produces) == 0 {
+		t.changed()
+		op.Produces = append([]string(nil), t.spec.Produces...)
+	}
+	for _, p := range op.Produces {
+		if p == contentType {
+			return
 		}
+	}
+	t.changed()
+	op.Produces = append(op.Produces, contentType)
+}
 
-		reqres.op.Responses.StatusCodeResponses[status] = resp
-		return nil
+// setResponse documents the response for a status, keeping headers already documented for it.
+func (t *docTransport) setResponse(op *spec.Operation, status int, resp spec.Response) {
+	prev, ok := op.Responses.StatusCodeResponses[status]
+	for name, h := range prev.Headers {
+		if _, ok := resp.Headers[name]; ok {
+			continue
+		}
+		if resp.Headers == nil {
+			resp.Headers = map[string]spec.Header{}
+		}
+		resp.Headers[name] = h
+	}
+	if ok && reflect.DeepEqual(prev, resp) {
+		return
+	}
+	t.changed()
+	op.Responses.StatusCodeResponses[status] = resp
+}
+
+// addResponseHeader documents a header of the response for a status, adding the response if it
+// isn't documented yet.
+func (t *docTransport) addResponseHeader(op *spec.Operation, status int, name, description string) {
+	resp, ok := op.Responses.StatusCodeResponses[status]
+	if !ok {
+		resp.Description = http.StatusText(status)
+	}
+	if resp.Headers == nil {
+		resp.Headers = map[string]spec.Header{}
+	}
+	resp.Headers[name] = spec.Header{
+		SimpleSchema: spec.SimpleSchema{
+			Type: "string",
+		},
+		HeaderProps: spec.HeaderProps{
+			Description: description,
+		},
+	}
+	t.changed()
+	op.Responses.StatusCodeResponses[status] = resp
+}
+
+func (reqres *docRequestResponse) Body(status int, v interface{}) error {
+	err := func() error {
+		reqres.Lock()
+		defer reqres.Unlock()
+		return reqres.setBodyResponse(reqres.op, status, reflect.TypeOf(v))
 	}()
 	if err != nil {
 		return err
@@ -452,17 +754,62 @@ func (reqres *docRequestResponse) Body(status int, v interface{}) error {
 	return reqres.inner.Body(status, v)
 }
 
-func (reqres *docRequestResponse) NoBody(status int) error {
+func (reqres *docRequestResponse) Blob(status int, contentType string, b []byte) error {
 	resp := spec.Response{
 		ResponseProps: spec.ResponseProps{
 			Description: http.StatusText(status),
+			Schema: &spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: spec.StringOrArray([]string{"file"}),
+				},
+			},
+			Headers: map[string]spec.Header{
+				"Content-Type": spec.Header{
+					SimpleSchema: spec.SimpleSchema{
+						Type: "string",
+					},
+					HeaderProps: spec.HeaderProps{
+						Description: contentType,
+					},
+				},
+			},
 		},
 	}
+	func() {
+		reqres.Lock()
+		defer reqres.Unlock()
+		reqres.setResponse(reqres.op, status, resp)
+	}()
+	return reqres.inner.Blob(status, contentType, b)
+}
+
+func (reqres *docRequestResponse) Stream(status int, contentType string, r io.Reader) error {
+	func() {
+		reqres.Lock()
+		defer reqres.Unlock()
+		reqres.setStreamResponse(reqres.op, status, contentType)
+	}()
+	return reqres.inner.Stream(status, contentType, r)
+}
+
+func (reqres *docRequestResponse) Events(ctx context.Context) resttransport.EventSink {
+	func() {
+		reqres.Lock()
+		defer reqres.Unlock()
+		reqres.setStreamResponse(reqres.op, http.StatusOK, resttransport.EventStreamContentType)
+	}()
+	return reqres.inner.Events(ctx)
+}
 
+func (reqres *docRequestResponse) ResponseSize() int64 {
+	return reqres.inner.ResponseSize()
+}
+
+func (reqres *docRequestResponse) NoBody(status int) error {
 	func() {
 		reqres.Lock()
 		defer reqres.Unlock()
-		reqres.op.Responses.StatusCodeResponses[status] = resp
+		reqres.setNoBodyResponse(reqres.op, status)
 	}()
 
 	return reqres.inner.NoBody(status)
@@ -0,0 +1,290 @@
+package doctransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type docWidget struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+func TestRegister_DocumentsTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dt := New(testtransport.New())
+	err := resttransport.Register(dt, http.MethodPut, "/widgets/{id}", []string{"application/json"},
+		func(ctx context.Context, p struct {
+			ID string `path:"id"`
+		}, q struct {
+			DryRun bool `query:"dry_run"`
+		}, w docWidget) (docWidget, error) {
+			return w, nil
+		})
+	require.NoError(err)
+
+	// nothing has been invoked, but the operation is complete
+	swagger, err := dt.Generate()
+	require.NoError(err)
+
+	op := swagger.Paths.Paths["/widgets/{id}"].Put
+	require.NotNil(op)
+
+	params := map[string]string{}
+	for _, p := range op.Parameters {
+		params[p.Name] = p.In
+	}
+	assert.Equal(map[string]string{"id": "path", "dry_run": "query", "body": "body"}, params)
+
+	require.Contains(op.Responses.StatusCodeResponses, http.StatusOK)
+	assert.Equal("#/definitions/docWidget", op.Responses.StatusCodeResponses[http.StatusOK].Schema.Ref.String())
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusBadRequest)
+	assert.Contains(swagger.Definitions, "docWidget")
+}
+
+func TestRegisterHandler_DeclaredThenObserved(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := New(tt)
+	err := dt.RegisterHandler(http.MethodGet, "/widgets/{id}", nil,
+		func(ctx context.Context, r resttransport.RequestResponse) error {
+			q := struct {
+				Fields string `query:"fields"`
+			}{}
+			if err := r.BindQuery(&q); err != nil {
+				return err
+			}
+			return r.Redirect(http.StatusFound, "/other")
+		},
+		resttransport.PathParams(struct {
+			ID string `path:"id"`
+		}{}),
+		resttransport.Response(http.StatusOK, docWidget{}),
+		resttransport.ErrorResponses(http.StatusNotFound),
+		Summary("Get a widget"),
+		Tags("widgets"),
+	)
+	require.NoError(err)
+
+	swagger, err := dt.Generate()
+	require.NoError(err)
+	op := swagger.Paths.Paths["/widgets/{id}"].Get
+	require.NotNil(op)
+	assert.Equal("Get a widget", op.Summary)
+	assert.Equal([]string{"widgets"}, op.Tags)
+	require.Len(op.Parameters, 1)
+	assert.Equal("id", op.Parameters[0].Name)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusOK)
+	assert.Equal("#/definitions/Problem", op.Responses.StatusCodeResponses[http.StatusNotFound].Schema.Ref.String())
+	assert.NotContains(op.Responses.StatusCodeResponses, http.StatusFound)
+
+	_, err = tt.Do(http.MethodGet, "/widgets/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "1"},
+	})
+	require.NoError(err)
+
+	swagger, err = dt.Generate()
+	require.NoError(err)
+	op = swagger.Paths.Paths["/widgets/{id}"].Get
+	require.Len(op.Parameters, 2)
+	assert.Equal("fields", op.Parameters[1].Name)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusOK)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusFound)
+}
+
+func TestRequirePreconditions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dt := New(testtransport.New())
+	h := func(ctx context.Context, r resttransport.RequestResponse) error { return nil }
+	require.NoError(dt.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, h, resttransport.RequirePreconditions()))
+	require.NoError(dt.RegisterHandler(http.MethodGet, "/widgets/{id}", nil, h, resttransport.RequirePreconditions()))
+	require.NoError(dt.RegisterHandler(http.MethodDelete, "/widgets/{id}", nil, h, resttransport.CurrentVersion(func(ctx context.Context, r resttransport.RequestResponse) (resttransport.Version, error) {
+		return resttransport.Version{}, nil
+	})))
+
+	s, err := dt.Generate()
+	require.NoError(err)
+
+	put := s.Paths.Paths["/widgets/{id}"].Put
+	assert.True(hasParameter(put, "header", "If-Match"))
+	assert.True(hasParameter(put, "header", "If-Unmodified-Since"))
+	assert.Contains(put.Responses.StatusCodeResponses, http.StatusPreconditionFailed)
+	assert.Contains(put.Responses.StatusCodeResponses, http.StatusPreconditionRequired)
+
+	get := s.Paths.Paths["/widgets/{id}"].Get
+	assert.False(hasParameter(get, "header", "If-Match"))
+
+	// preconditions are checked, but not required
+	del := s.Paths.Paths["/widgets/{id}"].Delete
+	assert.True(hasParameter(del, "header", "If-Match"))
+	assert.Contains(del.Responses.StatusCodeResponses, http.StatusPreconditionFailed)
+	assert.NotContains(del.Responses.StatusCodeResponses, http.StatusPreconditionRequired)
+}
+
+func TestStreams(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := New(tt)
+	require.NoError(dt.RegisterHandler(http.MethodGet, "/jobs/{id}/events", nil,
+		func(ctx context.Context, r resttransport.RequestResponse) error {
+			return r.Events(ctx).Send(resttransport.Event{Data: "done"})
+		},
+		resttransport.StreamResponse(http.StatusOK, resttransport.EventStreamContentType),
+	))
+	require.NoError(dt.RegisterHandler(http.MethodGet, "/logs", nil,
+		func(ctx context.Context, r resttransport.RequestResponse) error {
+			return r.Stream(http.StatusOK, "text/plain", strings.NewReader("log"))
+		},
+	))
+
+	s, err := dt.Generate()
+	require.NoError(err)
+	events := s.Paths.Paths["/jobs/{id}/events"].Get
+	assert.Equal([]string{"application/json", resttransport.EventStreamContentType}, events.Produces)
+	require.Contains(events.Responses.StatusCodeResponses, http.StatusOK)
+	assert.Equal("Server-Sent Events", events.Responses.StatusCodeResponses[http.StatusOK].Description)
+	assert.NotContains(s.Paths.Paths["/logs"].Get.Responses.StatusCodeResponses, http.StatusOK)
+
+	resp, err := tt.Do(http.MethodGet, "/logs", nil)
+	require.NoError(err)
+	assert.Equal([]byte("log"), resp.Body)
+	resp, err = tt.Do(http.MethodGet, "/jobs/{id}/events", &testtransport.Request{Path: map[string]string{"id": "1"}})
+	require.NoError(err)
+	assert.Equal([]resttransport.Event{{Data: "done"}}, resp.Events)
+
+	doc, err := dt.GenerateOpenAPI()
+	require.NoError(err)
+	assert.Contains(doc.Paths["/logs"]["get"].Responses["200"].Content, "text/plain")
+	assert.Contains(doc.Paths["/jobs/{id}/events"]["get"].Responses["200"].Content, resttransport.EventStreamContentType)
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := New(tt)
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(dt, "/rooms/{id}",
+		func(ctx context.Context, conn resttransport.WebSocketConn) error {
+			q := struct {
+				Since string `query:"since"`
+			}{}
+			if err := conn.BindQuery(&q); err != nil {
+				return err
+			}
+			return conn.Write(map[string]string{"since": q.Since})
+		},
+		resttransport.PathParams(struct {
+			ID string `path:"id"`
+		}{}),
+		Summary("Join a room"),
+	))
+
+	s, err := dt.Generate()
+	require.NoError(err)
+	op := s.Paths.Paths["/rooms/{id}"].Get
+	require.NotNil(op)
+	assert.Equal("Join a room", op.Summary)
+	assert.Equal(true, op.Extensions["x-websocket"])
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusSwitchingProtocols)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusUnauthorized)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusUpgradeRequired)
+	require.Len(op.Parameters, 1)
+
+	client, err := tt.WebSocket("/rooms/{id}", &testtransport.Request{
+		Path:  map[string]string{"id": "1"},
+		Query: url.Values{"since": {"5"}},
+		User:  "alice",
+	})
+	require.NoError(err)
+	var m map[string]string
+	require.NoError(client.Receive(&m))
+	assert.Equal(map[string]string{"since": "5"}, m)
+	code, _, err := client.Wait()
+	require.NoError(err)
+	assert.Equal(resttransport.CloseNormal, code)
+
+	s, err = dt.Generate()
+	require.NoError(err)
+	op = s.Paths.Paths["/rooms/{id}"].Get
+	require.Len(op.Parameters, 2)
+	assert.Equal("since", op.Parameters[1].Name)
+
+	// endpoints the inner transport can't register aren't documented
+	plain := New(struct{ resttransport.Transport }{testtransport.New()})
+	assert.Error(resttransport.RegisterWebSocketHandler(plain, "/other", nil))
+	s, err = plain.Generate()
+	require.NoError(err)
+	assert.Nil(s.Paths)
+}
+
+func TestGenerate_Concurrent(t *testing.T) {
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := New(tt)
+	require.NoError(dt.RegisterHandler(http.MethodGet, "/widgets/{id}", nil,
+		func(ctx context.Context, r resttransport.RequestResponse) error {
+			p := struct {
+				ID string `path:"id"`
+			}{}
+			if err := r.BindPath(&p); err != nil {
+				return err
+			}
+			if p.ID == "missing" {
+				return resttransport.NewError(http.StatusNotFound, "")
+			}
+			return r.Body(http.StatusOK, docWidget{ID: p.ID})
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := strconv.Itoa(j)
+				if (i+j)%7 == 0 {
+					id = "missing"
+				}
+				_, _ = tt.Do(http.MethodGet, "/widgets/{id}", &testtransport.Request{
+					Path: map[string]string{"id": id},
+				})
+			}
+		}(i)
+	}
+	for j := 0; j < 50; j++ {
+		swagger, err := dt.Generate()
+		require.NoError(err)
+		// the returned spec is a copy, safe to use while handlers document traffic
+		_, err = json.Marshal(swagger)
+		require.NoError(err)
+	}
+	wg.Wait()
+
+	swagger, err := dt.Generate()
+	require.NoError(err)
+	op := swagger.Paths.Paths["/widgets/{id}"].Get
+	require.Contains(op.Responses.StatusCodeResponses, http.StatusOK)
+	require.Contains(op.Responses.StatusCodeResponses, http.StatusNotFound)
+}
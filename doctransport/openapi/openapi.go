@@ -0,0 +1,655 @@
+// Package openapi converts the Swagger 2.0 documents generated by doctransport into OpenAPI 3.1.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/jsonreference"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// JSONSchemaDialect is the default dialect for schemas in generated documents.
+const JSONSchemaDialect = "https://spec.openapis.org/oas/3.1/dialect/base"
+
+// NullableExtension is the Swagger 2.0 vendor extension doctransport uses to mark nullable
+// (pointer) fields.
+const NullableExtension = "x-nullable"
+
+// ContentTypeExtension is the Swagger 2.0 vendor extension doctransport uses for the media type of
+// a streamed response, which 2.0 can only describe as a file.
+const ContentTypeExtension = "x-content-type"
+
+// WebSocketExtension is the Swagger 2.0 vendor extension doctransport uses to mark the operations
+// that upgrade to a WebSocket connection.
+const WebSocketExtension = "x-websocket"
+
+// Swagger 2.0 vendor extensions doctransport uses for security schemes 2.0 can't express. They hold
+// the OpenAPI 3.1 scheme (`bearer`), bearer format, API key location (`cookie`) and name, and
+// OAuthFlows.
+const (
+	SchemeExtension       = "x-scheme"
+	BearerFormatExtension = "x-bearer-format"
+	InExtension           = "x-in"
+	NameExtension         = "x-name"
+	FlowsExtension        = "x-flows"
+)
+
+const (
+	definitionsPrefix = "#/definitions/"
+	schemasPrefix     = "#/components/schemas/"
+
+	problemRef = definitionsPrefix + "Problem"
+)
+
+// Document is an OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI           string              `json:"openapi"`
+	Info              *spec.Info          `json:"info,omitempty"`
+	JSONSchemaDialect string              `json:"jsonSchemaDialect,omitempty"`
+	Paths             map[string]PathItem `json:"paths"`
+	Components        Components          `json:"components"`
+}
+
+// PathItem holds the operations of a path keyed by lower case HTTP method.
+type PathItem map[string]*Operation
+
+// Operation is a single API operation on a path.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query, header or cookie parameter.
+type Parameter struct {
+	Name        string       `json:"name"`
+	In          string       `json:"in"`
+	Description string       `json:"description,omitempty"`
+	Required    bool         `json:"required,omitempty"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes the request body per content type.
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema for a single content type.
+type MediaType struct {
+	Schema *spec.Schema `json:"schema,omitempty"`
+}
+
+// Response describes a single response.
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a single response header.
+type Header struct {
+	Description string       `json:"description,omitempty"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+// Components holds the reusable schemas and security schemes of a document.
+type Components struct {
+	Schemas         map[string]spec.Schema    `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes an authentication mechanism.
+type SecurityScheme struct {
+	Type         string      `json:"type"`
+	Description  string      `json:"description,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	In           string      `json:"in,omitempty"`
+	Scheme       string      `json:"scheme,omitempty"`
+	BearerFormat string      `json:"bearerFormat,omitempty"`
+	Flows        *OAuthFlows `json:"flows,omitempty"`
+}
+
+// OAuthFlows holds the supported OAuth2 flows of a security scheme.
+type OAuthFlows struct {
+	Implicit          *OAuthFlow `json:"implicit,omitempty"`
+	Password          *OAuthFlow `json:"password,omitempty"`
+	ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+// OAuthFlow describes a single OAuth2 flow.
+type OAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	RefreshURL       string            `json:"refreshUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// methods are the Swagger 2.0 operations in document order.
+var methods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+}
+
+func operation(pi spec.PathItem, httpMethod string) *spec.Operation {
+	switch httpMethod {
+	case http.MethodDelete:
+		return pi.Delete
+	case http.MethodGet:
+		return pi.Get
+	case http.MethodHead:
+		return pi.Head
+	case http.MethodOptions:
+		return pi.Options
+	case http.MethodPatch:
+		return pi.Patch
+	case http.MethodPost:
+		return pi.Post
+	case http.MethodPut:
+		return pi.Put
+	}
+	return nil
+}
+
+// FromSwagger converts a Swagger 2.0 document into OpenAPI 3.1.
+func FromSwagger(s *spec.Swagger) (*Document, error) {
+	doc := &Document{
+		OpenAPI:           Version,
+		Info:              s.Info,
+		JSONSchemaDialect: JSONSchemaDialect,
+		Paths:             map[string]PathItem{},
+	}
+
+	if len(s.Definitions) > 0 {
+		doc.Components.Schemas = map[string]spec.Schema{}
+		for name, sch := range s.Definitions {
+			converted, err := convertSchema(&sch)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to convert definition %s", name)
+			}
+			doc.Components.Schemas[name] = *converted
+		}
+	}
+
+	if len(s.SecurityDefinitions) > 0 {
+		doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+		for name, ss := range s.SecurityDefinitions {
+			doc.Components.SecuritySchemes[name] = convertSecurityScheme(name, ss)
+		}
+	}
+
+	if s.Paths == nil {
+		return doc, nil
+	}
+
+	for path, pi := range s.Paths.Paths {
+		item := PathItem{}
+		for _, m := range methods {
+			op := operation(pi, m)
+			if op == nil {
+				continue
+			}
+			converted, err := convertOperation(s, op)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to convert operation %s %s", m, path)
+			}
+			item[strings.ToLower(m)] = converted
+		}
+		doc.Paths[path] = item
+	}
+
+	return doc, nil
+}
+
+// nolint: gocyclo
+func convertSecurityScheme(name string, ss *spec.SecurityScheme) SecurityScheme {
+	switch ss.Type {
+	case "apiKey":
+		if scheme, _ := ss.Extensions.GetString(SchemeExtension); scheme != "" {
+			bearerFormat, _ := ss.Extensions.GetString(BearerFormatExtension)
+			return SecurityScheme{
+				Type:         "http",
+				Description:  ss.Description,
+				Scheme:       scheme,
+				BearerFormat: bearerFormat,
+			}
+		}
+		if in, _ := ss.Extensions.GetString(InExtension); in != "" {
+			name, _ := ss.Extensions.GetString(NameExtension)
+			return SecurityScheme{
+				Type:        "apiKey",
+				Description: ss.Description,
+				Name:        name,
+				In:          in,
+			}
+		}
+		if ss.In == "header" && ss.Name == "Authorization" && strings.EqualFold(name, "bearer") {
+			// doctransport documents bearer tokens as an Authorization header API key in 2.0
+			return SecurityScheme{
+				Type:        "http",
+				Description: ss.Description,
+				Scheme:      "bearer",
+			}
+		}
+		return SecurityScheme{
+			Type:        "apiKey",
+			Description: ss.Description,
+			Name:        ss.Name,
+			In:          ss.In,
+		}
+	case "basic":
+		return SecurityScheme{
+			Type:        "http",
+			Description: ss.Description,
+			Scheme:      "basic",
+		}
+	case "oauth2":
+		if flows, ok := extensionFlows(ss); ok {
+			return SecurityScheme{
+				Type:        "oauth2",
+				Description: ss.Description,
+				Flows:       flows,
+			}
+		}
+		flow := &OAuthFlow{
+			AuthorizationURL: ss.AuthorizationURL,
+			TokenURL:         ss.TokenURL,
+			Scopes:           ss.Scopes,
+		}
+		if flow.Scopes == nil {
+			flow.Scopes = map[string]string{}
+		}
+		flows := &OAuthFlows{}
+		switch ss.Flow {
+		case "implicit":
+			flows.Implicit = flow
+		case "password":
+			flows.Password = flow
+		case "application":
+			flows.ClientCredentials = flow
+		case "accessCode":
+			flows.AuthorizationCode = flow
+		}
+		return SecurityScheme{
+			Type:        "oauth2",
+			Description: ss.Description,
+			Flows:       flows,
+		}
+	}
+	return SecurityScheme{
+		Type:        ss.Type,
+		Description: ss.Description,
+	}
+}
+
+// extensionFlows reads FlowsExtension, which is an OAuthFlows, or its JSON form once the document
+// has been marshaled.
+func extensionFlows(ss *spec.SecurityScheme) (*OAuthFlows, bool) {
+	v, ok := ss.Extensions[FlowsExtension]
+	if !ok {
+		return nil, false
+	}
+	if flows, ok := v.(*OAuthFlows); ok {
+		return flows, true
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	flows := &OAuthFlows{}
+	if err := json.Unmarshal(raw, flows); err != nil {
+		return nil, false
+	}
+	return flows, true
+}
+
+func consumes(s *spec.Swagger, op *spec.Operation) []string {
+	if len(op.Consumes) > 0 {
+		return op.Consumes
+	}
+	if len(s.Consumes) > 0 {
+		return s.Consumes
+	}
+	return []string{"application/json"}
+}
+
+func produces(s *spec.Swagger, op *spec.Operation) []string {
+	if len(op.Produces) > 0 {
+		return op.Produces
+	}
+	if len(s.Produces) > 0 {
+		return s.Produces
+	}
+	return []string{"application/json"}
+}
+
+func content(contentTypes []string, sch *spec.Schema) map[string]MediaType {
+	c := map[string]MediaType{}
+	for _, ct := range contentTypes {
+		c[ct] = MediaType{Schema: sch}
+	}
+	return c
+}
+
+func binarySchema() *spec.Schema {
+	return &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:   []string{"string"},
+			Format: "binary",
+		},
+	}
+}
+
+// nolint: gocyclo
+func convertOperation(s *spec.Swagger, op *spec.Operation) (*Operation, error) {
+	converted := &Operation{
+		OperationID: op.ID,
+		Description: op.Description,
+		Security:    op.Security,
+		Responses:   map[string]Response{},
+	}
+
+	var files []string
+	for _, p := range op.Parameters {
+		switch {
+		case p.Type == "file":
+			files = append(files, p.Name)
+		case p.In == "body":
+			sch, err := convertSchema(p.Schema)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to convert body schema")
+			}
+			converted.RequestBody = &RequestBody{
+				Description: p.Description,
+				Required:    p.Required,
+				Content:     content(consumes(s, op), sch),
+			}
+		default:
+			converted.Parameters = append(converted.Parameters, Parameter{
+				Name:        p.Name,
+				In:          p.In,
+				Description: p.Description,
+				Required:    p.Required || p.In == "path",
+				Schema:      simpleSchema(p.SimpleSchema, p.CommonValidations),
+			})
+		}
+	}
+
+	if len(files) > 0 && converted.RequestBody == nil {
+		sort.Strings(files)
+		props := map[string]spec.Schema{}
+		for _, f := range files {
+			props[f] = *binarySchema()
+		}
+		converted.RequestBody = &RequestBody{
+			Required: true,
+			Content: content([]string{"multipart/form-data"}, &spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type:       []string{"object"},
+					Properties: props,
+				},
+			}),
+		}
+	}
+
+	if op.Responses == nil {
+		return converted, nil
+	}
+
+	if op.Responses.Default != nil {
+		resp, err := convertResponse(s, op, *op.Responses.Default)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert default response")
+		}
+		converted.Responses["default"] = resp
+	}
+
+	for status, r := range op.Responses.StatusCodeResponses {
+		resp, err := convertResponse(s, op, r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert response %d", status)
+		}
+		converted.Responses[strconv.Itoa(status)] = resp
+	}
+
+	return converted, nil
+}
+
+func convertResponse(s *spec.Swagger, op *spec.Operation, r spec.Response) (Response, error) {
+	resp := Response{
+		Description: r.Description,
+	}
+
+	if len(r.Headers) > 0 {
+		resp.Headers = map[string]Header{}
+		for name, h := range r.Headers {
+			if name == "Content-Type" {
+				// described by the content map
+				continue
+			}
+			resp.Headers[name] = Header{
+				Description: h.Description,
+				Schema:      simpleSchema(h.SimpleSchema, h.CommonValidations),
+			}
+		}
+	}
+
+	if r.Schema == nil {
+		return resp, nil
+	}
+
+	if r.Schema.Type.Contains("file") {
+		contentType, _ := r.Extensions.GetString(ContentTypeExtension)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		resp.Content = content([]string{contentType}, binarySchema())
+		return resp, nil
+	}
+
+	sch, err := convertSchema(r.Schema)
+	if err != nil {
+		return Response{}, err
+	}
+	if r.Schema.Ref.String() == problemRef {
+		resp.Content = content([]string{resttransport.ProblemContentType}, sch)
+		return resp, nil
+	}
+	resp.Content = content(produces(s, op), sch)
+	return resp, nil
+}
+
+func simpleSchema(ss spec.SimpleSchema, cv spec.CommonValidations) *spec.Schema {
+	if ss.Type == "" {
+		return nil
+	}
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:             []string{ss.Type},
+			Format:           ss.Format,
+			Default:          ss.Default,
+			Maximum:          cv.Maximum,
+			ExclusiveMaximum: cv.ExclusiveMaximum,
+			Minimum:          cv.Minimum,
+			ExclusiveMinimum: cv.ExclusiveMinimum,
+			MaxLength:        cv.MaxLength,
+			MinLength:        cv.MinLength,
+			Pattern:          cv.Pattern,
+			MaxItems:         cv.MaxItems,
+			MinItems:         cv.MinItems,
+			UniqueItems:      cv.UniqueItems,
+			MultipleOf:       cv.MultipleOf,
+			Enum:             cv.Enum,
+		},
+	}
+	if ss.Items != nil {
+		sch.Items = &spec.SchemaOrArray{
+			Schema: simpleSchema(ss.Items.SimpleSchema, ss.Items.CommonValidations),
+		}
+	}
+	exclusiveBounds(sch)
+	return sch
+}
+
+// exclusiveBounds rewrites the Swagger 2.0 boolean exclusiveMaximum and exclusiveMinimum, which
+// qualify maximum and minimum, into the JSON Schema 2020-12 keywords holding the bound itself.
+func exclusiveBounds(sch *spec.Schema) {
+	bound := func(exclusive *bool, limit **float64, keyword string) {
+		if !*exclusive {
+			return
+		}
+		*exclusive = false
+		if *limit == nil {
+			return
+		}
+		extra := map[string]interface{}{}
+		for k, v := range sch.ExtraProps {
+			extra[k] = v
+		}
+		extra[keyword] = **limit
+		sch.ExtraProps = extra
+		*limit = nil
+	}
+	bound(&sch.ExclusiveMaximum, &sch.Maximum, "exclusiveMaximum")
+	bound(&sch.ExclusiveMinimum, &sch.Minimum, "exclusiveMinimum")
+}
+
+func convertRef(ref spec.Ref) (spec.Ref, error) {
+	s := ref.String()
+	if !strings.HasPrefix(s, definitionsPrefix) {
+		return ref, nil
+	}
+	r, err := jsonreference.New(schemasPrefix + strings.TrimPrefix(s, definitionsPrefix))
+	if err != nil {
+		return spec.Ref{}, errors.Wrap(err, "unable to create json ref")
+	}
+	return spec.Ref{Ref: r}, nil
+}
+
+// convertSchema copies a Swagger 2.0 schema into its JSON Schema 2020-12 form, rewriting
+// references to components, nullable extensions to `null` types and exclusive bounds to numbers.
+// nolint: gocyclo
+func convertSchema(in *spec.Schema) (*spec.Schema, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	out := *in
+	out.VendorExtensible = spec.VendorExtensible{}
+	nullable := false
+	for k, v := range in.Extensions {
+		if k == NullableExtension {
+			nullable, _ = v.(bool)
+			continue
+		}
+		out.AddExtension(k, v)
+	}
+
+	if in.Ref.String() != "" {
+		ref, err := convertRef(in.Ref)
+		if err != nil {
+			return nil, err
+		}
+		out.Ref = ref
+	}
+
+	if in.Type.Contains("file") {
+		out.Type = []string{"string"}
+		out.Format = "binary"
+	}
+
+	exclusiveBounds(&out)
+
+	if in.Items != nil {
+		out.Items = &spec.SchemaOrArray{}
+		if in.Items.Schema != nil {
+			items, err := convertSchema(in.Items.Schema)
+			if err != nil {
+				return nil, err
+			}
+			out.Items.Schema = items
+		}
+		for i := range in.Items.Schemas {
+			items, err := convertSchema(&in.Items.Schemas[i])
+			if err != nil {
+				return nil, err
+			}
+			out.Items.Schemas = append(out.Items.Schemas, *items)
+		}
+	}
+
+	if in.Properties != nil {
+		out.Properties = map[string]spec.Schema{}
+		for name, p := range in.Properties {
+			converted, err := convertSchema(&p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to convert property %s", name)
+			}
+			out.Properties[name] = *converted
+		}
+	}
+
+	if in.AdditionalProperties != nil && in.AdditionalProperties.Schema != nil {
+		ap, err := convertSchema(in.AdditionalProperties.Schema)
+		if err != nil {
+			return nil, err
+		}
+		out.AdditionalProperties = &spec.SchemaOrBool{Allows: true, Schema: ap}
+	}
+
+	for _, list := range []*[]spec.Schema{&out.AllOf, &out.AnyOf, &out.OneOf} {
+		if *list == nil {
+			continue
+		}
+		converted := make([]spec.Schema, 0, len(*list))
+		for i := range *list {
+			c, err := convertSchema(&(*list)[i])
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, *c)
+		}
+		*list = converted
+	}
+
+	if !nullable {
+		return &out, nil
+	}
+
+	if out.Ref.String() != "" {
+		// add null alongside the reference rather than altering the referenced schema
+		return &spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				AnyOf: []spec.Schema{
+					out,
+					{SchemaProps: spec.SchemaProps{Type: []string{"null"}}},
+				},
+			},
+		}, nil
+	}
+
+	if len(out.Type) > 0 && !out.Type.Contains("null") {
+		out.Type = append(append(spec.StringOrArray{}, out.Type...), "null")
+	}
+	return &out, nil
+}
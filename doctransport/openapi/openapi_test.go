@@ -0,0 +1,129 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/doctransport/openapi"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type Toy struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Child struct {
+	Name        string  `json:"name"`
+	Nickname    *string `json:"nickname"`
+	FavoriteToy *Toy    `json:"favoriteToy"`
+}
+
+func TestFromSwagger(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := doctransport.New(tt)
+
+	require.NoError(dt.RegisterAuthenticatedHandler(http.MethodPut, "/children/{id}", []string{"application/json", "application/xml"}, func(ctx context.Context, r resttransport.RequestResponse) error {
+		pathParams := struct {
+			ID string `path:"id"`
+		}{}
+		if err := r.BindPath(&pathParams); err != nil {
+			return err
+		}
+		child := Child{}
+		if err := r.BindBody(&child); err != nil {
+			return err
+		}
+		return r.Body(http.StatusOK, child)
+	}))
+
+	resp, err := tt.Do(http.MethodPut, "/children/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "1"},
+		Body: Child{Name: "bob"},
+		User: "user",
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK)
+
+	doc, err := dt.GenerateOpenAPI()
+	require.NoError(err)
+
+	assert.Equal("3.1.0", doc.OpenAPI)
+	assert.Equal("http", doc.Components.SecuritySchemes["Bearer"].Type)
+	assert.Equal("bearer", doc.Components.SecuritySchemes["Bearer"].Scheme)
+
+	op := doc.Paths["/children/{id}"]["put"]
+	require.NotNil(op)
+	assert.Equal("updateChildren", op.OperationID)
+	require.Len(op.Parameters, 1)
+	assert.Equal("path", op.Parameters[0].In)
+	assert.True(op.Parameters[0].Required)
+
+	require.NotNil(op.RequestBody)
+	assert.Len(op.RequestBody.Content, 2)
+	assert.Equal("#/components/schemas/Child", op.RequestBody.Content["application/xml"].Schema.Ref.String())
+	assert.Contains(op.Responses, "200")
+	assert.Contains(op.Responses, "400")
+	assert.Contains(op.Responses, "default")
+	unauthorized := op.Responses["401"]
+	assert.Equal("#/components/schemas/Problem", unauthorized.Content["application/problem+json"].Schema.Ref.String())
+
+	child := doc.Components.Schemas["Child"]
+	assert.Equal(spec.StringOrArray{"string"}, child.Properties["name"].Type)
+	assert.Equal(spec.StringOrArray{"string", "null"}, child.Properties["nickname"].Type)
+	assert.Empty(child.Properties["nickname"].Extensions)
+
+	toy := child.Properties["favoriteToy"]
+	require.Len(toy.AnyOf, 2)
+	assert.Equal("#/components/schemas/Toy", toy.AnyOf[0].Ref.String())
+	assert.Equal(spec.StringOrArray{"null"}, toy.AnyOf[1].Type)
+}
+
+func TestFromSwagger_ExclusiveBounds(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	param := spec.QueryParam("ratio").Typed("number", "")
+	param.WithMinimum(0, true).WithMaximum(1, false)
+	body := spec.BodyParam("body", spec.Float64Property().WithMaximum(10, true))
+	s := &spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Paths: &spec.Paths{
+				Paths: map[string]spec.PathItem{
+					"/ratios": {PathItemProps: spec.PathItemProps{
+						Post: &spec.Operation{OperationProps: spec.OperationProps{
+							Parameters: []spec.Parameter{*param, *body},
+							Responses:  &spec.Responses{},
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	doc, err := openapi.FromSwagger(s)
+	require.NoError(err)
+	op := doc.Paths["/ratios"]["post"]
+	require.NotNil(op)
+	require.Len(op.Parameters, 1)
+
+	b, err := json.Marshal(op.Parameters[0].Schema)
+	require.NoError(err)
+	assert.JSONEq(`{"type": "number", "exclusiveMinimum": 0, "maximum": 1}`, string(b))
+
+	b, err = json.Marshal(op.RequestBody.Content["application/json"].Schema)
+	require.NoError(err)
+	assert.JSONEq(`{"type": "number", "format": "double", "exclusiveMaximum": 10}`, string(b))
+}
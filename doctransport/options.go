@@ -0,0 +1,42 @@
+package doctransport
+
+import (
+	"github.com/go-openapi/spec"
+
+	"github.com/paultyng/resttransport"
+)
+
+type optionKey int
+
+const (
+	summaryKey optionKey = iota
+	descriptionKey
+	tagsKey
+)
+
+// Summary sets the summary of the documented operation.
+func Summary(summary string) resttransport.RouteOption {
+	return resttransport.WithRouteValue(summaryKey, summary)
+}
+
+// Description sets the description of the documented operation.
+func Description(description string) resttransport.RouteOption {
+	return resttransport.WithRouteValue(descriptionKey, description)
+}
+
+// Tags sets the tags used to group the documented operation.
+func Tags(tags ...string) resttransport.RouteOption {
+	return resttransport.WithRouteValue(tagsKey, tags)
+}
+
+func applyOperationOptions(op *spec.Operation, r *resttransport.Route) {
+	if v, ok := r.Value(summaryKey).(string); ok {
+		op.Summary = v
+	}
+	if v, ok := r.Value(descriptionKey).(string); ok {
+		op.Description = v
+	}
+	if v, ok := r.Value(tagsKey).([]string); ok {
+		op.Tags = v
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"github.com/go-openapi/jsonreference"
 	"github.com/go-openapi/spec"
 	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport/openapi"
+	"github.com/paultyng/resttransport/validate"
 )
 
 const bearerTokenAuthorizationName = "Bearer"
@@ -14,6 +18,8 @@ const bearerTokenAuthorizationName = "Bearer"
 // well known types
 var (
 	wkTime = reflect.TypeOf(time.Time{})
+
+	problemType = reflect.TypeOf(resttransport.Problem{})
 )
 
 func primitiveSchema(jsonSchemaType, format string) (spec.Schema, error) {
@@ -59,7 +65,23 @@ func eachStructField(t reflect.Type, tagName string, cb func(name string, requir
 					return errors.Wrapf(err, "unable to get schema for field %s", f.Name)
 				}
 
-				err = cb(name, f.Type.Kind() != reflect.Ptr, sch)
+				rules, err := validate.ParseTag(f.Tag.Get(validate.TagName))
+				if err != nil {
+					return errors.Wrapf(err, "unable to parse validation for field %s", f.Name)
+				}
+				err = applyValidation(&sch, f.Type, rules)
+				if err != nil {
+					return errors.Wrapf(err, "unable to document validation for field %s", f.Name)
+				}
+
+				nullable := f.Type.Kind() == reflect.Ptr
+				if nullable {
+					sch.AddExtension(openapi.NullableExtension, true)
+				}
+
+				_, required := rules.Has(validate.Required)
+				_, omitEmpty := rules.Has(validate.OmitEmpty)
+				err = cb(name, (!nullable && !omitEmpty) || required, sch)
 				if err != nil {
 					return err
 				}
@@ -137,6 +159,27 @@ func arraySchema(t reflect.Type, ref bool) (spec.Schema, error) {
 	}, nil
 }
 
+func mapSchema(t reflect.Type, ref bool) (spec.Schema, error) {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return spec.Schema{}, errors.Errorf("%s is not a map with string keys", t.Name())
+	}
+
+	values, err := schema(t.Elem(), ref)
+	if err != nil {
+		return spec.Schema{}, errors.Wrapf(err, "unable to determine values for %s", t.Name())
+	}
+
+	return spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type: []string{"object"},
+			AdditionalProperties: &spec.SchemaOrBool{
+				Allows: true,
+				Schema: &values,
+			},
+		},
+	}, nil
+}
+
 // nolint: gocyclo
 func schema(t reflect.Type, ref bool) (spec.Schema, error) {
 	switch t.Kind() {
@@ -166,6 +209,8 @@ func schema(t reflect.Type, ref bool) (spec.Schema, error) {
 	case reflect.Array,
 		reflect.Slice:
 		return arraySchema(t, ref)
+	case reflect.Map:
+		return mapSchema(t, ref)
 	case reflect.Struct:
 		if t == wkTime {
 			return primitiveSchema("string", "date-time")
@@ -143,3 +143,46 @@ func TestSchema(t *testing.T) {
 // 	assert.True(structs[reflect.TypeOf(Child{})], "has Child")
 // 	assert.False(structs[reflect.TypeOf(time.Time{})], "does not have time.Time")
 // }
+
+func TestStructSchema_Validation(t *testing.T) {
+	assert := require.New(t)
+
+	type Order struct {
+		ID       string            `json:"id" validate:"uuid"`
+		Quantity int               `json:"quantity" validate:"min=1,max=10"`
+		Status   string            `json:"status" validate:"enum=open|closed"`
+		Code     *string           `json:"code" validate:"required,len=3,pattern=^[A-Z]+$"`
+		Sizes    []int             `json:"sizes" validate:"max=3,dive,enum=1|2"`
+		Labels   map[string]string `json:"labels" validate:"dive,min=1"`
+		Coupon   string            `json:"coupon" validate:"omitempty,len=8"`
+	}
+
+	schema, err := structSchema(reflect.TypeOf(Order{}))
+	assert.NoError(err)
+	assert.Contains(schema.Required, "code")
+	assert.Contains(schema.Required, "status")
+	assert.NotContains(schema.Required, "coupon")
+
+	id := schema.Properties["id"]
+	assert.Equal("uuid", id.Format)
+
+	quantity := schema.Properties["quantity"]
+	assert.Equal(1.0, *quantity.Minimum)
+	assert.Equal(10.0, *quantity.Maximum)
+
+	status := schema.Properties["status"]
+	assert.Equal([]interface{}{"open", "closed"}, status.Enum)
+
+	code := schema.Properties["code"]
+	assert.Equal(int64(3), *code.MinLength)
+	assert.Equal(int64(3), *code.MaxLength)
+	assert.Equal("^[A-Z]+$", code.Pattern)
+
+	sizes := schema.Properties["sizes"]
+	assert.Equal(int64(3), *sizes.MaxItems)
+	assert.Equal([]interface{}{int64(1), int64(2)}, sizes.Items.Schema.Enum)
+
+	labels := schema.Properties["labels"]
+	assert.Equal(spec.StringOrArray([]string{"object"}), labels.Type)
+	assert.Equal(int64(1), *labels.AdditionalProperties.Schema.MinLength)
+}
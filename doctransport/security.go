@@ -0,0 +1,136 @@
+package doctransport
+
+import (
+	"net/http"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport/openapi"
+)
+
+// defaultBearerScheme documents handlers registered as authenticated without declaring security.
+var defaultBearerScheme = &resttransport.SecurityScheme{
+	Name:        bearerTokenAuthorizationName,
+	Type:        resttransport.BearerAuth,
+	Description: "Requires a bearer token",
+}
+
+// declareSecurity documents the security requirements of an operation, collecting their schemes
+// for the security definitions. A scheme name can only be used for one scheme.
+func (t *docTransport) declareSecurity(op *spec.Operation, reqs []resttransport.SecurityRequirement) error {
+	op.Security = nil
+	scoped := false
+	for _, req := range reqs {
+		if existing, ok := t.securitySchemes[req.Scheme.Name]; ok && existing != req.Scheme {
+			return errors.Errorf("security scheme '%s' is declared more than once", req.Scheme.Name)
+		}
+		t.securitySchemes[req.Scheme.Name] = req.Scheme
+
+		scopes := req.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		scoped = scoped || len(scopes) > 0
+		op.Security = append(op.Security, map[string][]string{req.Scheme.Name: scopes})
+	}
+
+	t.addProblemResponse(op, http.StatusUnauthorized)
+	if scoped {
+		t.addProblemResponse(op, http.StatusForbidden)
+	}
+	return nil
+}
+
+func (t *docTransport) securityDefinitions() spec.SecurityDefinitions {
+	defs := spec.SecurityDefinitions{}
+	for name, s := range t.securitySchemes {
+		defs[name] = securityScheme(s)
+	}
+	return defs
+}
+
+// securityScheme converts a scheme to a Swagger 2.0 security definition. What 2.0 can't express,
+// bearer tokens, API keys in cookies and more than one OAuth2 flow, is kept in vendor extensions
+// so it survives conversion to OpenAPI 3.1.
+func securityScheme(s *resttransport.SecurityScheme) *spec.SecurityScheme {
+	var ss *spec.SecurityScheme
+	switch s.Type {
+	case resttransport.BasicAuth:
+		ss = spec.BasicAuth()
+	case resttransport.BearerAuth:
+		ss = spec.APIKeyAuth("Authorization", "header")
+		ss.AddExtension(openapi.SchemeExtension, "bearer")
+		if s.BearerFormat != "" {
+			ss.AddExtension(openapi.BearerFormatExtension, s.BearerFormat)
+		}
+	case resttransport.APIKeyAuth:
+		if s.In == resttransport.APIKeyInCookie {
+			ss = spec.APIKeyAuth("Cookie", "header")
+			ss.AddExtension(openapi.InExtension, resttransport.APIKeyInCookie)
+			ss.AddExtension(openapi.NameExtension, s.ParamName)
+			break
+		}
+		ss = spec.APIKeyAuth(s.ParamName, s.In)
+	case resttransport.OAuth2:
+		ss = oauth2Scheme(s.Flows)
+	default:
+		ss = &spec.SecurityScheme{SecuritySchemeProps: spec.SecuritySchemeProps{Type: string(s.Type)}}
+	}
+	ss.Description = s.Description
+	return ss
+}
+
+var swaggerFlows = map[resttransport.OAuthFlowType]string{
+	resttransport.ImplicitFlow:          "implicit",
+	resttransport.PasswordFlow:          "password",
+	resttransport.ClientCredentialsFlow: "application",
+	resttransport.AuthorizationCodeFlow: "accessCode",
+}
+
+func oauth2Scheme(flows []resttransport.OAuthFlow) *spec.SecurityScheme {
+	ss := &spec.SecurityScheme{SecuritySchemeProps: spec.SecuritySchemeProps{Type: "oauth2"}}
+	if len(flows) == 0 {
+		return ss
+	}
+
+	// 2.0 has a single flow per scheme, the first is documented and all of them are in the extension
+	first := flows[0]
+	ss.Flow = swaggerFlows[first.Type]
+	ss.AuthorizationURL = first.AuthorizationURL
+	ss.TokenURL = first.TokenURL
+	ss.Scopes = map[string]string{}
+	for _, f := range flows {
+		for scope, desc := range f.Scopes {
+			ss.Scopes[scope] = desc
+		}
+	}
+
+	if len(flows) > 1 || first.RefreshURL != "" {
+		all := &openapi.OAuthFlows{}
+		for _, f := range flows {
+			flow := &openapi.OAuthFlow{
+				AuthorizationURL: f.AuthorizationURL,
+				TokenURL:         f.TokenURL,
+				RefreshURL:       f.RefreshURL,
+				Scopes:           f.Scopes,
+			}
+			if flow.Scopes == nil {
+				flow.Scopes = map[string]string{}
+			}
+			switch f.Type {
+			case resttransport.ImplicitFlow:
+				all.Implicit = flow
+			case resttransport.PasswordFlow:
+				all.Password = flow
+			case resttransport.ClientCredentialsFlow:
+				all.ClientCredentials = flow
+			case resttransport.AuthorizationCodeFlow:
+				all.AuthorizationCode = flow
+			}
+		}
+		ss.AddExtension(openapi.FlowsExtension, all)
+	}
+	return ss
+}
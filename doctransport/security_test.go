@@ -0,0 +1,78 @@
+package doctransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func TestSecurity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	jwt := &resttransport.SecurityScheme{Name: "jwt", Type: resttransport.BearerAuth, BearerFormat: "JWT"}
+	session := &resttransport.SecurityScheme{Name: "session", Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInCookie, ParamName: "sid"}
+	oauth := &resttransport.SecurityScheme{
+		Name: "oauth",
+		Type: resttransport.OAuth2,
+		Flows: []resttransport.OAuthFlow{
+			{Type: resttransport.AuthorizationCodeFlow, AuthorizationURL: "https://auth/authorize", TokenURL: "https://auth/token", Scopes: map[string]string{"widgets:write": "Modify widgets"}},
+			{Type: resttransport.ClientCredentialsFlow, TokenURL: "https://auth/token", Scopes: map[string]string{"widgets:write": "Modify widgets"}},
+		},
+	}
+
+	h := func(ctx context.Context, r resttransport.RequestResponse) error { return nil }
+	dt := New(testtransport.New())
+	require.NoError(dt.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, h,
+		resttransport.Security(jwt, "widgets:write"),
+		resttransport.Security(oauth, "widgets:write"),
+		resttransport.Security(session),
+	))
+	require.NoError(dt.RegisterHandler(http.MethodGet, "/widgets", nil, h))
+
+	s, err := dt.Generate()
+	require.NoError(err)
+
+	op := s.Paths.Paths["/widgets/{id}"].Put
+	assert.Equal([]map[string][]string{
+		{"jwt": {"widgets:write"}},
+		{"oauth": {"widgets:write"}},
+		{"session": {}},
+	}, op.Security)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusUnauthorized)
+	assert.Contains(op.Responses.StatusCodeResponses, http.StatusForbidden)
+	assert.Empty(s.Paths.Paths["/widgets"].Get.Security)
+
+	// only declared schemes are documented
+	require.Len(s.SecurityDefinitions, 3)
+	assert.Equal("oauth2", s.SecurityDefinitions["oauth"].Type)
+	assert.Equal("accessCode", s.SecurityDefinitions["oauth"].Flow)
+
+	doc, err := dt.GenerateOpenAPI()
+	require.NoError(err)
+	schemes := doc.Components.SecuritySchemes
+	assert.Equal("http", schemes["jwt"].Type)
+	assert.Equal("bearer", schemes["jwt"].Scheme)
+	assert.Equal("JWT", schemes["jwt"].BearerFormat)
+	assert.Equal("cookie", schemes["session"].In)
+	assert.Equal("sid", schemes["session"].Name)
+	require.NotNil(schemes["oauth"].Flows)
+	assert.Equal("https://auth/authorize", schemes["oauth"].Flows.AuthorizationCode.AuthorizationURL)
+	assert.Equal("https://auth/token", schemes["oauth"].Flows.ClientCredentials.TokenURL)
+
+	// authenticated handlers without declared security keep the bearer token scheme
+	require.NoError(dt.RegisterAuthenticatedHandler(http.MethodDelete, "/widgets/{id}", nil, h))
+	s, err = dt.Generate()
+	require.NoError(err)
+	assert.Contains(s.SecurityDefinitions, "Bearer")
+	assert.Equal([]map[string][]string{{"Bearer": {}}}, s.Paths.Paths["/widgets/{id}"].Delete.Security)
+
+	other := &resttransport.SecurityScheme{Name: "jwt", Type: resttransport.BasicAuth}
+	assert.Error(dt.RegisterHandler(http.MethodPost, "/widgets", nil, h, resttransport.Security(other)))
+}
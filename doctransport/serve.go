@@ -0,0 +1,256 @@
+package doctransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/paultyng/resttransport"
+)
+
+// ServeConfig holds configuration for Serve.
+type ServeConfig struct {
+	// Prefix is prepended to the registered paths, for example `/api`.
+	Prefix string
+	// Title is the title of the documentation page, defaults to `API Documentation`.
+	Title string
+}
+
+// uiAssets are the files of the embedded Swagger UI that are served.
+var uiAssets = map[string]bool{
+	"swagger-ui.css":                  true,
+	"swagger-ui-bundle.js":            true,
+	"swagger-ui-standalone-preset.js": true,
+	"favicon-16x16.png":               true,
+	"favicon-32x32.png":               true,
+	"oauth2-redirect.html":            true,
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" type="text/css" href="{{.Docs}}/swagger-ui.css">
+<link rel="icon" type="image/png" href="{{.Docs}}/favicon-32x32.png" sizes="32x32">
+<link rel="icon" type="image/png" href="{{.Docs}}/favicon-16x16.png" sizes="16x16">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Docs}}/swagger-ui-bundle.js"></script>
+<script src="{{.Docs}}/swagger-ui-standalone-preset.js"></script>
+<script>
+window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: {{.Spec}},
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    oauth2RedirectUrl: window.location.origin + {{.Docs}} + "/oauth2-redirect.html",
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+</script>
+</body>
+</html>
+`))
+
+// Serve registers handlers on t that expose the documentation generated by st:
+//
+//	GET {prefix}/swagger.json   the Swagger 2.0 spec
+//	GET {prefix}/openapi.yaml   the OpenAPI 3.1 document
+//	GET {prefix}/docs           an embedded Swagger UI page, served without any external requests
+//
+// Specs are only regenerated once the documentation has changed, and responses carry an ETag so
+// clients can revalidate them cheaply. Register these on the transport st wraps (rather than st
+// itself), unless the documentation endpoints should document themselves.
+func Serve(t resttransport.Transport, st SwaggerTransport, c *ServeConfig) error {
+	if c == nil {
+		c = &ServeConfig{}
+	}
+	prefix := strings.TrimSuffix(c.Prefix, "/")
+	title := c.Title
+	if title == "" {
+		title = "API Documentation"
+	}
+
+	swaggerJSON := &specCache{st: st, render: renderSwagger}
+	openAPIYAML := &specCache{st: st, render: renderOpenAPI}
+
+	var page bytes.Buffer
+	err := uiTemplate.Execute(&page, struct {
+		Title, Docs, Spec string
+	}{title, prefix + "/docs", prefix + "/openapi.yaml"})
+	if err != nil {
+		return errors.Wrap(err, "unable to render documentation page")
+	}
+	pageBody := page.Bytes()
+	pageTag := resttransport.ETag(pageBody)
+
+	routes := []struct {
+		path string
+		h    resttransport.Handler
+	}{
+		{"/swagger.json", swaggerJSON.handler("application/json")},
+		{"/openapi.yaml", openAPIYAML.handler("application/yaml")},
+		{"/docs", func(ctx context.Context, r resttransport.RequestResponse) error {
+			return sendCached(r, "text/html; charset=utf-8", pageBody, pageTag)
+		}},
+		{"/docs/{file}", serveAsset},
+	}
+	for _, route := range routes {
+		if err := t.RegisterHandler(http.MethodGet, prefix+route.path, nil, route.h); err != nil {
+			return errors.Wrapf(err, "unable to register %s", route.path)
+		}
+	}
+	return nil
+}
+
+// revisioner is implemented by docTransport to tell when the documentation last changed.
+type revisioner interface {
+	revision() uint64
+}
+
+// specCache holds a rendered spec until the documentation changes.
+type specCache struct {
+	sync.Mutex
+	st     SwaggerTransport
+	render func(SwaggerTransport) ([]byte, error)
+
+	valid    bool
+	revision uint64
+	body     []byte
+	etag     string
+}
+
+func (c *specCache) get() ([]byte, string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	rev, canCache := c.st.(revisioner)
+	if canCache && c.valid && rev.revision() == c.revision {
+		return c.body, c.etag, nil
+	}
+
+	// read the revision first, so changes made while rendering invalidate the result
+	var revision uint64
+	if canCache {
+		revision = rev.revision()
+	}
+	body, err := c.render(c.st)
+	if err != nil {
+		return nil, "", err
+	}
+	c.valid, c.revision, c.body, c.etag = true, revision, body, resttransport.ETag(body)
+	return c.body, c.etag, nil
+}
+
+func (c *specCache) handler(contentType string) resttransport.Handler {
+	return func(ctx context.Context, r resttransport.RequestResponse) error {
+		body, tag, err := c.get()
+		if err != nil {
+			return err
+		}
+		return sendCached(r, contentType, body, tag)
+	}
+}
+
+func renderSwagger(st SwaggerTransport) ([]byte, error) {
+	swagger, err := st.Generate()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate swagger")
+	}
+	return json.MarshalIndent(swagger, "", "  ")
+}
+
+func renderOpenAPI(st SwaggerTransport) ([]byte, error) {
+	doc, err := st.GenerateOpenAPI()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate openapi")
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal openapi")
+	}
+	return jsonToYAML(raw)
+}
+
+// jsonToYAML converts JSON to block style YAML, keeping the order of keys (the spec types only
+// define their JSON marshaling).
+func jsonToYAML(raw []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(raw, &node); err != nil {
+		return nil, errors.Wrap(err, "unable to parse json")
+	}
+	resetStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, errors.Wrap(err, "unable to encode yaml")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to encode yaml")
+	}
+	return buf.Bytes(), nil
+}
+
+func resetStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetStyle(c)
+	}
+}
+
+var assetTags sync.Map
+
+func serveAsset(ctx context.Context, r resttransport.RequestResponse) error {
+	p := struct {
+		File string `path:"file"`
+	}{}
+	if err := r.BindPath(&p); err != nil {
+		return err
+	}
+	if !uiAssets[p.File] {
+		return resttransport.NewError(http.StatusNotFound, "")
+	}
+
+	b, err := fs.ReadFile(swaggerFiles.FS, p.File)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", p.File)
+	}
+	tag, ok := assetTags.Load(p.File)
+	if !ok {
+		tag, _ = assetTags.LoadOrStore(p.File, resttransport.ETag(b))
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(p.File))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return sendCached(r, contentType, b, tag.(string))
+}
+
+// sendCached sends b with its ETag, or a 304 Not Modified if the client already has it.
+func sendCached(r resttransport.RequestResponse, contentType string, b []byte, tag string) error {
+	h := r.ResponseHeader()
+	h.Set("ETag", tag)
+	h.Set("Cache-Control", "no-cache")
+	if resttransport.ETagMatches(r.RequestHeader().Get("If-None-Match"), tag) {
+		return r.NoBody(http.StatusNotModified)
+	}
+	return r.Blob(http.StatusOK, contentType, b)
+}
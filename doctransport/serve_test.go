@@ -0,0 +1,90 @@
+package doctransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func TestServe(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := New(tt)
+	require.NoError(resttransport.Register(dt, http.MethodGet, "/widgets", nil,
+		func(ctx context.Context, _ struct{}, _ struct{}, _ struct{}) ([]docWidget, error) {
+			return nil, nil
+		}))
+	require.NoError(Serve(tt, dt, &ServeConfig{Prefix: "/api/"}))
+
+	resp, err := tt.Do(http.MethodGet, "/api/swagger.json", nil)
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK).Header("Content-Type", "application/json")
+	swagger := struct {
+		Paths map[string]interface{} `json:"paths"`
+	}{}
+	require.NoError(resp.DecodeBody(&swagger))
+	assert.Contains(swagger.Paths, "/widgets")
+	assert.NotContains(swagger.Paths, "/api/swagger.json")
+
+	tag := resp.Header.Get("ETag")
+	require.NotEmpty(tag)
+
+	resp, err = tt.Do(http.MethodGet, "/api/swagger.json", &testtransport.Request{
+		Header: http.Header{"If-None-Match": {tag}},
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusNotModified)
+
+	// traffic that was already documented leaves the tag as is
+	_, err = tt.Do(http.MethodGet, "/widgets", nil)
+	require.NoError(err)
+	resp, err = tt.Do(http.MethodGet, "/api/swagger.json", &testtransport.Request{
+		Header: http.Header{"If-None-Match": {tag}},
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusNotModified)
+
+	// new documentation changes the tag
+	require.NoError(dt.RegisterHandler(http.MethodDelete, "/widgets", nil,
+		func(ctx context.Context, r resttransport.RequestResponse) error {
+			return r.NoBody(http.StatusNoContent)
+		}))
+	resp, err = tt.Do(http.MethodGet, "/api/swagger.json", &testtransport.Request{
+		Header: http.Header{"If-None-Match": {tag}},
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK)
+	assert.NotEqual(tag, resp.Header.Get("ETag"))
+
+	resp, err = tt.Do(http.MethodGet, "/api/openapi.yaml", nil)
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK).Header("Content-Type", "application/yaml")
+	assert.Contains(string(resp.Body.([]byte)), "openapi: 3.1.0\n")
+
+	resp, err = tt.Do(http.MethodGet, "/api/docs", nil)
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK)
+	page := string(resp.Body.([]byte))
+	assert.Contains(page, `src="/api/docs/swagger-ui-bundle.js"`)
+	assert.Contains(page, `url: "/api/openapi.yaml"`)
+
+	resp, err = tt.Do(http.MethodGet, "/api/docs/{file}", &testtransport.Request{
+		Path: map[string]string{"file": "swagger-ui.css"},
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK).Header("Content-Type", "text/css; charset=utf-8")
+
+	resp, err = tt.Do(http.MethodGet, "/api/docs/{file}", &testtransport.Request{
+		Path: map[string]string{"file": "swagger-ui.js.map"},
+	})
+	require.NoError(err)
+	resp.Expect(t).Error().Status(http.StatusNotFound)
+}
@@ -0,0 +1,140 @@
+package doctransport
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport/validate"
+)
+
+// applyValidation documents the `validate` rules of a field as JSON Schema keywords.
+// nolint: gocyclo
+func applyValidation(s *spec.Schema, t reflect.Type, rules *validate.Rules) error {
+	if rules == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for _, r := range rules.Rules {
+		switch r.Name {
+		case validate.Min, validate.Max, validate.Len:
+			n, err := r.Number()
+			if err != nil {
+				return err
+			}
+			min := r.Name == validate.Min || r.Name == validate.Len
+			max := r.Name == validate.Max || r.Name == validate.Len
+			switch {
+			case s.Type.Contains("integer") || s.Type.Contains("number"):
+				if min {
+					s.Minimum = &n
+				}
+				if max {
+					s.Maximum = &n
+				}
+			case s.Type.Contains("string"):
+				if min {
+					s.MinLength = int64Ptr(n)
+				}
+				if max {
+					s.MaxLength = int64Ptr(n)
+				}
+			case s.Type.Contains("array"):
+				if min {
+					s.MinItems = int64Ptr(n)
+				}
+				if max {
+					s.MaxItems = int64Ptr(n)
+				}
+			case s.Type.Contains("object"):
+				if min {
+					s.MinProperties = int64Ptr(n)
+				}
+				if max {
+					s.MaxProperties = int64Ptr(n)
+				}
+			}
+		case validate.Pattern:
+			s.Pattern = r.Param
+		case validate.Email:
+			s.Format = "email"
+		case validate.UUID:
+			s.Format = "uuid"
+		case validate.Enum:
+			values, err := enumValues(s, r.Values())
+			if err != nil {
+				return err
+			}
+			s.Enum = values
+		}
+	}
+
+	if rules.Elem == nil {
+		return nil
+	}
+	switch {
+	case s.Items != nil && s.Items.Schema != nil:
+		return applyValidation(s.Items.Schema, t.Elem(), rules.Elem)
+	case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+		return applyValidation(s.AdditionalProperties.Schema, t.Elem(), rules.Elem)
+	}
+	return nil
+}
+
+func int64Ptr(n float64) *int64 {
+	i := int64(n)
+	return &i
+}
+
+// enumValues converts enum values to the JSON type of the schema.
+func enumValues(s *spec.Schema, values []string) ([]interface{}, error) {
+	enum := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		switch {
+		case s.Type.Contains("integer"):
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid integer enum value '%s'", v)
+			}
+			enum = append(enum, i)
+		case s.Type.Contains("number"):
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid number enum value '%s'", v)
+			}
+			enum = append(enum, f)
+		case s.Type.Contains("boolean"):
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid boolean enum value '%s'", v)
+			}
+			enum = append(enum, b)
+		default:
+			enum = append(enum, v)
+		}
+	}
+	return enum, nil
+}
+
+// commonValidations copies the validation keywords of a schema for use on a parameter.
+func commonValidations(s spec.Schema) spec.CommonValidations {
+	return spec.CommonValidations{
+		Maximum:          s.Maximum,
+		ExclusiveMaximum: s.ExclusiveMaximum,
+		Minimum:          s.Minimum,
+		ExclusiveMinimum: s.ExclusiveMinimum,
+		MaxLength:        s.MaxLength,
+		MinLength:        s.MinLength,
+		Pattern:          s.Pattern,
+		MaxItems:         s.MaxItems,
+		MinItems:         s.MinItems,
+		UniqueItems:      s.UniqueItems,
+		MultipleOf:       s.MultipleOf,
+		Enum:             s.Enum,
+	}
+}
@@ -0,0 +1,99 @@
+package doctransport
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/go-openapi/spec"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport/openapi"
+)
+
+// RegisterWebSocketHandler registers a WebSocket handler on the inner transport, which must
+// implement resttransport.WebSocketTransport, and documents its upgrade endpoint once registered.
+func (t *docTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return t.registerWebSocket(false, path, h, opts)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers an authenticated WebSocket handler on the inner
+// transport, which must implement resttransport.WebSocketTransport, and documents its upgrade
+// endpoint once registered.
+func (t *docTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return t.registerWebSocket(true, path, h, opts)
+}
+
+// registerWebSocket registers the handler on the inner transport first, so endpoints that can't be
+// registered aren't documented, then documents a GET operation answered with 101 Switching
+// Protocols, marked with openapi.WebSocketExtension. The wrapped handler records the parameters it
+// binds.
+func (t *docTransport) registerWebSocket(auth bool, path string, inner resttransport.WebSocketHandler, opts []resttransport.RouteOption) error {
+	t.Lock()
+	defer t.Unlock()
+
+	// op is set before the lock is released, so connections wait for it
+	var op *spec.Operation
+	wrapped := func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		t.Lock()
+		op := op
+		t.Unlock()
+		if op == nil {
+			return inner(ctx, conn)
+		}
+		return inner(ctx, &docWebSocketConn{
+			WebSocketConn: conn,
+			docTransport:  t,
+			op:            op,
+		})
+	}
+
+	register := resttransport.RegisterWebSocketHandler
+	if auth {
+		register = resttransport.RegisterAuthenticatedWebSocketHandler
+	}
+	if err := register(t.inner, path, wrapped, opts...); err != nil {
+		return err
+	}
+
+	declared, err := t.declareOperation(auth, http.MethodGet, path, nil, resttransport.NewRoute(opts...))
+	if err != nil {
+		return err
+	}
+	declared.AddExtension(openapi.WebSocketExtension, true)
+	t.setResponse(declared, http.StatusSwitchingProtocols, spec.Response{
+		ResponseProps: spec.ResponseProps{
+			Description: "Switching Protocols to WebSocket",
+		},
+	})
+	t.addResponseHeader(declared, http.StatusSwitchingProtocols, "Upgrade", "websocket")
+	t.addProblemResponse(declared, http.StatusUpgradeRequired)
+	op = declared
+	return nil
+}
+
+type docWebSocketConn struct {
+	resttransport.WebSocketConn
+	*docTransport
+	op *spec.Operation
+}
+
+func (conn *docWebSocketConn) BindQuery(v interface{}) error {
+	if err := conn.appendParameters("query", v); err != nil {
+		return err
+	}
+	return conn.WebSocketConn.BindQuery(v)
+}
+
+func (conn *docWebSocketConn) BindPath(v interface{}) error {
+	if err := conn.appendParameters("path", v); err != nil {
+		return err
+	}
+	return conn.WebSocketConn.BindPath(v)
+}
+
+func (conn *docWebSocketConn) appendParameters(in string, v interface{}) error {
+	conn.Lock()
+	defer conn.Unlock()
+	return conn.appendSimpleSchemaParameters(conn.op, in, reflect.TypeOf(v))
+}
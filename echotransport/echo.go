@@ -2,30 +2,35 @@
 package echotransport
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
-	"github.com/gorilla/schema"
 	"github.com/labstack/echo"
 	"github.com/pkg/errors"
 
 	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+	"github.com/paultyng/resttransport/internal/bind"
+	"github.com/paultyng/resttransport/mediatype"
 )
 
-var (
-	pathDecoder  = schema.NewDecoder()
-	queryDecoder = schema.NewDecoder()
-)
-
-func init() {
-	pathDecoder.SetAliasTag("path")
-	queryDecoder.SetAliasTag("query")
-}
-
 type echoTransport struct {
 	echo                     EchoOrContext
 	authenticationMiddleware []echo.MiddlewareFunc
 	userKey                  string
+	codecs                   *codec.Registry
+	errorRenderer            ErrorRenderer
+	authenticators           map[string]resttransport.Authenticator
+	eventKeepAlive           time.Duration
+	websocket                *websocketUpgrader
 }
 
 // EchoOrContext represents an Echo application struct or Context interface.
@@ -40,11 +45,30 @@ type EchoOrContext interface {
 	TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
 }
 
-// Config holds configuration information for the Echo Transport.
+// ErrorRenderer renders an error returned from a resttransport.Handler. Returning an error passes it
+// on to the Echo HTTPErrorHandler.
+type ErrorRenderer func(echo.Context, error) error
+
+// Config holds configuration information for the Echo Transport. Codecs are used to negotiate
+// response bodies from the request Accept header and decode request bodies by Content-Type, and
+// default to codec.Default(). ErrorRenderer defaults to RenderProblem.
+//
+// Authenticators are keyed by security scheme name. Routes registered with the
+// resttransport.Security option are authenticated by them instead of AuthenticationMiddleware, and
+// the authenticated user is stored under UserContextKey.
+//
+// EventKeepAlive is how often a comment is sent on idle event streams, defaults to
+// resttransport.DefaultEventKeepAlive, and a negative value disables it. WebSocket configures the
+// connections of handlers registered through resttransport.WebSocketTransport.
 type Config struct {
 	Echo                     EchoOrContext
 	AuthenticationMiddleware []echo.MiddlewareFunc
 	UserContextKey           string
+	Codecs                   *codec.Registry
+	ErrorRenderer            ErrorRenderer
+	Authenticators           map[string]resttransport.Authenticator
+	EventKeepAlive           time.Duration
+	WebSocket                *WebSocketConfig
 }
 
 // New returns a Transport that wraps an Echo application.
@@ -58,29 +82,137 @@ func New(c *Config) resttransport.Transport {
 	if e == nil {
 		e = echo.New()
 	}
+	codecs := c.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+	errorRenderer := c.ErrorRenderer
+	if errorRenderer == nil {
+		errorRenderer = RenderProblem
+	}
+	eventKeepAlive := c.EventKeepAlive
+	switch {
+	case eventKeepAlive == 0:
+		eventKeepAlive = resttransport.DefaultEventKeepAlive
+	case eventKeepAlive < 0:
+		eventKeepAlive = 0
+	}
 	return &echoTransport{
 		echo: e,
 		authenticationMiddleware: c.AuthenticationMiddleware,
 		userKey:                  c.UserContextKey,
+		codecs:                   codecs,
+		errorRenderer:            errorRenderer,
+		authenticators:           c.Authenticators,
+		eventKeepAlive:           eventKeepAlive,
+		websocket:                newUpgrader(c.WebSocket),
+	}
+}
+
+// RenderProblem renders errors as RFC 9457 `application/problem+json`. Echo HTTP errors keep their
+// status code, and causes of 500 errors are logged rather than rendered.
+func RenderProblem(c echo.Context, err error) error {
+	if he, ok := err.(*echo.HTTPError); ok {
+		e := resttransport.NewError(he.Code, fmt.Sprint(he.Message))
+		if e.Detail == http.StatusText(he.Code) {
+			e.Detail = ""
+		}
+		e.Err = he
+		err = e
+	}
+
+	p := resttransport.NewProblem(err)
+	if p.Status == http.StatusInternalServerError {
+		c.Logger().Error(err)
 	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, resttransport.ProblemContentType)
+	resp.WriteHeader(p.Status)
+	return json.NewEncoder(resp).Encode(p)
 }
 
 type echoRequestResponse struct {
-	userKey string
-	c       echo.Context
+	userKey        string
+	codecs         *codec.Registry
+	consumes       []string
+	c              echo.Context
+	raw            []byte
+	eventKeepAlive time.Duration
+	events         resttransport.EventSink
 }
 
 func (rr *echoRequestResponse) RequestHeader() http.Header {
 	return rr.c.Request().Header
 }
 
+func (rr *echoRequestResponse) RemoteAddr() string {
+	return rr.c.Request().RemoteAddr
+}
+
+func (rr *echoRequestResponse) URL() *url.URL {
+	return rr.c.Request().URL
+}
+
+func (rr *echoRequestResponse) Preconditions() resttransport.Preconditions {
+	return resttransport.ParsePreconditions(rr.c.Request().Header)
+}
+
+func (rr *echoRequestResponse) ResponseHeader() http.Header {
+	return rr.c.Response().Header()
+}
+
 func (rr *echoRequestResponse) BindQuery(v interface{}) error {
 	q := rr.c.QueryParams()
-	return queryDecoder.Decode(v, q)
+	return bind.Query(v, q)
+}
+
+func isForm(contentType string) bool {
+	return strings.HasPrefix(contentType, echo.MIMEApplicationForm) ||
+		strings.HasPrefix(contentType, echo.MIMEMultipartForm)
 }
 
 func (rr *echoRequestResponse) BindBody(v interface{}) error {
-	return rr.c.Bind(v)
+	req := rr.c.Request()
+	if req.ContentLength == 0 {
+		return resttransport.NewError(http.StatusBadRequest, "request body can't be empty")
+	}
+
+	ctype := req.Header.Get(echo.HeaderContentType)
+	dec, ok := rr.codecs.ForContentType(ctype, rr.consumes)
+	if !ok {
+		if isForm(ctype) && (len(rr.consumes) == 0 || mediatype.Matches(rr.consumes, ctype)) {
+			// forms are bound by Echo rather than a codec
+			if err := rr.c.Bind(v); err != nil {
+				return bind.Error("form", err)
+			}
+			return bind.Validate("form", v)
+		}
+		return resttransport.Errorf(http.StatusUnsupportedMediaType, "unsupported content type '%s'", ctype)
+	}
+
+	return bind.Body(v, dec.Decode(req.Body, v))
+}
+
+func (rr *echoRequestResponse) RawBody() ([]byte, error) {
+	if rr.raw == nil {
+		b, err := bufferBody(rr.c.Request())
+		if err != nil {
+			return nil, err
+		}
+		rr.raw = b
+	}
+	return rr.raw, nil
+}
+
+// bufferBody reads the request body and replaces it with a buffer of the bytes read.
+func bufferBody(req *http.Request) ([]byte, error) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
 }
 
 func (rr *echoRequestResponse) BindPath(v interface{}) error {
@@ -88,12 +220,47 @@ func (rr *echoRequestResponse) BindPath(v interface{}) error {
 	for _, n := range rr.c.ParamNames() {
 		values[n] = []string{rr.c.Param(n)}
 	}
-	return pathDecoder.Decode(v, values)
+	return bind.Path(v, values)
 }
 
 func (rr *echoRequestResponse) Body(status int, body interface{}) error {
-	// TODO: based on accepted content types?
-	return rr.c.JSON(status, body)
+	enc, ok := rr.codecs.Negotiate(rr.c.Request().Header.Get(echo.HeaderAccept))
+	if !ok {
+		return resttransport.NewError(http.StatusNotAcceptable, "")
+	}
+
+	resp := rr.c.Response()
+	resp.Header().Set(echo.HeaderContentType, enc.ContentType())
+	resp.WriteHeader(status)
+	return enc.Encode(resp, body)
+}
+
+func (rr *echoRequestResponse) Blob(status int, contentType string, b []byte) error {
+	return rr.c.Blob(status, contentType, b)
+}
+
+func (rr *echoRequestResponse) Stream(status int, contentType string, r io.Reader) error {
+	resp := rr.c.Response()
+	resp.Header().Set(echo.HeaderContentType, contentType)
+	resp.WriteHeader(status)
+	rr.flush()
+	return resttransport.WriteStream(resp, rr.flush, r)
+}
+
+func (rr *echoRequestResponse) Events(ctx context.Context) resttransport.EventSink {
+	rr.events = resttransport.StartEventStream(ctx, rr.c.Response(), rr.flush, rr.eventKeepAlive)
+	return rr.events
+}
+
+func (rr *echoRequestResponse) ResponseSize() int64 {
+	return rr.c.Response().Size
+}
+
+// flush sends buffered response data to the client, if the underlying writer supports it.
+func (rr *echoRequestResponse) flush() {
+	if f, ok := rr.c.Response().Writer.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 func (rr *echoRequestResponse) NoBody(status int) error {
@@ -118,25 +285,86 @@ func (rr *echoRequestResponse) FormFile(name string) (*multipart.FileHeader, err
 	return rr.c.FormFile(name)
 }
 
-func (t *echoTransport) echoHandlerWrapper(h resttransport.Handler) echo.HandlerFunc {
+func hasBody(req *http.Request) bool {
+	return req.ContentLength != 0 || len(req.TransferEncoding) > 0
+}
+
+func (t *echoTransport) echoHandlerWrapper(consumes []string, security []resttransport.SecurityRequirement, h resttransport.Handler) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		reqresp := &echoRequestResponse{
-			c:       c,
-			userKey: t.userKey,
+		err := t.authenticate(c, security)
+		if err == nil {
+			err = t.handle(c, consumes, h)
+		}
+		if err != nil && !c.Response().Committed {
+			return t.errorRenderer(c, err)
 		}
-		return h(c.Request().Context(), reqresp)
+		return err
 	}
 }
 
-func (t *echoTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
-	return t.register(httpMethod, replacePathParameters(path), h)
+// authenticate enforces the security requirements of a route, if it has any.
+func (t *echoTransport) authenticate(c echo.Context, security []resttransport.SecurityRequirement) error {
+	if len(security) == 0 {
+		return nil
+	}
+	user, err := resttransport.Authenticate(c.Request().Context(), c.Request(), security, t.authenticators)
+	if err != nil {
+		if e, ok := resttransport.AsError(err); ok && e.Status == http.StatusUnauthorized {
+			for _, challenge := range resttransport.Challenges(security) {
+				c.Response().Header().Add(echo.HeaderWWWAuthenticate, challenge)
+			}
+		}
+		return err
+	}
+	c.Set(t.userKey, user)
+	return nil
 }
 
-func (t *echoTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
-	return t.register(httpMethod, replacePathParameters(path), h, t.authenticationMiddleware...)
+func (t *echoTransport) handle(c echo.Context, consumes []string, h resttransport.Handler) error {
+	req := c.Request()
+	if len(consumes) > 0 && hasBody(req) && !mediatype.Matches(consumes, req.Header.Get(echo.HeaderContentType)) {
+		return resttransport.Errorf(http.StatusUnsupportedMediaType, "unsupported content type '%s'", req.Header.Get(echo.HeaderContentType))
+	}
+
+	reqresp := &echoRequestResponse{
+		c:              c,
+		userKey:        t.userKey,
+		codecs:         t.codecs,
+		consumes:       consumes,
+		eventKeepAlive: t.eventKeepAlive,
+	}
+	ctx := req.Context()
+	if user := reqresp.User(); user != nil {
+		ctx = resttransport.WithPrincipal(ctx, user)
+	}
+	defer func() {
+		if reqresp.events != nil {
+			reqresp.events.Close()
+		}
+	}()
+	return h(ctx, reqresp)
 }
 
-func (t *echoTransport) register(httpMethod, path string, h resttransport.Handler, mw ...echo.MiddlewareFunc) error {
+func (t *echoTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.register(httpMethod, replacePathParameters(path), consumes, h, resttransport.NewRoute(opts...))
+}
+
+func (t *echoTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	route := resttransport.NewRoute(opts...)
+	if len(route.Security) > 0 {
+		// the route's authenticators replace the authentication middleware
+		return t.register(httpMethod, replacePathParameters(path), consumes, h, route)
+	}
+	return t.register(httpMethod, replacePathParameters(path), consumes, h, route, t.authenticationMiddleware...)
+}
+
+func (t *echoTransport) register(httpMethod, path string, consumes []string, h resttransport.Handler, route *resttransport.Route, mw ...echo.MiddlewareFunc) error {
+	for _, req := range route.Security {
+		if _, ok := t.authenticators[req.Scheme.Name]; !ok {
+			return errors.Errorf("no authenticator for security scheme '%s'", req.Scheme.Name)
+		}
+	}
+
 	var reg func(string, echo.HandlerFunc, ...echo.MiddlewareFunc) *echo.Route
 
 	switch httpMethod {
@@ -160,6 +388,7 @@ func (t *echoTransport) register(httpMethod, path string, h resttransport.Handle
 		return errors.Errorf("unexpected method '%s'", httpMethod)
 	}
 
-	reg(path, t.echoHandlerWrapper(h), mw...)
+	h = resttransport.EnforcePreconditions(httpMethod, route, h)
+	reg(path, t.echoHandlerWrapper(consumes, route.Security, h), mw...)
 	return nil
 }
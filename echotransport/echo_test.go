@@ -0,0 +1,388 @@
+package echotransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+)
+
+func TestSecurity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	jwt := &resttransport.SecurityScheme{Name: "jwt", Type: resttransport.BearerAuth}
+	e := echo.New()
+	tr := New(&Config{
+		Echo:           e,
+		UserContextKey: "user",
+		Authenticators: map[string]resttransport.Authenticator{
+			"jwt": func(ctx context.Context, s *resttransport.SecurityScheme, cred string, scopes []string) (interface{}, error) {
+				switch cred {
+				case "admin":
+					return "admin", nil
+				case "reader":
+					return nil, resttransport.Errorf(http.StatusForbidden, "missing scope %v", scopes)
+				}
+				return nil, resttransport.NewError(http.StatusUnauthorized, "invalid token")
+			},
+		},
+	})
+
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Body(http.StatusOK, r.User())
+	}
+	require.NoError(tr.RegisterHandler(http.MethodDelete, "/widgets/{id}", nil, h, resttransport.Security(jwt, "widgets:write")))
+
+	other := &resttransport.SecurityScheme{Name: "key", Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInHeader, ParamName: "X-Key"}
+	assert.Error(tr.RegisterHandler(http.MethodGet, "/widgets", nil, h, resttransport.Security(other)))
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/widgets/1", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("")
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+	assert.Equal("Bearer", rec.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(http.StatusUnauthorized, do("bad").Code)
+	assert.Equal(http.StatusForbidden, do("reader").Code)
+
+	rec = do("admin")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`"admin"`, rec.Body.String())
+}
+
+func TestCodecs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	type widget struct {
+		ID   string `json:"id" xml:"id"`
+		Name string `json:"name" xml:"name"`
+	}
+
+	e := echo.New()
+	tr := New(&Config{Echo: e})
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		var w widget
+		if err := r.BindBody(&w); err != nil {
+			return err
+		}
+		w.ID = "1"
+		return r.Body(http.StatusCreated, w)
+	}))
+
+	do := func(contentType string, body []byte, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/widgets", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	encode := func(c codec.Codec, v interface{}) []byte {
+		var buf bytes.Buffer
+		require.NoError(c.Encode(&buf, v))
+		return buf.Bytes()
+	}
+
+	rec := do("application/json", []byte(`{"name":"foo"}`), "")
+	assert.Equal(http.StatusCreated, rec.Code)
+	assert.Equal(codec.JSON.ContentType(), rec.Header().Get("Content-Type"))
+	assert.JSONEq(`{"id":"1","name":"foo"}`, rec.Body.String())
+
+	// the request codec is chosen by Content-Type, the response codec by Accept
+	rec = do(codec.XML.ContentType(), encode(codec.XML, widget{Name: "foo"}), codec.MessagePack.ContentType())
+	assert.Equal(http.StatusCreated, rec.Code)
+	assert.Equal(codec.MessagePack.ContentType(), rec.Header().Get("Content-Type"))
+	var actual widget
+	require.NoError(codec.MessagePack.Decode(rec.Body, &actual))
+	assert.Equal(widget{ID: "1", Name: "foo"}, actual)
+
+	rec = do(codec.CBOR.ContentType(), encode(codec.CBOR, widget{Name: "foo"}), "application/xml;q=0.5, text/csv")
+	assert.Equal(http.StatusCreated, rec.Code)
+	assert.Equal(codec.XML.ContentType(), rec.Header().Get("Content-Type"))
+
+	rec = do("application/json", []byte(`{"name":"foo"}`), "text/csv")
+	assert.Equal(http.StatusNotAcceptable, rec.Code)
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	// a 406 Problem body is rendered as JSON regardless of Accept
+	var p resttransport.Problem
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(http.StatusNotAcceptable, p.Status)
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	e := echo.New()
+	tr := New(&Config{Echo: e})
+	called := false
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		called = true
+		var v struct{}
+		if err := r.BindBody(&v); err != nil {
+			return err
+		}
+		return r.NoBody(http.StatusNoContent)
+	}
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/json", []string{"application/json"}, h))
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/any", nil, h))
+
+	do := func(path, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// rejected before the handler runs
+	rec := do("/json", "text/plain")
+	assert.Equal(http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.False(called)
+
+	assert.Equal(http.StatusNoContent, do("/json", "application/json; charset=utf-8").Code)
+
+	// rejected by BindBody, as no codec decodes it
+	called = false
+	assert.Equal(http.StatusUnsupportedMediaType, do("/any", "text/plain").Code)
+	assert.True(called)
+}
+
+func TestPreconditions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	version := resttransport.Version{ETag: `"v1"`}
+	calls := 0
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		version.ETag = `"v2"`
+		resttransport.SetVersion(r, version)
+		return r.NoBody(http.StatusNoContent)
+	}
+	e := echo.New()
+	tr := New(&Config{Echo: e})
+	require.NoError(tr.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, h,
+		resttransport.RequirePreconditions(),
+		resttransport.CurrentVersion(func(ctx context.Context, r resttransport.RequestResponse) (resttransport.Version, error) {
+			return version, nil
+		})))
+
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/widgets/1", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := put("")
+	assert.Equal(http.StatusPreconditionRequired, rec.Code)
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(rec.Body.String(), `"precondition_required"`)
+
+	rec = put(`"v0"`)
+	assert.Equal(http.StatusPreconditionFailed, rec.Code)
+	assert.Contains(rec.Body.String(), `"precondition_failed"`)
+	assert.Equal(0, calls)
+
+	rec = put(`"v1"`)
+	assert.Equal(http.StatusNoContent, rec.Code)
+	assert.Equal(`"v2"`, rec.Header().Get("ETag"))
+
+	// a lost update is rejected before the handler runs
+	assert.Equal(http.StatusPreconditionFailed, put(`"v1"`).Code)
+	assert.Equal(1, calls)
+}
+
+func TestStreaming(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	e := echo.New()
+	tr := New(&Config{Echo: e, EventKeepAlive: -1})
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/logs", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Stream(http.StatusOK, "text/plain", strings.NewReader("line 1\nline 2\n"))
+	}))
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/jobs/{id}/events", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		events := r.Events(ctx)
+		for i := 1; i <= 2; i++ {
+			if err := events.Send(resttransport.Event{ID: strconv.Itoa(i), Event: "progress", Data: map[string]int{"percent": i * 50}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal("line 1\nline 2\n", rec.Body.String())
+	assert.True(rec.Flushed)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/1/events", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(resttransport.EventStreamContentType, rec.Header().Get("Content-Type"))
+	assert.Equal("id: 1\nevent: progress\ndata: {\"percent\":50}\n\nid: 2\nevent: progress\ndata: {\"percent\":100}\n\n", rec.Body.String())
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	e := echo.New()
+	authenticate := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") != "secret" {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			c.Set("user", "alice")
+			return next(c)
+		}
+	}
+	tr := New(&Config{
+		Echo:                     e,
+		UserContextKey:           "user",
+		AuthenticationMiddleware: []echo.MiddlewareFunc{authenticate},
+	})
+
+	type message struct {
+		Text string `json:"text"`
+	}
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(tr, "/rooms/{id}", func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		p := struct {
+			ID string `path:"id"`
+		}{}
+		if err := conn.BindPath(&p); err != nil {
+			return err
+		}
+		user, err := resttransport.PrincipalAs[string](ctx)
+		if err != nil {
+			return err
+		}
+		for {
+			var m message
+			if err := conn.Read(&m); err != nil {
+				return err
+			}
+			if m.Text == "" {
+				return resttransport.NewError(http.StatusBadRequest, "empty message")
+			}
+			if err := conn.Write(message{Text: user + "@" + p.ID + ": " + m.Text}); err != nil {
+				return err
+			}
+		}
+	}))
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms/1"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(err)
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	req := httptest.NewRequest(http.MethodGet, "/rooms/1", nil)
+	req.Header.Set("Authorization", "secret")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusUpgradeRequired, rec.Code)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"secret"}})
+	require.NoError(err)
+	defer conn.Close()
+
+	require.NoError(conn.WriteJSON(message{Text: "hi"}))
+	messageType, b, err := conn.ReadMessage()
+	require.NoError(err)
+	assert.Equal(websocket.TextMessage, messageType)
+	assert.JSONEq(`{"text":"alice@1: hi"}`, string(b))
+
+	require.NoError(conn.WriteJSON(message{}))
+	_, _, err = conn.ReadMessage()
+	require.True(websocket.IsCloseError(err, 4400), "unexpected error %v", err)
+	assert.Equal("empty message", err.(*websocket.CloseError).Text)
+}
+
+func TestWebSocket_Disconnects(t *testing.T) {
+	e := echo.New()
+	tr := New(&Config{
+		Echo:      e,
+		WebSocket: &WebSocketConfig{PingInterval: 50 * time.Millisecond},
+	})
+	errs := make(chan error, 1)
+	require.NoError(t, resttransport.RegisterWebSocketHandler(tr, "/rooms", func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		for {
+			var v interface{}
+			if err := conn.Read(&v); err != nil {
+				errs <- err
+				return err
+			}
+		}
+	}))
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms"
+
+	for name, disconnect := range map[string]func(*websocket.Conn){
+		"no status": func(conn *websocket.Conn) {
+			_ = conn.WriteMessage(websocket.CloseMessage, nil)
+		},
+		"policy": func(conn *websocket.Conn) {
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, ""))
+		},
+		"abnormal": func(conn *websocket.Conn) {
+			conn.UnderlyingConn().Close()
+		},
+		// the client never reads, so never answers pings
+		"timeout": func(conn *websocket.Conn) {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			disconnect(conn)
+			select {
+			case err := <-errs:
+				assert.Equal(t, resttransport.ErrWebSocketClosed, err)
+				code, _ := resttransport.CloseStatus(err)
+				assert.Equal(t, resttransport.CloseNormal, code)
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler did not return")
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package echotransport
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultReadLimit    = 1 << 20
+	controlWriteTimeout = 10 * time.Second
+)
+
+// WebSocketConfig holds configuration for WebSocket connections. Codec encodes messages and
+// defaults to codec.JSON, whose messages are sent as text, other codecs' as binary. A ping is sent
+// every PingInterval (default 30 seconds), and connections are closed if no pong or message
+// arrives within two intervals. Messages are limited to ReadLimit bytes, default 1 MiB.
+// CheckOrigin defaults to rejecting cross-origin requests.
+type WebSocketConfig struct {
+	Codec        codec.Codec
+	PingInterval time.Duration
+	ReadLimit    int64
+	CheckOrigin  func(*http.Request) bool
+}
+
+func newUpgrader(c *WebSocketConfig) *websocketUpgrader {
+	if c == nil {
+		c = &WebSocketConfig{}
+	}
+	u := &websocketUpgrader{
+		codec:        c.Codec,
+		pingInterval: c.PingInterval,
+		readLimit:    c.ReadLimit,
+		upgrader:     websocket.Upgrader{CheckOrigin: c.CheckOrigin},
+	}
+	if u.codec == nil {
+		u.codec = codec.JSON
+	}
+	if u.pingInterval <= 0 {
+		u.pingInterval = defaultPingInterval
+	}
+	if u.readLimit <= 0 {
+		u.readLimit = defaultReadLimit
+	}
+	return u
+}
+
+type websocketUpgrader struct {
+	codec        codec.Codec
+	pingInterval time.Duration
+	readLimit    int64
+	upgrader     websocket.Upgrader
+}
+
+func (t *echoTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	route := resttransport.NewRoute(opts...)
+	return t.register(http.MethodGet, replacePathParameters(path), nil, t.upgradeHandler(h), route)
+}
+
+func (t *echoTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	route := resttransport.NewRoute(opts...)
+	if len(route.Security) > 0 {
+		// the route's authenticators replace the authentication middleware
+		return t.register(http.MethodGet, replacePathParameters(path), nil, t.upgradeHandler(h), route)
+	}
+	return t.register(http.MethodGet, replacePathParameters(path), nil, t.upgradeHandler(h), route, t.authenticationMiddleware...)
+}
+
+// upgradeHandler adapts a WebSocketHandler to a Handler that upgrades the request, after
+// authentication, and closes the connection with the handler's outcome.
+func (t *echoTransport) upgradeHandler(h resttransport.WebSocketHandler) resttransport.Handler {
+	u := t.websocket
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		rr, ok := reqres.(*echoRequestResponse)
+		if !ok {
+			return errors.Errorf("unexpected RequestResponse %T", reqres)
+		}
+		if !websocket.IsWebSocketUpgrade(rr.c.Request()) {
+			rr.c.Response().Header().Set("Upgrade", "websocket")
+			return resttransport.NewError(http.StatusUpgradeRequired, "expected a WebSocket upgrade request")
+		}
+
+		ws, err := u.upgrader.Upgrade(rr.c.Response(), rr.c.Request(), nil)
+		if err != nil {
+			// the upgrader has already responded
+			return nil
+		}
+		conn := &echoWebSocketConn{
+			echoRequestResponse: rr,
+			ws:                  ws,
+			codec:               u.codec,
+			pongWait:            2 * u.pingInterval,
+			done:                make(chan struct{}),
+		}
+		ws.SetReadLimit(u.readLimit)
+		conn.keepAlive(u.pingInterval)
+
+		err = h(ctx, conn)
+		code, reason := resttransport.CloseStatus(err)
+		conn.Close(code, reason)
+		if code == resttransport.CloseInternalError {
+			rr.c.Logger().Error(err)
+		}
+		return nil
+	}
+}
+
+type echoWebSocketConn struct {
+	*echoRequestResponse
+	ws       *websocket.Conn
+	codec    codec.Codec
+	pongWait time.Duration
+	done     chan struct{}
+	once     sync.Once
+}
+
+// keepAlive pings the peer every interval until the connection is closed. Reads fail if neither a
+// pong nor a message arrives within pongWait.
+func (c *echoWebSocketConn) keepAlive(interval time.Duration) {
+	c.ws.SetReadDeadline(time.Now().Add(c.pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(c.pongWait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(controlWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (c *echoWebSocketConn) Read(v interface{}) error {
+	_, b, err := c.ws.ReadMessage()
+	if err != nil {
+		if closed(err) {
+			return resttransport.ErrWebSocketClosed
+		}
+		return errors.Wrap(err, "unable to read message")
+	}
+	c.ws.SetReadDeadline(time.Now().Add(c.pongWait))
+	if err := c.codec.Decode(bytes.NewReader(b), v); err != nil {
+		return &resttransport.Error{
+			Status: http.StatusBadRequest,
+			Detail: "unable to decode message",
+			Err:    err,
+		}
+	}
+	return nil
+}
+
+// closed reports whether a read error means the connection is gone: the peer sent a close message
+// with any code (including 1005 for none), dropped the connection (reported as 1006) or missed the
+// read deadline, or the connection was closed locally.
+func closed(err error) bool {
+	var ce *websocket.CloseError
+	if stderrors.As(err, &ce) {
+		return true
+	}
+	var ne net.Error
+	if stderrors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return stderrors.Is(err, net.ErrClosed)
+}
+
+func (c *echoWebSocketConn) Write(v interface{}) error {
+	var buf bytes.Buffer
+	if err := c.codec.Encode(&buf, v); err != nil {
+		return errors.Wrap(err, "unable to encode message")
+	}
+	messageType := websocket.BinaryMessage
+	if c.codec.ContentType() == codec.JSON.ContentType() {
+		messageType = websocket.TextMessage
+	}
+	return errors.Wrap(c.ws.WriteMessage(messageType, buf.Bytes()), "unable to write message")
+}
+
+func (c *echoWebSocketConn) Close(code int, reason string) error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		msg := websocket.FormatCloseMessage(code, resttransport.TruncateCloseReason(reason))
+		_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(controlWriteTimeout))
+		err = c.ws.Close()
+	})
+	return err
+}
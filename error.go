@@ -0,0 +1,130 @@
+package resttransport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	// Field is the name or path of the field, for example `id` or `items[0].name`.
+	Field string `json:"field"`
+	// In is where the field was bound from (`path`, `query` or `body`).
+	In     string `json:"in,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Error is a handler error with an HTTP status. Transports render it as an RFC 9457 problem, any
+// other error returned from a Handler is treated as a 500 Internal Server Error.
+type Error struct {
+	Status int
+	// Code is an optional, machine readable error code.
+	Code   string
+	Title  string
+	Detail string
+	Fields []FieldError
+	// Err is the underlying cause, it is never rendered.
+	Err error
+}
+
+// NewError returns an Error with the given status and detail.
+func NewError(status int, detail string) *Error {
+	return &Error{
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Errorf returns an Error with the given status and a formatted detail.
+func Errorf(status int, format string, args ...interface{}) *Error {
+	return NewError(status, fmt.Sprintf(format, args...))
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.title())
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Cause returns the underlying error, for compatibility with github.com/pkg/errors.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) title() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return http.StatusText(e.Status)
+}
+
+// AsError finds the first Error in err's chain, following both Unwrap and the Cause method used by
+// github.com/pkg/errors.
+func AsError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Cause() error }:
+			err = x.Cause()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// Problem is an RFC 9457 problem details document.
+type Problem struct {
+	Type     string       `json:"type,omitempty"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem returns the problem details for an error. Errors that are not (and do not wrap) an
+// Error become a 500 without any detail, so internal messages are not leaked to clients.
+func NewProblem(err error) Problem {
+	e, ok := AsError(err)
+	if !ok || e.Status == 0 {
+		return Problem{
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+		}
+	}
+	return Problem{
+		Title:  e.title(),
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+}
+
+// WriteProblem renders an error as `application/problem+json`.
+func WriteProblem(w http.ResponseWriter, err error) error {
+	p := NewProblem(err)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,45 @@
+package resttransport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paultyng/resttransport"
+)
+
+func TestNewProblem(t *testing.T) {
+	assert := assert.New(t)
+
+	p := resttransport.NewProblem(errors.New("database password is hunter2"))
+	assert.Equal(http.StatusInternalServerError, p.Status)
+	assert.Equal("Internal Server Error", p.Title)
+	assert.Empty(p.Detail)
+
+	err := errors.Wrap(&resttransport.Error{
+		Status: http.StatusBadRequest,
+		Code:   "invalid_id",
+		Detail: "id is invalid",
+		Fields: []resttransport.FieldError{{Field: "id", In: "path", Detail: "expected int"}},
+		Err:    errors.New("invalid syntax"),
+	}, "unable to get foo")
+	p = resttransport.NewProblem(err)
+	assert.Equal(http.StatusBadRequest, p.Status)
+	assert.Equal("Bad Request", p.Title)
+	assert.Equal("invalid_id", p.Code)
+	assert.Equal("id is invalid", p.Detail)
+	assert.Len(p.Errors, 1)
+}
+
+func TestWriteProblem(t *testing.T) {
+	assert := assert.New(t)
+
+	w := httptest.NewRecorder()
+	assert.NoError(resttransport.WriteProblem(w, resttransport.Errorf(http.StatusNotFound, "foo %d not found", 1)))
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Equal(resttransport.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(`{"title":"Not Found","status":404,"detail":"foo 1 not found"}`, w.Body.String())
+}
@@ -0,0 +1,199 @@
+package idempotencytransport
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileStore is a Store keeping a JSON file per key in a directory, which can be shared by the
+// instances of a service on one host. Keys are reserved by creating their file exclusively. Expired
+// files are only replaced when their key is used again, under a lock file.
+type FileStore struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileStore returns a FileStore in dir, which is created on first use.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		dir: dir,
+		now: time.Now,
+	}
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, hash([]byte(key))+".json")
+}
+
+func (s *FileStore) Begin(ctx context.Context, key string, rec *Record) (*Record, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "unable to create idempotency store directory")
+	}
+	reserved := *rec
+	reserved.Response = nil
+	b, err := json.Marshal(&reserved)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal idempotency record")
+	}
+
+	path := s.path(key)
+	// a second attempt follows a record released in between
+	for attempt := 0; attempt < 2; attempt++ {
+		created, err := create(path, b)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return nil, nil
+		}
+
+		existing, ok, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// released since
+			continue
+		}
+		if existing == nil {
+			// still being written by the request that reserved it
+			return &Record{Fingerprint: rec.Fingerprint}, nil
+		}
+		if !s.now().After(existing.Expires) {
+			return existing, nil
+		}
+		return s.replaceExpired(path, rec.Fingerprint, b)
+	}
+	return nil, errors.Errorf("unable to reserve idempotency key")
+}
+
+// staleLock is the age past which the lock of a request that crashed while replacing an expired
+// record is removed.
+const staleLock = time.Minute
+
+// replaceExpired replaces an expired record with a reservation while holding a lock file, and
+// checks it is still expired once locked, so requests racing to replace it can't remove each
+// other's reservations. Requests finding the lock held are told the key is in flight.
+func (s *FileStore) replaceExpired(path, fingerprint string, b []byte) (*Record, error) {
+	inFlight := &Record{Fingerprint: fingerprint}
+
+	lock := path + ".lock"
+	locked, err := create(lock, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lock)
+		}
+		return inFlight, nil
+	}
+	defer os.Remove(lock)
+
+	existing, ok, err := s.read(path)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if existing == nil {
+			return inFlight, nil
+		}
+		if !s.now().After(existing.Expires) {
+			return existing, nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "unable to remove expired idempotency record")
+		}
+	}
+	created, err := create(path, b)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// reserved without the lock by a request that found no record
+		return inFlight, nil
+	}
+	return nil, nil
+}
+
+// create creates a file exclusively with contents b, and returns false if it already exists.
+func create(path string, b []byte) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "unable to create idempotency record")
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return false, errors.Wrap(err, "unable to write idempotency record")
+	}
+	return true, nil
+}
+
+// read returns the record in a file, or nil if it is incomplete, and whether the file exists.
+func (s *FileStore) read(path string) (*Record, bool, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrap(err, "unable to read idempotency record")
+	}
+	rec := &Record{}
+	if err := json.Unmarshal(b, rec); err != nil {
+		return nil, true, nil
+	}
+	return rec, true, nil
+}
+
+func (s *FileStore) Complete(ctx context.Context, key string, resp *Response) error {
+	path := s.path(key)
+	rec, _, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return errors.Errorf("idempotency key is not reserved")
+	}
+	rec.Response = resp
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal idempotency record")
+	}
+
+	// write a temporary file and rename it, so readers never see a partial record
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create idempotency record")
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "unable to write idempotency record")
+	}
+	return nil
+}
+
+func (s *FileStore) Release(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove idempotency record")
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+// Package idempotencytransport makes the handlers of a resttransport safe to retry, using the
+// `Idempotency-Key` request header.
+//
+// The first request with a key runs the handler, and the response it sends with Body or NoBody is
+// kept in a Store. Retries with the same key get the stored response, with an
+// `Idempotent-Replayed: true` header, without running the handler again. A retry while the first
+// request is still in flight is answered with 409 Conflict, and reusing a key for a request with a
+// different path, query or body with 422 Unprocessable Entity.
+//
+// Only routes registered with the Idempotent option are affected, which also declares the 409 and
+// 422 responses so doctransport documents them:
+//
+//	t := idempotencytransport.New(echotransport.New(nil), &idempotencytransport.Config{
+//		Store: idempotencytransport.NewFileStore("/var/lib/api/idempotency"),
+//	})
+//	t.RegisterHandler("POST", "/payments", nil, createPayment, idempotencytransport.Idempotent())
+//
+// Handler errors, server error responses and responses sent any other way are not stored, so the
+// request can be retried. If a response was sent but can't be stored, the key stays reserved until
+// it expires, as the request must not run again. Keys are scoped to the route, and for authenticated
+// handlers, and routes with the resttransport.Security option, to the resttransport.PrincipalID of
+// the user; requests of users without one are rejected. The user is only known once the request is
+// authenticated, so this transport must wrap transports that authenticate in a handler, such as
+// jwt.Transport, rather than be wrapped by them.
+package idempotencytransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+	"github.com/paultyng/resttransport/routename"
+)
+
+const (
+	// KeyHeader is the request header holding the idempotency key.
+	KeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from the Store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+type optionKey int
+
+const (
+	idempotentKey optionKey = iota
+)
+
+// Idempotent opts a route in to idempotency keys, and declares its 409 and 422 responses.
+func Idempotent() resttransport.RouteOption {
+	return func(r *resttransport.Route) {
+		resttransport.WithRouteValue(idempotentKey, true)(r)
+		resttransport.ErrorResponses(http.StatusConflict, http.StatusUnprocessableEntity)(r)
+	}
+}
+
+// Config holds configuration for the idempotency Transport. Store defaults to an in-memory
+// NewMemoryStore, and TTL, how long keys are kept, to 24 hours. If Required is set, requests to
+// idempotent routes without a key are rejected with 400 Bad Request. Codecs encode the stored
+// responses in the content type the inner transport sent them in, and default to codec.Default();
+// responses in other content types are not stored.
+type Config struct {
+	Store    Store
+	TTL      time.Duration
+	Required bool
+	Codecs   *codec.Registry
+}
+
+type idempotencyTransport struct {
+	inner    resttransport.Transport
+	namer    routename.Namer
+	store    Store
+	ttl      time.Duration
+	required bool
+	codecs   *codec.Registry
+}
+
+// New returns a new instance of a resttransport that replays the responses of idempotent routes.
+func New(inner resttransport.Transport, c *Config) resttransport.Transport {
+	if c == nil {
+		c = &Config{}
+	}
+	store := c.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	codecs := c.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+	return &idempotencyTransport{
+		inner:    inner,
+		namer:    routename.New(),
+		store:    store,
+		ttl:      ttl,
+		required: c.Required,
+		codecs:   codecs,
+	}
+}
+
+func (t *idempotencyTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h = t.wrapHandler(false, httpMethod, path, h, opts)
+	return t.inner.RegisterHandler(httpMethod, path, consumes, h, opts...)
+}
+
+func (t *idempotencyTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h = t.wrapHandler(true, httpMethod, path, h, opts)
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, h, opts...)
+}
+
+// RegisterWebSocketHandler registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport. WebSocket connections are not idempotent.
+func (t *idempotencyTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers the authenticated handler on the inner
+// transport, see RegisterWebSocketHandler.
+func (t *idempotencyTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// wrapHandler stores and replays the responses of the handler if the route is idempotent.
+func (t *idempotencyTransport) wrapHandler(auth bool, httpMethod, path string, inner resttransport.Handler, opts []resttransport.RouteOption) resttransport.Handler {
+	route := resttransport.NewRoute(opts...)
+	if idempotent, _ := route.Value(idempotentKey).(bool); !idempotent {
+		return inner
+	}
+	auth = auth || len(route.Security) > 0
+	operation := t.namer.Name(httpMethod, path)
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		key := reqres.RequestHeader().Get(KeyHeader)
+		switch {
+		case key == "" && t.required:
+			return &resttransport.Error{
+				Status: http.StatusBadRequest,
+				Code:   "idempotency_key_required",
+				Detail: fmt.Sprintf("the %s header is required", KeyHeader),
+			}
+		case key == "":
+			return inner(ctx, reqres)
+		case len(key) > maxKeyLength:
+			return &resttransport.Error{
+				Status: http.StatusBadRequest,
+				Code:   "invalid_idempotency_key",
+				Detail: fmt.Sprintf("the %s header can't be longer than %d characters", KeyHeader, maxKeyLength),
+			}
+		}
+
+		scope := ""
+		if auth {
+			if scope = resttransport.PrincipalID(reqres.User()); scope == "" {
+				return errors.Errorf("unable to scope %s for %s, the user has no principal ID", KeyHeader, operation)
+			}
+		}
+		key = operation + "\x00" + scope + "\x00" + key
+
+		body, err := reqres.RawBody()
+		if err != nil {
+			return err
+		}
+		fp := hash([]byte(reqres.URL().RequestURI()), body)
+
+		rec, err := t.store.Begin(ctx, key, &Record{
+			Fingerprint: fp,
+			Expires:     time.Now().Add(t.ttl),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "unable to begin idempotent request for %s", operation)
+		}
+		if rec != nil {
+			switch {
+			case rec.Fingerprint != fp:
+				return &resttransport.Error{
+					Status: http.StatusUnprocessableEntity,
+					Code:   "idempotency_key_reused",
+					Detail: fmt.Sprintf("the %s was already used for a different request", KeyHeader),
+				}
+			case rec.Response == nil:
+				return &resttransport.Error{
+					Status: http.StatusConflict,
+					Code:   "request_in_progress",
+					Detail: fmt.Sprintf("a request with this %s is still in progress", KeyHeader),
+				}
+			}
+			return replay(reqres, rec.Response)
+		}
+
+		rr := &idempotentRequestResponse{RequestResponse: reqres, codecs: t.codecs}
+		defer func() {
+			// release the key on errors and panics, so the request can be retried, unless a response
+			// was sent
+			if rr.response == nil {
+				_ = t.store.Release(context.Background(), key)
+			}
+		}()
+
+		err = inner(ctx, rr)
+		if rr.response == nil {
+			return err
+		}
+		if serr := t.store.Complete(ctx, key, rr.response); serr != nil {
+			// the key stays reserved until it expires, as retries would run the handler again
+			return errors.Wrapf(serr, "unable to store idempotent response for %s", operation)
+		}
+		return err
+	}
+}
+
+// hash returns the hex encoded SHA-256 of the parts, separated by NUL bytes.
+func hash(parts ...[]byte) string {
+	h := sha256.New()
+	for i, p := range parts {
+		if i > 0 {
+			h.Write([]byte{0})
+		}
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay sends a stored response. Headers already set, for example by other middleware, are kept.
+func replay(reqres resttransport.RequestResponse, resp *Response) error {
+	h := reqres.ResponseHeader()
+	for name, values := range resp.Header {
+		if _, ok := h[name]; !ok {
+			h[name] = values
+		}
+	}
+	h.Set(ReplayedHeader, "true")
+	switch {
+	case resp.ContentType != "":
+		return reqres.Blob(resp.Status, resp.ContentType, resp.Body)
+	case resp.Body == nil:
+		return reqres.NoBody(resp.Status)
+	}
+	return reqres.Body(resp.Status, json.RawMessage(resp.Body))
+}
+
+// idempotentRequestResponse captures the response sent by a handler.
+type idempotentRequestResponse struct {
+	resttransport.RequestResponse
+	codecs   *codec.Registry
+	response *Response
+}
+
+// Body keeps the response encoded as the inner transport sent it, in the codec matching the
+// `Content-Type` it set, so replays are identical whatever the retry accepts.
+func (reqres *idempotentRequestResponse) Body(status int, body interface{}) error {
+	h := reqres.ResponseHeader().Clone()
+	if err := reqres.RequestResponse.Body(status, body); err != nil {
+		return err
+	}
+	contentType := reqres.ResponseHeader().Get("Content-Type")
+	if contentType == "" {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal body for idempotent response")
+		}
+		reqres.capture(status, h, "", b)
+		return nil
+	}
+	c, ok := reqres.codecs.ForContentType(contentType, nil)
+	if !ok {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, body); err != nil {
+		return errors.Wrap(err, "unable to encode body for idempotent response")
+	}
+	reqres.capture(status, h, contentType, buf.Bytes())
+	return nil
+}
+
+func (reqres *idempotentRequestResponse) NoBody(status int) error {
+	h := reqres.ResponseHeader().Clone()
+	if err := reqres.RequestResponse.NoBody(status); err != nil {
+		return err
+	}
+	reqres.capture(status, h, "", nil)
+	return nil
+}
+
+// capture keeps a sent response, with the headers set before sending it. Server errors are not
+// kept.
+func (reqres *idempotentRequestResponse) capture(status int, h http.Header, contentType string, body []byte) {
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	reqres.response = &Response{
+		Status:      status,
+		Header:      h,
+		ContentType: contentType,
+		Body:        body,
+	}
+}
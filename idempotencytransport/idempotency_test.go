@@ -0,0 +1,440 @@
+package idempotencytransport
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/echotransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type payment struct {
+	ID     int `json:"id"`
+	Amount int `json:"amount"`
+}
+
+func jsonBody(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func testStores(t *testing.T) map[string]func() Store {
+	return map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"file":   func() Store { return NewFileStore(t.TempDir()) },
+	}
+}
+
+func TestReplay(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			tt := testtransport.New()
+			it := New(tt, &Config{Store: store()})
+
+			calls := 0
+			require.NoError(it.RegisterHandler(http.MethodPost, "/payments", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+				var p payment
+				if err := r.BindBody(&p); err != nil {
+					return err
+				}
+				calls++
+				p.ID = calls
+				r.ResponseHeader().Set("Location", "/payments/1")
+				return r.Body(http.StatusCreated, p)
+			}, Idempotent()))
+			require.NoError(it.RegisterHandler(http.MethodDelete, "/payments/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+				calls++
+				return r.NoBody(http.StatusNoContent)
+			}, Idempotent()))
+
+			post := func(key string, amount int) *testtransport.Response {
+				resp, err := tt.Do(http.MethodPost, "/payments", &testtransport.Request{
+					Header: http.Header{KeyHeader: {key}},
+					Body:   payment{Amount: amount},
+				})
+				require.NoError(err)
+				return resp
+			}
+
+			first := post("a", 100)
+			assert.Equal(http.StatusCreated, first.Status)
+			assert.Empty(first.Header.Get(ReplayedHeader))
+
+			retry := post("a", 100)
+			assert.Equal(http.StatusCreated, retry.Status)
+			assert.Equal("true", retry.Header.Get(ReplayedHeader))
+			assert.Equal("/payments/1", retry.Header.Get("Location"))
+			assert.JSONEq(jsonBody(t, first.Body), jsonBody(t, retry.Body))
+			assert.Equal(1, calls)
+
+			reused := post("a", 200)
+			assert.Equal(http.StatusUnprocessableEntity, reused.Status)
+			assert.Equal("idempotency_key_reused", reused.Body.(resttransport.Problem).Code)
+
+			assert.Equal(http.StatusCreated, post("b", 200).Status)
+			assert.Equal(2, calls)
+
+			// no key, no idempotency
+			resp, err := tt.Do(http.MethodPost, "/payments", &testtransport.Request{Body: payment{Amount: 100}})
+			require.NoError(err)
+			assert.Equal(http.StatusCreated, resp.Status)
+			assert.Equal(3, calls)
+
+			del := func() *testtransport.Response {
+				resp, err := tt.Do(http.MethodDelete, "/payments/{id}", &testtransport.Request{
+					Path:   map[string]string{"id": "1"},
+					Header: http.Header{KeyHeader: {"a"}},
+				})
+				require.NoError(err)
+				return resp
+			}
+			// keys are scoped to the route
+			assert.Equal(http.StatusNoContent, del().Status)
+			resp = del()
+			assert.Equal(http.StatusNoContent, resp.Status)
+			assert.Equal("true", resp.Header.Get(ReplayedHeader))
+			assert.Nil(resp.Body)
+			assert.Equal(4, calls)
+		})
+	}
+}
+
+func TestConcurrentAndFailed(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			tt := testtransport.New()
+			it := New(tt, &Config{Store: store()})
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			fail := true
+			require.NoError(it.RegisterHandler(http.MethodPost, "/payments", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+				if fail {
+					return resttransport.NewError(http.StatusServiceUnavailable, "try again")
+				}
+				close(started)
+				<-release
+				return r.Body(http.StatusCreated, payment{ID: 1})
+			}, Idempotent()))
+
+			post := func() *testtransport.Response {
+				resp, err := tt.Do(http.MethodPost, "/payments", &testtransport.Request{
+					Header: http.Header{KeyHeader: {"a"}},
+					Body:   payment{Amount: 100},
+				})
+				require.NoError(err)
+				return resp
+			}
+
+			// failures release the key
+			assert.Equal(http.StatusServiceUnavailable, post().Status)
+			fail = false
+
+			done := make(chan *testtransport.Response)
+			go func() { done <- post() }()
+			<-started
+
+			resp := post()
+			assert.Equal(http.StatusConflict, resp.Status)
+			assert.Equal("request_in_progress", resp.Body.(resttransport.Problem).Code)
+
+			close(release)
+			assert.Equal(http.StatusCreated, (<-done).Status)
+			assert.Equal("true", post().Header.Get(ReplayedHeader))
+		})
+	}
+}
+
+// failingStore fails to complete requests.
+type failingStore struct {
+	Store
+}
+
+func (s failingStore) Complete(ctx context.Context, key string, resp *Response) error {
+	return errors.New("disk full")
+}
+
+func TestReplay_Codecs(t *testing.T) {
+	type widget struct {
+		XMLName xml.Name `json:"-" xml:"W"`
+		Name    string   `json:"name" xml:"name"`
+	}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			e := echo.New()
+			it := New(echotransport.New(&echotransport.Config{Echo: e}), &Config{Store: store()})
+			calls := 0
+			require.NoError(it.RegisterHandler(http.MethodPost, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+				calls++
+				return r.Body(http.StatusCreated, widget{Name: "a"})
+			}, Idempotent()))
+
+			post := func(key, accept string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/widgets", nil)
+				req.Header.Set(KeyHeader, key)
+				req.Header.Set("Accept", accept)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				return rec
+			}
+
+			for i, accept := range []string{"application/xml", "application/msgpack", "application/cbor"} {
+				key := strconv.Itoa(i)
+				first := post(key, accept)
+				require.Equal(http.StatusCreated, first.Code)
+				assert.Equal(accept, first.Header().Get("Content-Type"))
+
+				// the retry gets the same bytes, even if it accepts another content type
+				for _, retryAccept := range []string{accept, "application/json"} {
+					retry := post(key, retryAccept)
+					assert.Equal(http.StatusCreated, retry.Code)
+					assert.Equal("true", retry.Header().Get(ReplayedHeader))
+					assert.Equal(accept, retry.Header().Get("Content-Type"))
+					assert.Equal(first.Body.Bytes(), retry.Body.Bytes())
+				}
+			}
+			assert.Equal(3, calls)
+			assert.Equal("<W><name>a</name></W>", post("0", "application/xml").Body.String())
+		})
+	}
+}
+
+func TestCompleteFailed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	it := New(tt, &Config{Store: failingStore{NewMemoryStore()}})
+	calls := 0
+	require.NoError(it.RegisterHandler(http.MethodPost, "/payments", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		return r.Body(http.StatusCreated, payment{ID: calls})
+	}, Idempotent()))
+
+	post := func() *testtransport.Response {
+		resp, err := tt.Do(http.MethodPost, "/payments", &testtransport.Request{
+			Header: http.Header{KeyHeader: {"a"}},
+			Body:   payment{Amount: 100},
+		})
+		require.NoError(err)
+		return resp
+	}
+
+	resp := post()
+	assert.Equal(http.StatusCreated, resp.Status)
+	assert.EqualError(resp.Err, "unable to store idempotent response for createPayment: disk full")
+
+	// the payment was made, so the key stays reserved rather than running the handler again
+	assert.Equal(http.StatusConflict, post().Status)
+	assert.Equal(1, calls)
+}
+
+func TestPrincipalScope(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	it := New(tt, nil)
+	calls := 0
+	require.NoError(it.RegisterAuthenticatedHandler(http.MethodPost, "/payments", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		return r.Body(http.StatusCreated, payment{ID: calls})
+	}, Idempotent()))
+
+	post := func(user interface{}, token string, amount int) *testtransport.Response {
+		resp, err := tt.Do(http.MethodPost, "/payments", &testtransport.Request{
+			User:   user,
+			Header: http.Header{KeyHeader: {"a"}, "Authorization": {token}},
+			Body:   payment{Amount: amount},
+		})
+		require.NoError(err)
+		return resp
+	}
+
+	assert.Equal(http.StatusCreated, post("alice", "t1", 100).Status)
+	// a refreshed token is the same user
+	resp := post("alice", "t2", 100)
+	assert.Equal("true", resp.Header.Get(ReplayedHeader))
+	assert.JSONEq(`{"id":1,"amount":0}`, jsonBody(t, resp.Body))
+
+	// other users have their own keys
+	resp = post("bob", "t1", 200)
+	assert.Equal(http.StatusCreated, resp.Status)
+	assert.Empty(resp.Header.Get(ReplayedHeader))
+	assert.Equal(2, calls)
+
+	resp = post(struct{}{}, "t3", 100)
+	assert.Equal(http.StatusInternalServerError, resp.Status)
+	assert.Equal(2, calls)
+}
+
+func TestRequired(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := doctransport.New(tt)
+	it := New(dt, &Config{Required: true})
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.NoBody(http.StatusNoContent)
+	}
+	require.NoError(it.RegisterHandler(http.MethodPost, "/payments", nil, h, Idempotent()))
+	require.NoError(it.RegisterHandler(http.MethodPost, "/refunds", nil, h))
+
+	resp, err := tt.Do(http.MethodPost, "/payments", nil)
+	require.NoError(err)
+	assert.Equal(http.StatusBadRequest, resp.Status)
+	assert.Equal("idempotency_key_required", resp.Body.(resttransport.Problem).Code)
+
+	resp, err = tt.Do(http.MethodPost, "/refunds", nil)
+	require.NoError(err)
+	assert.Equal(http.StatusNoContent, resp.Status)
+
+	s, err := dt.Generate()
+	require.NoError(err)
+	responses := s.Paths.Paths["/payments"].Post.Responses.StatusCodeResponses
+	assert.Contains(responses, http.StatusConflict)
+	assert.Contains(responses, http.StatusUnprocessableEntity)
+	assert.NotContains(s.Paths.Paths["/refunds"].Post.Responses.StatusCodeResponses, http.StatusConflict)
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	memory := NewMemoryStore()
+	memory.now = clock
+	file := NewFileStore(t.TempDir())
+	file.now = clock
+
+	for name, store := range map[string]Store{"memory": memory, "file": file} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx := context.Background()
+
+			rec, err := store.Begin(ctx, "k", &Record{Fingerprint: "a", Expires: now.Add(time.Hour)})
+			require.NoError(err)
+			assert.Nil(rec)
+			require.NoError(store.Complete(ctx, "k", &Response{Status: http.StatusCreated, Body: json.RawMessage(`{"id":1}`)}))
+
+			rec, err = store.Begin(ctx, "k", &Record{Fingerprint: "b", Expires: now.Add(time.Hour)})
+			require.NoError(err)
+			require.NotNil(rec)
+			assert.Equal("a", rec.Fingerprint)
+			require.NotNil(rec.Response)
+			assert.Equal(http.StatusCreated, rec.Response.Status)
+			assert.JSONEq(`{"id":1}`, string(rec.Response.Body))
+
+			rec, err = store.Begin(ctx, "k", &Record{Fingerprint: "b", Expires: now.Add(3 * time.Hour)})
+			require.NoError(err)
+			assert.NotNil(rec)
+
+			now = now.Add(2 * time.Hour)
+			rec, err = store.Begin(ctx, "k", &Record{Fingerprint: "b", Expires: now.Add(time.Hour)})
+			require.NoError(err)
+			assert.Nil(rec)
+
+			require.NoError(store.Release(ctx, "k"))
+			rec, err = store.Begin(ctx, "k", &Record{Fingerprint: "c", Expires: now.Add(time.Hour)})
+			require.NoError(err)
+			assert.Nil(rec)
+		})
+	}
+}
+
+func TestFileStore_ReplaceExpired(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	store.now = func() time.Time { return now }
+	_, err := store.Begin(ctx, "k", &Record{Fingerprint: "a", Expires: now.Add(-time.Hour)})
+	require.NoError(err)
+	require.NoError(store.Complete(ctx, "k", &Response{Status: http.StatusOK}))
+
+	// requests racing to replace the expired record reserve the key once
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		won     int
+		lastErr error
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec, err := store.Begin(ctx, "k", &Record{Fingerprint: "b", Expires: now.Add(time.Hour)})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+			} else if rec == nil {
+				won++
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(lastErr)
+	assert.Equal(1, won)
+	_, err = os.Stat(store.path("k") + ".lock")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	it := New(tt, nil)
+
+	reply := func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		var m string
+		if err := conn.Read(&m); err != nil {
+			return err
+		}
+		return conn.Write(m)
+	}
+	require.NoError(resttransport.RegisterWebSocketHandler(it, "/rooms", reply))
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(it, "/private", reply))
+
+	for path, user := range map[string]interface{}{"/rooms": nil, "/private": "alice"} {
+		client, err := tt.WebSocket(path, &testtransport.Request{User: user})
+		require.NoError(err)
+		require.NoError(client.Send("hi"))
+		var m string
+		require.NoError(client.Receive(&m))
+		assert.Equal("hi", m)
+		code, _, _ := client.Wait()
+		assert.Equal(resttransport.CloseNormal, code)
+	}
+}
@@ -0,0 +1,99 @@
+package idempotencytransport
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	// ContentType is the media type Body is encoded in. It is empty if the transport didn't set
+	// one, and Body holds the JSON payload sent with Body.
+	ContentType string `json:"contentType,omitempty"`
+	// Body is the encoded payload sent with Body, or nil if the response was sent with NoBody.
+	Body []byte `json:"body,omitempty"`
+}
+
+// Record is the state of an idempotency key.
+type Record struct {
+	// Fingerprint identifies the request the key was first used for.
+	Fingerprint string    `json:"fingerprint"`
+	Expires     time.Time `json:"expires"`
+	// Response is nil while the first request is in flight.
+	Response *Response `json:"response,omitempty"`
+}
+
+// Store keeps idempotency keys and their responses. Implementations must be safe for concurrent
+// use, and treat records past their expiry as absent.
+type Store interface {
+	// Begin reserves key with a record without a Response. If key is already reserved, it returns
+	// the existing record instead.
+	Begin(ctx context.Context, key string, rec *Record) (*Record, error)
+	// Complete sets the response of a reserved key.
+	Complete(ctx context.Context, key string, resp *Response) error
+	// Release removes a key, so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store holding records in memory, for a single instance of a service.
+type MemoryStore struct {
+	sync.Mutex
+	now       func() time.Time
+	records   map[string]*Record
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		records: map[string]*Record{},
+	}
+}
+
+// sweepInterval is how often expired records are dropped from a MemoryStore.
+const sweepInterval = time.Minute
+
+func (s *MemoryStore) Begin(ctx context.Context, key string, rec *Record) (*Record, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.lastSweep = now
+		for k, r := range s.records {
+			if now.After(r.Expires) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	if existing, ok := s.records[key]; ok && !now.After(existing.Expires) {
+		r := *existing
+		return &r, nil
+	}
+	r := *rec
+	r.Response = nil
+	s.records[key] = &r
+	return nil, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, resp *Response) error {
+	s.Lock()
+	defer s.Unlock()
+	if rec, ok := s.records[key]; ok {
+		rec.Response = resp
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package bind holds the struct binding shared by the resttransport implementations.
+package bind
+
+import (
+	"github.com/gorilla/schema"
+)
+
+var (
+	pathDecoder  = schema.NewDecoder()
+	queryDecoder = schema.NewDecoder()
+	formDecoder  = schema.NewDecoder()
+)
+
+func init() {
+	pathDecoder.SetAliasTag("path")
+	queryDecoder.SetAliasTag("query")
+	formDecoder.SetAliasTag("form")
+}
+
+func decode(in string, d *schema.Decoder, v interface{}, values map[string][]string) error {
+	if err := d.Decode(v, values); err != nil {
+		return Error(in, err)
+	}
+	return Validate(in, v)
+}
+
+// Path binds a struct to path variables using `path` struct tags and validates it.
+func Path(v interface{}, values map[string][]string) error {
+	return decode("path", pathDecoder, v, values)
+}
+
+// Query binds a struct to query string variables using `query` struct tags and validates it.
+func Query(v interface{}, values map[string][]string) error {
+	return decode("query", queryDecoder, v, values)
+}
+
+// Form binds a struct to form values using `form` struct tags and validates it.
+func Form(v interface{}, values map[string][]string) error {
+	return decode("form", formDecoder, v, values)
+}
@@ -0,0 +1,66 @@
+package bind
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/validate"
+)
+
+func TestQuery_Error(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	v := struct {
+		Page  int  `query:"page"`
+		Limit int  `query:"limit"`
+		Desc  bool `query:"desc"`
+	}{}
+	err := Query(&v, map[string][]string{
+		"page":  {"one"},
+		"limit": {"ten"},
+		"desc":  {"true"},
+	})
+	require.Error(err)
+
+	e, ok := resttransport.AsError(err)
+	require.True(ok)
+	assert.Equal(http.StatusBadRequest, e.Status)
+	require.Len(e.Fields, 2)
+	assert.Equal("limit", e.Fields[0].Field)
+	assert.Equal("query", e.Fields[0].In)
+	assert.Equal("page", e.Fields[1].Field)
+	assert.True(v.Desc)
+}
+
+func TestQuery_Validation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	v := struct {
+		Page  int    `query:"page" validate:"min=1"`
+		Order string `query:"order" validate:"enum=asc|desc"`
+	}{}
+	err := Query(&v, map[string][]string{
+		"page":  {"0"},
+		"order": {"up"},
+	})
+	require.Error(err)
+
+	e, ok := resttransport.AsError(err)
+	require.True(ok)
+	assert.Equal(http.StatusUnprocessableEntity, e.Status)
+	assert.Equal([]resttransport.FieldError{
+		{Field: "page", In: "query", Detail: "value must be at least 1"},
+		{Field: "order", In: "query", Detail: "must be one of asc, desc"},
+	}, e.Fields)
+
+	var verrs validate.Errors
+	assert.True(errors.As(err, &verrs))
+	assert.Len(verrs, 2)
+}
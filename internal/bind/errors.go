@@ -0,0 +1,67 @@
+package bind
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/schema"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Error maps a binding error for a location (`path`, `query`, `body` or `form`) to a 400 Bad
+// Request with per-field detail where the underlying error provides it.
+func Error(in string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := resttransport.AsError(err); ok {
+		return err
+	}
+
+	e := &resttransport.Error{
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("unable to bind %s", in),
+		Err:    err,
+	}
+
+	switch err := err.(type) {
+	case schema.MultiError:
+		keys := make([]string, 0, len(err))
+		for k := range err {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.Fields = append(e.Fields, fieldError(in, k, err[k]))
+		}
+	case schema.ConversionError:
+		e.Fields = append(e.Fields, fieldError(in, err.Key, err))
+	case *json.UnmarshalTypeError:
+		e.Fields = append(e.Fields, resttransport.FieldError{
+			Field:  err.Field,
+			In:     in,
+			Detail: fmt.Sprintf("expected %s but got %s", err.Type, err.Value),
+		})
+	case *json.SyntaxError:
+		e.Detail = fmt.Sprintf("malformed %s at offset %d: %s", in, err.Offset, err.Error())
+	default:
+		e.Detail = fmt.Sprintf("unable to bind %s: %s", in, err.Error())
+	}
+
+	return e
+}
+
+func fieldError(in, key string, err error) resttransport.FieldError {
+	detail := err.Error()
+	if ce, ok := err.(schema.ConversionError); ok {
+		detail = fmt.Sprintf("expected %s", ce.Type)
+	}
+	return resttransport.FieldError{
+		Field:  key,
+		In:     in,
+		Detail: detail,
+	}
+}
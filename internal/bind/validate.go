@@ -0,0 +1,53 @@
+package bind
+
+import (
+	"net/http"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/validate"
+)
+
+// nameTags maps a binding location to the struct tag naming its fields.
+var nameTags = map[string]string{
+	"path":  "path",
+	"query": "query",
+	"form":  "form",
+	"body":  "json",
+}
+
+// Validate checks a bound value against its `validate` struct tags. Failures are returned as a 422
+// Unprocessable Entity whose cause is the validate.Errors, so handlers can inspect them.
+func Validate(in string, v interface{}) error {
+	err := validate.Struct(v, nameTags[in])
+	if err == nil {
+		return nil
+	}
+	verrs, ok := err.(validate.Errors)
+	if !ok {
+		// a bad tag or a value that can't be validated is a programming error
+		return err
+	}
+
+	e := &resttransport.Error{
+		Status: http.StatusUnprocessableEntity,
+		Detail: "request validation failed",
+		Err:    verrs,
+	}
+	for _, fe := range verrs {
+		e.Fields = append(e.Fields, resttransport.FieldError{
+			Field:  fe.Path,
+			In:     in,
+			Detail: fe.Reason,
+		})
+	}
+	return e
+}
+
+// Body validates a value decoded from the request body, passing through any decoding error as a
+// bind error.
+func Body(v interface{}, err error) error {
+	if err != nil {
+		return Error("body", err)
+	}
+	return Validate("body", v)
+}
@@ -0,0 +1,104 @@
+// Package observe records what a handler sent through a RequestResponse for wrapping transports.
+package observe
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Recorder wraps a RequestResponse and records the response sent by the handler.
+type Recorder struct {
+	resttransport.RequestResponse
+	sent   bool
+	status int
+	events resttransport.EventSink
+}
+
+// New returns a Recorder wrapping inner.
+func New(inner resttransport.RequestResponse) *Recorder {
+	return &Recorder{
+		RequestResponse: inner,
+	}
+}
+
+func (r *Recorder) record(status int) {
+	if r.sent {
+		return
+	}
+	r.sent = true
+	r.status = status
+}
+
+// Body records the status.
+func (r *Recorder) Body(status int, body interface{}) error {
+	r.record(status)
+	return r.RequestResponse.Body(status, body)
+}
+
+// NoBody records the status.
+func (r *Recorder) NoBody(status int) error {
+	r.record(status)
+	return r.RequestResponse.NoBody(status)
+}
+
+// Blob records the status.
+func (r *Recorder) Blob(status int, contentType string, b []byte) error {
+	r.record(status)
+	return r.RequestResponse.Blob(status, contentType, b)
+}
+
+// Stream records the status.
+func (r *Recorder) Stream(status int, contentType string, body io.Reader) error {
+	r.record(status)
+	return r.RequestResponse.Stream(status, contentType, body)
+}
+
+// Events records a 200 and the event stream.
+func (r *Recorder) Events(ctx context.Context) resttransport.EventSink {
+	r.record(http.StatusOK)
+	r.events = r.RequestResponse.Events(ctx)
+	return r.events
+}
+
+// EventStream returns the event stream the handler started, or nil. It outlives the handler until
+// the transport closes it.
+func (r *Recorder) EventStream() resttransport.EventSink {
+	return r.events
+}
+
+// Redirect records the status.
+func (r *Recorder) Redirect(status int, location string) error {
+	r.record(status)
+	return r.RequestResponse.Redirect(status, location)
+}
+
+// Attachment records a 200.
+func (r *Recorder) Attachment(file, name, contentType string) error {
+	r.record(http.StatusOK)
+	return r.RequestResponse.Attachment(file, name, contentType)
+}
+
+// Sent reports whether the handler sent a response.
+func (r *Recorder) Sent() bool {
+	return r.sent
+}
+
+// Status returns the status of the response. If the handler did not send one, this is the status
+// the transport renders err with, or 200 if there was no error.
+func (r *Recorder) Status(err error) int {
+	if r.sent {
+		return r.status
+	}
+	if err != nil {
+		return resttransport.NewProblem(err).Status
+	}
+	return http.StatusOK
+}
+
+// Size returns the size of the response body in bytes, as written by the transport.
+func (r *Recorder) Size() int64 {
+	return r.RequestResponse.ResponseSize()
+}
@@ -2,16 +2,19 @@ package resttransport
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 )
 
 // Transport represents the mapping between an API and the underlying communication infrastructure.
 // Handlers can be registered with or without authentication. URL variable annotations should follow
-// the form `/foo/{id}` where brackets are used to denote path parameters.
+// the form `/foo/{id}` where brackets are used to denote path parameters. Route options describe
+// the route further, see Route.
 type Transport interface {
-	RegisterHandler(httpMethod, path string, consumes []string, h Handler) error
-	RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h Handler) error
+	RegisterHandler(httpMethod, path string, consumes []string, h Handler, opts ...RouteOption) error
+	RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h Handler, opts ...RouteOption) error
 }
 
 // RequestResponse represents the handlers contract for reading request data and responding. Both
@@ -34,6 +37,16 @@ type Transport interface {
 //	}
 type RequestResponse interface {
 	RequestHeader() http.Header
+	// RemoteAddr returns the network address of the client, usually `host:port` as in
+	// http.Request.RemoteAddr. Proxy headers such as `X-Forwarded-For` are not taken into account.
+	RemoteAddr() string
+	// URL returns the requested URL, with the path parameter values in its path.
+	URL() *url.URL
+	// Preconditions returns the `If-Match` and `If-Unmodified-Since` preconditions of the request,
+	// for handlers to check against the current version of the resource before updating it.
+	Preconditions() Preconditions
+	// ResponseHeader returns the response headers, which can be modified until the response is sent.
+	ResponseHeader() http.Header
 
 	// BindQuery binds a struct to query string variables extracted from the requested URL.
 	BindQuery(interface{}) error
@@ -42,8 +55,12 @@ type RequestResponse interface {
 	BindBody(interface{}) error
 	// BindPath binds a struct to path variables extracted from the requested URL.
 	BindPath(interface{}) error
+	// RawBody returns the request body without decoding it. The body is buffered, so it can still be
+	// bound with BindBody afterwards.
+	RawBody() ([]byte, error)
 
-	// User returns current user state/context (differs based on transport implementations).
+	// User returns current user state/context (differs based on transport implementations). The
+	// same value is stored in the handler's context, see UserAs and PrincipalAs.
 	User() interface{}
 
 	// FormFile retrieves a file (by name) from the request
@@ -60,6 +77,19 @@ type RequestResponse interface {
 	Body(status int, body interface{}) error
 	// NoBody sends a response with only a status code.
 	NoBody(status int) error
+	// Blob sends raw bytes as the response body with the given status code and content type,
+	// bypassing the transports marshaling.
+	Blob(status int, contentType string, b []byte) error
+	// Stream sends the response body read from r with the given status code and content type,
+	// flushing it to the client as it is read. It returns once r is exhausted or fails, or the
+	// client goes away.
+	Stream(status int, contentType string, r io.Reader) error
+	// Events starts a 200 `text/event-stream` response, for sending Server-Sent Events until the
+	// handler returns, ctx is done or the client goes away.
+	Events(ctx context.Context) EventSink
+	// ResponseSize returns the number of response body bytes written so far, as encoded by the
+	// transport.
+	ResponseSize() int64
 }
 
 // Handler represents a func that processes a RequestResponse.
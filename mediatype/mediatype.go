@@ -0,0 +1,172 @@
+// Package mediatype parses and matches HTTP media types for content negotiation.
+package mediatype
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MediaType is a parsed media type, optionally with a wildcard type or subtype.
+type MediaType struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+	// Quality is the `q` parameter of an Accept header entry (defaults to 1).
+	Quality float64
+}
+
+// Parse parses a media type such as `application/json; charset=utf-8` or `application/*`.
+func Parse(s string) (MediaType, error) {
+	mt, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return MediaType{}, errors.Wrapf(err, "unable to parse media type '%s'", s)
+	}
+	parts := strings.SplitN(mt, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return MediaType{}, errors.Errorf("invalid media type '%s'", s)
+	}
+
+	m := MediaType{
+		Type:    parts[0],
+		Subtype: parts[1],
+		Params:  map[string]string{},
+		Quality: 1,
+	}
+	for k, v := range params {
+		if k == "q" {
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return MediaType{}, errors.Wrapf(err, "invalid quality in '%s'", s)
+			}
+			m.Quality = q
+			continue
+		}
+		// charset values are case insensitive
+		if k == "charset" {
+			v = strings.ToLower(v)
+		}
+		m.Params[k] = v
+	}
+	return m, nil
+}
+
+// String returns the media type without parameters.
+func (m MediaType) String() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// Match reports whether candidate is matched by m, treating m as a pattern. Wildcards in m match
+// any type or subtype, and every parameter in m must be present with the same value in candidate.
+// Parameters of candidate that m does not mention are ignored.
+func (m MediaType) Match(candidate MediaType) bool {
+	if m.Type != "*" && m.Type != candidate.Type {
+		return false
+	}
+	if m.Subtype != "*" && m.Subtype != candidate.Subtype {
+		return false
+	}
+	for k, v := range m.Params {
+		if candidate.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity ranks more specific media types higher when qualities are equal.
+func (m MediaType) specificity() int {
+	switch {
+	case m.Type == "*":
+		return 0
+	case m.Subtype == "*":
+		return 1
+	}
+	return 2 + len(m.Params)
+}
+
+// Matches reports whether contentType is matched by any of the patterns. Unparseable patterns are
+// ignored.
+func Matches(patterns []string, contentType string) bool {
+	ct, err := Parse(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		pm, err := Parse(p)
+		if err != nil {
+			continue
+		}
+		if pm.Match(ct) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAccept parses an Accept header into media types ordered by preference. Invalid entries are
+// skipped, and an empty header accepts anything.
+func ParseAccept(accept string) []MediaType {
+	if strings.TrimSpace(accept) == "" {
+		return []MediaType{{Type: "*", Subtype: "*", Params: map[string]string{}, Quality: 1}}
+	}
+
+	accepted := []MediaType{}
+	for _, part := range strings.Split(accept, ",") {
+		m, err := Parse(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		accepted = append(accepted, m)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].Quality != accepted[j].Quality {
+			return accepted[i].Quality > accepted[j].Quality
+		}
+		return accepted[i].specificity() > accepted[j].specificity()
+	})
+	return accepted
+}
+
+// Negotiate returns the offer that best satisfies the Accept header. Offers are expected in the
+// server's order of preference, which breaks ties between equally acceptable offers.
+func Negotiate(accept string, offers []string) (string, bool) {
+	type offer struct {
+		raw string
+		m   MediaType
+	}
+	parsed := make([]offer, 0, len(offers))
+	for _, o := range offers {
+		m, err := Parse(o)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, offer{o, m})
+	}
+
+	for _, a := range ParseAccept(accept) {
+		if a.Quality <= 0 {
+			continue
+		}
+		for _, o := range parsed {
+			if a.Match(o.m) && !excluded(accept, o.m) {
+				return o.raw, true
+			}
+		}
+	}
+	return "", false
+}
+
+// excluded reports whether the Accept header explicitly refuses m with `q=0`.
+func excluded(accept string, m MediaType) bool {
+	for _, a := range ParseAccept(accept) {
+		if a.Quality <= 0 && a.specificity() >= 2 && a.Match(m) {
+			return true
+		}
+	}
+	return false
+}
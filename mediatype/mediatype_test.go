@@ -0,0 +1,64 @@
+package mediatype
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	for i, c := range []struct {
+		expected    bool
+		patterns    []string
+		contentType string
+	}{
+		{true, []string{"application/json"}, "application/json"},
+		{true, []string{"application/json"}, "application/json; charset=UTF-8"},
+		{true, []string{"application/xml", "application/json"}, "application/json"},
+		{false, []string{"application/xml"}, "application/json"},
+		{true, []string{"application/*"}, "application/json"},
+		{false, []string{"text/*"}, "application/json"},
+		{true, []string{"*/*"}, "image/png"},
+		{true, []string{"text/plain; charset=utf-8"}, "text/plain; charset=UTF-8"},
+		{false, []string{"text/plain; charset=utf-8"}, "text/plain; charset=iso-8859-1"},
+		{false, []string{"text/plain; charset=utf-8"}, "text/plain"},
+		{false, []string{"application/json"}, ""},
+		{false, []string{"application/json"}, "json"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.contentType), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := Matches(c.patterns, c.contentType)
+			assert.Equal(c.expected, actual)
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "application/cbor"}
+
+	for i, c := range []struct {
+		expected string
+		ok       bool
+		accept   string
+	}{
+		{"application/json", true, ""},
+		{"application/json", true, "*/*"},
+		{"application/xml", true, "application/xml"},
+		{"application/xml", true, "text/html, application/xml;q=0.9, */*;q=0.8"},
+		{"application/cbor", true, "application/json;q=0.5, application/cbor"},
+		{"application/xml", true, "application/json;q=0, application/*"},
+		{"application/json", true, "application/*"},
+		{"", false, "text/html"},
+		{"", false, "application/json;q=0, application/xml;q=0, application/cbor;q=0"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.accept), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, ok := Negotiate(c.accept, offers)
+			assert.Equal(c.ok, ok)
+			assert.Equal(c.expected, actual)
+		})
+	}
+}
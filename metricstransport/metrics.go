@@ -0,0 +1,162 @@
+// Package metricstransport records Prometheus metrics for the handlers of a resttransport.
+package metricstransport
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/internal/observe"
+	"github.com/paultyng/resttransport/routename"
+)
+
+// MetricsTransport is the interface for a Transport that records metrics.
+type MetricsTransport interface {
+	resttransport.Transport
+	// Registry returns the registry holding the recorded metrics.
+	Registry() *prometheus.Registry
+	// Handler returns an http.Handler that serves the registry for scraping.
+	Handler() http.Handler
+}
+
+// Config holds configuration information for the metrics Transport. If Registry is nil a new one is
+// created, and buckets default to prometheus.DefBuckets for latency and exponential buckets from
+// 100 bytes to 100MB for response sizes.
+type Config struct {
+	Namespace      string
+	Registry       *prometheus.Registry
+	LatencyBuckets []float64
+	SizeBuckets    []float64
+	ConstantLabels prometheus.Labels
+}
+
+type metricsTransport struct {
+	inner    resttransport.Transport
+	namer    routename.Namer
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	sizes    *prometheus.HistogramVec
+}
+
+var (
+	labels         = []string{"operation", "method", "status"}
+	inFlightLabels = []string{"operation", "method"}
+)
+
+// New returns a new instance of a resttransport that records request count, latency, in flight
+// requests and response sizes labeled by operation name, method and status code.
+func New(inner resttransport.Transport, c *Config) (MetricsTransport, error) {
+	if c == nil {
+		c = &Config{}
+	}
+	registry := c.Registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	latencyBuckets := c.LatencyBuckets
+	if latencyBuckets == nil {
+		latencyBuckets = prometheus.DefBuckets
+	}
+	sizeBuckets := c.SizeBuckets
+	if sizeBuckets == nil {
+		sizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+
+	t := &metricsTransport{
+		inner:    inner,
+		namer:    routename.New(),
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.Namespace,
+			Name:        "http_requests_total",
+			Help:        "Total number of HTTP requests handled.",
+			ConstLabels: c.ConstantLabels,
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.Namespace,
+			Name:        "http_request_duration_seconds",
+			Help:        "Latency of HTTP requests.",
+			ConstLabels: c.ConstantLabels,
+			Buckets:     latencyBuckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   c.Namespace,
+			Name:        "http_requests_in_flight",
+			Help:        "Number of HTTP requests currently being handled.",
+			ConstLabels: c.ConstantLabels,
+		}, inFlightLabels),
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.Namespace,
+			Name:        "http_response_size_bytes",
+			Help:        "Size of HTTP response bodies in bytes.",
+			ConstLabels: c.ConstantLabels,
+			Buckets:     sizeBuckets,
+		}, labels),
+	}
+
+	for _, col := range []prometheus.Collector{t.requests, t.latency, t.inFlight, t.sizes} {
+		if err := registry.Register(col); err != nil {
+			return nil, errors.Wrap(err, "unable to register collector")
+		}
+	}
+
+	return t, nil
+}
+
+func (t *metricsTransport) Registry() *prometheus.Registry {
+	return t.registry
+}
+
+func (t *metricsTransport) Handler() http.Handler {
+	return promhttp.HandlerFor(t.registry, promhttp.HandlerOpts{})
+}
+
+func (t *metricsTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
+}
+
+func (t *metricsTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
+}
+
+// RegisterWebSocketHandler registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport. WebSocket connections are not measured.
+func (t *metricsTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers the authenticated handler on the inner
+// transport, see RegisterWebSocketHandler.
+func (t *metricsTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, h, opts...)
+}
+
+func (t *metricsTransport) wrapHandler(httpMethod, path string, inner resttransport.Handler) resttransport.Handler {
+	operation := t.namer.Name(httpMethod, path)
+	inFlight := t.inFlight.WithLabelValues(operation, httpMethod)
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		rec := observe.New(reqres)
+		err := inner(ctx, rec)
+
+		status := strconv.Itoa(rec.Status(err))
+		t.requests.WithLabelValues(operation, httpMethod, status).Inc()
+		t.latency.WithLabelValues(operation, httpMethod, status).Observe(time.Since(start).Seconds())
+		t.sizes.WithLabelValues(operation, httpMethod, status).Observe(float64(rec.Size()))
+
+		return err
+	}
+}
@@ -0,0 +1,119 @@
+package metricstransport_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/codec"
+	"github.com/paultyng/resttransport/echotransport"
+	"github.com/paultyng/resttransport/metricstransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func getFoo(ctx context.Context, r resttransport.RequestResponse) error {
+	pathParams := struct {
+		ID string `path:"id"`
+	}{}
+	if err := r.BindPath(&pathParams); err != nil {
+		return err
+	}
+	if pathParams.ID == "missing" {
+		return resttransport.NewError(http.StatusNotFound, "")
+	}
+	return r.Body(http.StatusOK, map[string]string{"id": pathParams.ID})
+}
+
+func TestTransport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	mt, err := metricstransport.New(tt, &metricstransport.Config{Namespace: "api"})
+	require.NoError(err)
+	require.NoError(mt.RegisterHandler(http.MethodGet, "/foos/{id}", nil, getFoo))
+
+	for _, id := range []string{"1", "2", "missing"} {
+		_, err := tt.Do(http.MethodGet, "/foos/{id}", &testtransport.Request{
+			Path: map[string]string{"id": id},
+		})
+		require.NoError(err)
+	}
+
+	err = testutil.GatherAndCompare(mt.Registry(), strings.NewReader(`
+# HELP api_http_requests_total Total number of HTTP requests handled.
+# TYPE api_http_requests_total counter
+api_http_requests_total{method="GET",operation="getFoo",status="200"} 2
+api_http_requests_total{method="GET",operation="getFoo",status="404"} 1
+# HELP api_http_requests_in_flight Number of HTTP requests currently being handled.
+# TYPE api_http_requests_in_flight gauge
+api_http_requests_in_flight{method="GET",operation="getFoo"} 0
+`), "api_http_requests_total", "api_http_requests_in_flight")
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	mt.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `api_http_request_duration_seconds_count{method="GET",operation="getFoo",status="200"} 2`)
+	assert.Contains(w.Body.String(), `api_http_response_size_bytes_sum{method="GET",operation="getFoo",status="200"} 20`)
+}
+
+func TestTransport_Size(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	e := echo.New()
+	mt, err := metricstransport.New(echotransport.New(&echotransport.Config{Echo: e}), nil)
+	require.NoError(err)
+	require.NoError(mt.RegisterHandler(http.MethodGet, "/foos/{id}", nil, getFoo))
+
+	// the size is counted as written, in the negotiated encoding
+	req := httptest.NewRequest(http.MethodGet, "/foos/1", nil)
+	req.Header.Set("Accept", codec.MessagePack.ContentType())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(http.StatusOK, rec.Code)
+
+	w := httptest.NewRecorder()
+	mt.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(w.Body.String(), fmt.Sprintf(`http_response_size_bytes_sum{method="GET",operation="getFoo",status="200"} %d`, rec.Body.Len()))
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	mt, err := metricstransport.New(tt, nil)
+	require.NoError(err)
+
+	reply := func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		var m string
+		if err := conn.Read(&m); err != nil {
+			return err
+		}
+		return conn.Write(m)
+	}
+	require.NoError(resttransport.RegisterWebSocketHandler(mt, "/rooms", reply))
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(mt, "/private", reply))
+
+	for path, user := range map[string]interface{}{"/rooms": nil, "/private": "alice"} {
+		client, err := tt.WebSocket(path, &testtransport.Request{User: user})
+		require.NoError(err)
+		require.NoError(client.Send("hi"))
+		var m string
+		require.NoError(client.Receive(&m))
+		assert.Equal("hi", m)
+		code, _, _ := client.Wait()
+		assert.Equal(resttransport.CloseNormal, code)
+	}
+}
@@ -0,0 +1,393 @@
+// Package nethttptransport wraps the standard library http.ServeMux for use as a resttransport
+// implementation.
+package nethttptransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/internal/bind"
+	"github.com/paultyng/resttransport/mediatype"
+)
+
+const defaultMaxMemory = 32 << 20
+
+type contextKey string
+
+// DefaultUserContextKey is the request context key used to look up the user when
+// Config.UserContextKey is not set.
+const DefaultUserContextKey = contextKey("user")
+
+// Middleware wraps an http.Handler, typically to authenticate a request and store the user in the
+// request context.
+type Middleware func(http.Handler) http.Handler
+
+// ErrorHandler renders an error returned from a resttransport.Handler. It is only called if the
+// handler has not already written a response.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Config holds configuration information for the net/http Transport. Mux defaults to a new
+// http.ServeMux, so handlers are never registered on http.DefaultServeMux unless it is passed in.
+// ErrorHandler defaults to RenderProblem.
+//
+// Authenticators are keyed by security scheme name. Routes registered with the
+// resttransport.Security option are authenticated by them instead of AuthenticationMiddleware, and
+// the authenticated user is stored in the request context under UserContextKey.
+//
+// EventKeepAlive is how often a comment is sent on idle event streams, defaults to
+// resttransport.DefaultEventKeepAlive, and a negative value disables it.
+type Config struct {
+	Mux                      *http.ServeMux
+	AuthenticationMiddleware []Middleware
+	UserContextKey           interface{}
+	ErrorHandler             ErrorHandler
+	Authenticators           map[string]resttransport.Authenticator
+	EventKeepAlive           time.Duration
+}
+
+type netHTTPTransport struct {
+	mux                      *http.ServeMux
+	authenticationMiddleware []Middleware
+	userKey                  interface{}
+	errorHandler             ErrorHandler
+	authenticators           map[string]resttransport.Authenticator
+	eventKeepAlive           time.Duration
+}
+
+// New returns a Transport that registers handlers on an http.ServeMux. The Transport is also an
+// http.Handler serving the mux.
+func New(c *Config) resttransport.Transport {
+	if c == nil {
+		c = &Config{}
+	}
+	mux := c.Mux
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	userKey := c.UserContextKey
+	if userKey == nil {
+		userKey = DefaultUserContextKey
+	}
+	errorHandler := c.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = RenderProblem
+	}
+	eventKeepAlive := c.EventKeepAlive
+	switch {
+	case eventKeepAlive == 0:
+		eventKeepAlive = resttransport.DefaultEventKeepAlive
+	case eventKeepAlive < 0:
+		eventKeepAlive = 0
+	}
+	return &netHTTPTransport{
+		mux:                      mux,
+		authenticationMiddleware: c.AuthenticationMiddleware,
+		userKey:                  userKey,
+		errorHandler:             errorHandler,
+		authenticators:           c.Authenticators,
+		eventKeepAlive:           eventKeepAlive,
+	}
+}
+
+// ServeHTTP dispatches the request to the handler registered on the mux.
+func (t *netHTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mux.ServeHTTP(w, r)
+}
+
+// RenderProblem renders errors as RFC 9457 `application/problem+json`.
+func RenderProblem(w http.ResponseWriter, r *http.Request, err error) {
+	_ = resttransport.WriteProblem(w, err)
+}
+
+// responseWriter tracks whether a response has been committed so errors returned after writing
+// are not rendered on top of it, and counts the body bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	committed bool
+	size      int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.committed = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.committed = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client, if the wrapped writer supports it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type netHTTPRequestResponse struct {
+	userKey        interface{}
+	paramNames     []string
+	w              *responseWriter
+	r              *http.Request
+	raw            []byte
+	eventKeepAlive time.Duration
+	events         resttransport.EventSink
+}
+
+func (rr *netHTTPRequestResponse) RequestHeader() http.Header {
+	return rr.r.Header
+}
+
+func (rr *netHTTPRequestResponse) RemoteAddr() string {
+	return rr.r.RemoteAddr
+}
+
+func (rr *netHTTPRequestResponse) URL() *url.URL {
+	return rr.r.URL
+}
+
+func (rr *netHTTPRequestResponse) Preconditions() resttransport.Preconditions {
+	return resttransport.ParsePreconditions(rr.r.Header)
+}
+
+func (rr *netHTTPRequestResponse) BindQuery(v interface{}) error {
+	return bind.Query(v, rr.r.URL.Query())
+}
+
+func (rr *netHTTPRequestResponse) BindBody(v interface{}) error {
+	if rr.r.ContentLength == 0 {
+		return resttransport.NewError(http.StatusBadRequest, "request body can't be empty")
+	}
+	ctype := rr.r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ctype, "application/json"):
+		return bind.Body(v, json.NewDecoder(rr.r.Body).Decode(v))
+	case strings.HasPrefix(ctype, "application/xml"), strings.HasPrefix(ctype, "text/xml"):
+		return bind.Body(v, xml.NewDecoder(rr.r.Body).Decode(v))
+	case strings.HasPrefix(ctype, "application/x-www-form-urlencoded"):
+		if err := rr.r.ParseForm(); err != nil {
+			return bind.Error("form", err)
+		}
+		return bind.Form(v, rr.r.PostForm)
+	case strings.HasPrefix(ctype, "multipart/form-data"):
+		if err := rr.r.ParseMultipartForm(defaultMaxMemory); err != nil {
+			return bind.Error("form", err)
+		}
+		return bind.Form(v, rr.r.MultipartForm.Value)
+	default:
+		return resttransport.Errorf(http.StatusUnsupportedMediaType, "unsupported content type '%s'", ctype)
+	}
+}
+
+func (rr *netHTTPRequestResponse) ResponseHeader() http.Header {
+	return rr.w.Header()
+}
+
+func (rr *netHTTPRequestResponse) RawBody() ([]byte, error) {
+	if rr.raw == nil {
+		b, err := io.ReadAll(rr.r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read request body")
+		}
+		rr.r.Body = io.NopCloser(bytes.NewReader(b))
+		rr.raw = b
+	}
+	return rr.raw, nil
+}
+
+func (rr *netHTTPRequestResponse) BindPath(v interface{}) error {
+	values := map[string][]string{}
+	for _, n := range rr.paramNames {
+		values[n] = []string{rr.r.PathValue(n)}
+	}
+	return bind.Path(v, values)
+}
+
+func (rr *netHTTPRequestResponse) Body(status int, body interface{}) error {
+	rr.w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	rr.w.WriteHeader(status)
+	return json.NewEncoder(rr.w).Encode(body)
+}
+
+func (rr *netHTTPRequestResponse) Blob(status int, contentType string, b []byte) error {
+	rr.w.Header().Set("Content-Type", contentType)
+	rr.w.WriteHeader(status)
+	_, err := rr.w.Write(b)
+	return err
+}
+
+func (rr *netHTTPRequestResponse) Stream(status int, contentType string, r io.Reader) error {
+	rr.w.Header().Set("Content-Type", contentType)
+	rr.w.WriteHeader(status)
+	rr.w.Flush()
+	return resttransport.WriteStream(rr.w, rr.w.Flush, r)
+}
+
+func (rr *netHTTPRequestResponse) Events(ctx context.Context) resttransport.EventSink {
+	rr.events = resttransport.StartEventStream(ctx, rr.w, rr.w.Flush, rr.eventKeepAlive)
+	return rr.events
+}
+
+func (rr *netHTTPRequestResponse) ResponseSize() int64 {
+	return rr.w.size
+}
+
+func (rr *netHTTPRequestResponse) NoBody(status int) error {
+	rr.w.WriteHeader(status)
+	return nil
+}
+
+func (rr *netHTTPRequestResponse) Attachment(file, name, contentType string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open attachment %s", file)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "unable to stat attachment %s", file)
+	}
+
+	rr.w.Header().Set("Content-Type", contentType)
+	rr.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(rr.w, rr.r, fi.Name(), fi.ModTime(), f)
+	return nil
+}
+
+func (rr *netHTTPRequestResponse) Redirect(status int, location string) error {
+	rr.w.Header().Set("Location", location)
+	rr.w.WriteHeader(status)
+	return nil
+}
+
+func (rr *netHTTPRequestResponse) User() interface{} {
+	return rr.r.Context().Value(rr.userKey)
+}
+
+func (rr *netHTTPRequestResponse) FormFile(name string) (*multipart.FileHeader, error) {
+	f, fh, err := rr.r.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0
+}
+
+func (t *netHTTPTransport) httpHandlerWrapper(path string, consumes []string, security []resttransport.SecurityRequirement, h resttransport.Handler) http.Handler {
+	paramNames := pathParameterNames(path)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := t.authenticate(w, r, security)
+		if err != nil {
+			t.errorHandler(w, r, err)
+			return
+		}
+		if len(consumes) > 0 && hasBody(r) && !mediatype.Matches(consumes, r.Header.Get("Content-Type")) {
+			t.errorHandler(w, r, resttransport.Errorf(http.StatusUnsupportedMediaType, "unsupported content type '%s'", r.Header.Get("Content-Type")))
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+		reqresp := &netHTTPRequestResponse{
+			userKey:        t.userKey,
+			paramNames:     paramNames,
+			w:              rw,
+			r:              r,
+			eventKeepAlive: t.eventKeepAlive,
+		}
+		ctx := r.Context()
+		if user := reqresp.User(); user != nil {
+			ctx = resttransport.WithPrincipal(ctx, user)
+		}
+		defer func() {
+			if reqresp.events != nil {
+				reqresp.events.Close()
+			}
+		}()
+		if err := h(ctx, reqresp); err != nil && !rw.committed {
+			t.errorHandler(rw, r, err)
+		}
+	})
+}
+
+// authenticate enforces the security requirements of a route, if it has any, returning the request
+// with the authenticated user in its context.
+func (t *netHTTPTransport) authenticate(w http.ResponseWriter, r *http.Request, security []resttransport.SecurityRequirement) (*http.Request, error) {
+	if len(security) == 0 {
+		return r, nil
+	}
+	user, err := resttransport.Authenticate(r.Context(), r, security, t.authenticators)
+	if err != nil {
+		if e, ok := resttransport.AsError(err); ok && e.Status == http.StatusUnauthorized {
+			for _, challenge := range resttransport.Challenges(security) {
+				w.Header().Add("WWW-Authenticate", challenge)
+			}
+		}
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), t.userKey, user)), nil
+}
+
+func (t *netHTTPTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.register(httpMethod, path, consumes, h, resttransport.NewRoute(opts...))
+}
+
+func (t *netHTTPTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	route := resttransport.NewRoute(opts...)
+	if len(route.Security) > 0 {
+		// the route's authenticators replace the authentication middleware
+		return t.register(httpMethod, path, consumes, h, route)
+	}
+	return t.register(httpMethod, path, consumes, h, route, t.authenticationMiddleware...)
+}
+
+func (t *netHTTPTransport) register(httpMethod, path string, consumes []string, h resttransport.Handler, route *resttransport.Route, mw ...Middleware) error {
+	switch httpMethod {
+	case "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE", "DELETE":
+	default:
+		return errors.Errorf("unexpected method '%s'", httpMethod)
+	}
+	for _, req := range route.Security {
+		if _, ok := t.authenticators[req.Scheme.Name]; !ok {
+			return errors.Errorf("no authenticator for security scheme '%s'", req.Scheme.Name)
+		}
+	}
+
+	h = resttransport.EnforcePreconditions(httpMethod, route, h)
+	handler := t.httpHandlerWrapper(path, consumes, route.Security, h)
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+
+	return registerPattern(t.mux, pattern(httpMethod, path), handler)
+}
+
+// registerPattern converts the panic from an invalid or conflicting pattern into an error.
+func registerPattern(mux *http.ServeMux, pattern string, handler http.Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("unable to register '%s': %v", pattern, r)
+		}
+	}()
+	mux.Handle(pattern, handler)
+	return nil
+}
@@ -0,0 +1,245 @@
+package nethttptransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+)
+
+type widget struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Verbose bool   `json:"verbose"`
+}
+
+func TestBind(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tr := New(nil)
+	require.NoError(tr.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		p := struct {
+			ID string `path:"id"`
+		}{}
+		if err := r.BindPath(&p); err != nil {
+			return err
+		}
+		q := struct {
+			Verbose bool `query:"verbose"`
+		}{}
+		if err := r.BindQuery(&q); err != nil {
+			return err
+		}
+		var w widget
+		if err := r.BindBody(&w); err != nil {
+			return err
+		}
+		w.ID = p.ID
+		w.Verbose = q.Verbose
+		return r.Body(http.StatusOK, w)
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, "/widgets/1?verbose=true", strings.NewReader(`{"name":"foo"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	tr.(http.Handler).ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"id":"1","name":"foo","verbose":true}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPut, "/widgets/1", nil)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	tr.(http.Handler).ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tr := New(nil)
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return resttransport.NewError(http.StatusNotFound, "no widget")
+	}))
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		if err := r.NoBody(http.StatusAccepted); err != nil {
+			return err
+		}
+		// the response is committed, so this isn't rendered
+		return resttransport.NewError(http.StatusInternalServerError, "too late")
+	}))
+	assert.Error(tr.RegisterHandler("CONNECT", "/widgets", nil, nil))
+
+	rec := httptest.NewRecorder()
+	tr.(http.Handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets/1", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+	var p resttransport.Problem
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(http.StatusNotFound, p.Status)
+	assert.Equal("no widget", p.Detail)
+
+	rec = httptest.NewRecorder()
+	tr.(http.Handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	assert.Equal(http.StatusAccepted, rec.Code)
+	assert.Empty(rec.Body.String())
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), DefaultUserContextKey, "alice")))
+		})
+	}
+	mux := http.NewServeMux()
+	tr := New(&Config{
+		Mux:                      mux,
+		AuthenticationMiddleware: []Middleware{authenticate},
+	})
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		user, _ := resttransport.PrincipalAs[string](ctx)
+		return r.Body(http.StatusOK, map[string]interface{}{"user": r.User(), "principal": user})
+	}
+	require.NoError(tr.RegisterAuthenticatedHandler(http.MethodGet, "/me", nil, h))
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/public", nil, h))
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(http.StatusUnauthorized, do("/me", "").Code)
+	assert.Equal(http.StatusUnauthorized, do("/me", "wrong").Code)
+
+	rec := do("/me", "secret")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"user":"alice","principal":"alice"}`, rec.Body.String())
+
+	rec = do("/public", "")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"user":null,"principal":""}`, rec.Body.String())
+}
+
+func TestSecurity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	jwt := &resttransport.SecurityScheme{Name: "jwt", Type: resttransport.BearerAuth}
+	tr := New(&Config{
+		AuthenticationMiddleware: []Middleware{func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+		}},
+		Authenticators: map[string]resttransport.Authenticator{
+			"jwt": func(ctx context.Context, s *resttransport.SecurityScheme, cred string, scopes []string) (interface{}, error) {
+				switch cred {
+				case "admin":
+					return "admin", nil
+				case "reader":
+					return nil, resttransport.Errorf(http.StatusForbidden, "missing scope %v", scopes)
+				}
+				return nil, resttransport.NewError(http.StatusUnauthorized, "invalid token")
+			},
+		},
+	})
+
+	called := false
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		called = true
+		user, _ := resttransport.PrincipalAs[string](ctx)
+		return r.Body(http.StatusOK, map[string]interface{}{"user": r.User(), "principal": user})
+	}
+	require.NoError(tr.RegisterHandler(http.MethodDelete, "/widgets/{id}", nil, h, resttransport.Security(jwt, "widgets:write")))
+	// the route's authenticators replace the middleware
+	require.NoError(tr.RegisterAuthenticatedHandler(http.MethodPut, "/widgets/{id}", nil, h, resttransport.Security(jwt)))
+
+	other := &resttransport.SecurityScheme{Name: "key", Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInHeader, ParamName: "X-Key"}
+	assert.Error(tr.RegisterHandler(http.MethodGet, "/widgets", nil, h, resttransport.Security(other)))
+
+	do := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/widgets/1", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		tr.(http.Handler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodDelete, "")
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+	assert.Equal("Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	assert.Equal(http.StatusUnauthorized, do(http.MethodDelete, "bad").Code)
+	assert.Equal(http.StatusForbidden, do(http.MethodDelete, "reader").Code)
+	assert.False(called)
+
+	rec = do(http.MethodDelete, "admin")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"user":"admin","principal":"admin"}`, rec.Body.String())
+
+	rec = do(http.MethodPut, "admin")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"user":"admin","principal":"admin"}`, rec.Body.String())
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tr := New(nil)
+	called := false
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		called = true
+		var v struct{}
+		if err := r.BindBody(&v); err != nil {
+			return err
+		}
+		return r.NoBody(http.StatusNoContent)
+	}
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/json", []string{"application/json"}, h))
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/any", nil, h))
+
+	do := func(path, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		tr.(http.Handler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// rejected before the handler runs
+	rec := do("/json", "text/plain")
+	assert.Equal(http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(resttransport.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.False(called)
+
+	assert.Equal(http.StatusNoContent, do("/json", "application/json; charset=utf-8").Code)
+
+	// rejected by BindBody, as it can't decode it
+	called = false
+	assert.Equal(http.StatusUnsupportedMediaType, do("/any", "text/plain").Code)
+	assert.True(called)
+}
@@ -0,0 +1,29 @@
+package nethttptransport
+
+import (
+	"regexp"
+	"strings"
+)
+
+var pathParameterRegex = regexp.MustCompile(`{([^}]+)}`)
+
+// pattern translates a resttransport path into an http.ServeMux pattern. Path parameters already
+// share the `{id}` syntax, but a trailing slash must be anchored with `{$}` so it is not treated as
+// a subtree match.
+func pattern(httpMethod, path string) string {
+	if path == "" {
+		path = "/"
+	}
+	if strings.HasSuffix(path, "/") {
+		path += "{$}"
+	}
+	return httpMethod + " " + path
+}
+
+func pathParameterNames(path string) []string {
+	names := []string{}
+	for _, m := range pathParameterRegex.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
@@ -0,0 +1,49 @@
+package nethttptransport
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPattern(t *testing.T) {
+	for i, c := range []struct {
+		expected string
+		method   string
+		path     string
+	}{
+		{"GET /{$}", "GET", "/"},
+		{"GET /{$}", "GET", ""},
+		{"GET /foo", "GET", "/foo"},
+		{"POST /foo/{$}", "POST", "/foo/"},
+		{"PUT /foo/{bar}", "PUT", "/foo/{bar}"},
+		{"DELETE /foo/{bar}/baz", "DELETE", "/foo/{bar}/baz"},
+	} {
+		t.Run(fmt.Sprintf("%d %s %s", i, c.method, c.path), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := pattern(c.method, c.path)
+			assert.Equal(c.expected, actual)
+		})
+	}
+}
+
+func TestPathParameterNames(t *testing.T) {
+	for i, c := range []struct {
+		expected []string
+		path     string
+	}{
+		{[]string{}, "/"},
+		{[]string{}, "/foo"},
+		{[]string{"bar"}, "/foo/{bar}"},
+		{[]string{"bar", "baz"}, "/foo/{bar}/{baz}"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.path), func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := pathParameterNames(c.path)
+			assert.Equal(c.expected, actual)
+		})
+	}
+}
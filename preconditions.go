@@ -0,0 +1,161 @@
+package resttransport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Version identifies the current state of a resource, by entity tag, modification time or both.
+type Version struct {
+	// ETag is a strong entity tag, including the quotes, see ETag.
+	ETag         string
+	LastModified time.Time
+}
+
+func (v Version) exists() bool {
+	return v.ETag != "" || !v.LastModified.IsZero()
+}
+
+// SetVersion sets the `ETag` and `Last-Modified` response headers to a version, so clients can
+// make conditional requests against it.
+func SetVersion(r RequestResponse, v Version) {
+	h := r.ResponseHeader()
+	if v.ETag != "" {
+		h.Set("ETag", v.ETag)
+	}
+	if !v.LastModified.IsZero() {
+		h.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Preconditions are the `If-Match` and `If-Unmodified-Since` headers of a request, which protect
+// updates from overwriting changes the client hasn't seen.
+type Preconditions struct {
+	IfMatch string
+	// IfUnmodifiedSince is zero if the header is missing or invalid.
+	IfUnmodifiedSince time.Time
+}
+
+// ParsePreconditions reads the preconditions from request headers.
+func ParsePreconditions(h http.Header) Preconditions {
+	p := Preconditions{
+		IfMatch: strings.TrimSpace(h.Get("If-Match")),
+	}
+	if t, err := http.ParseTime(h.Get("If-Unmodified-Since")); err == nil {
+		p.IfUnmodifiedSince = t
+	}
+	return p
+}
+
+// Present reports whether the request has any preconditions.
+func (p Preconditions) Present() bool {
+	return p.IfMatch != "" || !p.IfUnmodifiedSince.IsZero()
+}
+
+// Check evaluates the preconditions against the current version of the resource, per RFC 9110:
+// `If-Match` uses the strong comparison (`*` matches any existing resource), and
+// `If-Unmodified-Since` is only evaluated without it. Pass the zero Version if the resource does
+// not exist. A failed precondition is a 412 Error, handlers should check before changing anything:
+//
+//	if err := r.Preconditions().Check(resttransport.Version{ETag: widget.ETag()}); err != nil {
+//		return err
+//	}
+//
+// Routes registered with CurrentVersion have their preconditions checked before the handler runs.
+func (p Preconditions) Check(current Version) error {
+	if p.IfMatch != "" {
+		if !current.exists() || !p.ifMatch(current.ETag) {
+			return preconditionFailed("If-Match")
+		}
+		return nil
+	}
+	if !p.IfUnmodifiedSince.IsZero() && !current.LastModified.IsZero() {
+		if current.LastModified.Truncate(time.Second).After(p.IfUnmodifiedSince) {
+			return preconditionFailed("If-Unmodified-Since")
+		}
+	}
+	return nil
+}
+
+func (p Preconditions) ifMatch(tag string) bool {
+	if tag == "" || strings.HasPrefix(tag, "W/") {
+		return p.IfMatch == "*" && tag != ""
+	}
+	for _, candidate := range strings.Split(p.IfMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func preconditionFailed(header string) *Error {
+	return &Error{
+		Status: http.StatusPreconditionFailed,
+		Code:   "precondition_failed",
+		Detail: "the resource has changed, " + header + " does not match its current version",
+	}
+}
+
+// RequirePreconditions requires PUT, PATCH and DELETE requests to the route to have `If-Match` or
+// `If-Unmodified-Since`, see EnforcePreconditions.
+func RequirePreconditions() RouteOption {
+	return func(r *Route) {
+		r.RequirePreconditions = true
+	}
+}
+
+// VersionFunc returns the current version of the resource a request targets, or the zero Version
+// if it does not exist.
+type VersionFunc func(ctx context.Context, r RequestResponse) (Version, error)
+
+// CurrentVersion checks the preconditions of PUT, PATCH and DELETE requests to the route against
+// the version returned by fn, answering failed ones with 412 Precondition Failed before the handler
+// runs, see EnforcePreconditions.
+func CurrentVersion(fn VersionFunc) RouteOption {
+	return func(r *Route) {
+		r.CurrentVersion = fn
+	}
+}
+
+// EnforcePreconditions wraps the handler of a route registered with RequirePreconditions to
+// answer PUT, PATCH and DELETE requests without preconditions with 428 Precondition Required,
+// and the handler of a route registered with CurrentVersion to check their preconditions.
+// Transports call it when registering handlers.
+func EnforcePreconditions(httpMethod string, r *Route, h Handler) Handler {
+	switch httpMethod {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return h
+	}
+	if !r.RequirePreconditions && r.CurrentVersion == nil {
+		return h
+	}
+	required, current := r.RequirePreconditions, r.CurrentVersion
+	return func(ctx context.Context, rr RequestResponse) error {
+		p := rr.Preconditions()
+		if !p.Present() {
+			if required {
+				return &Error{
+					Status: http.StatusPreconditionRequired,
+					Code:   "precondition_required",
+					Detail: "If-Match or If-Unmodified-Since is required",
+				}
+			}
+			return h(ctx, rr)
+		}
+		if current != nil {
+			v, err := current(ctx, rr)
+			if err != nil {
+				return err
+			}
+			if err := p.Check(v); err != nil {
+				return err
+			}
+		}
+		return h(ctx, rr)
+	}
+}
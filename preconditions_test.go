@@ -0,0 +1,117 @@
+package resttransport_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func TestPreconditionsCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := resttransport.Version{ETag: `"v2"`, LastModified: modified}
+	check := func(h http.Header, v resttransport.Version) int {
+		err := resttransport.ParsePreconditions(h).Check(v)
+		if err == nil {
+			return 0
+		}
+		e, _ := resttransport.AsError(err)
+		return e.Status
+	}
+
+	assert.Equal(0, check(http.Header{}, current))
+	assert.Equal(0, check(http.Header{"If-Match": {`"v1", "v2"`}}, current))
+	assert.Equal(0, check(http.Header{"If-Match": {"*"}}, current))
+	assert.Equal(http.StatusPreconditionFailed, check(http.Header{"If-Match": {`"v1"`}}, current))
+	// If-Match uses the strong comparison
+	assert.Equal(http.StatusPreconditionFailed, check(http.Header{"If-Match": {`W/"v2"`}}, current))
+	assert.Equal(http.StatusPreconditionFailed, check(http.Header{"If-Match": {"*"}}, resttransport.Version{}))
+
+	assert.Equal(0, check(http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}}, current))
+	assert.Equal(http.StatusPreconditionFailed, check(http.Header{"If-Unmodified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}}, current))
+	// If-Unmodified-Since is ignored with If-Match
+	assert.Equal(0, check(http.Header{
+		"If-Match":            {`"v2"`},
+		"If-Unmodified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)},
+	}, current))
+}
+
+func TestRequirePreconditions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	version := resttransport.Version{ETag: `"v1"`}
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		if err := r.Preconditions().Check(version); err != nil {
+			return err
+		}
+		version.ETag = `"v2"`
+		resttransport.SetVersion(r, version)
+		return r.NoBody(http.StatusNoContent)
+	}, resttransport.RequirePreconditions()))
+
+	put := func(h http.Header) *testtransport.Response {
+		resp, err := tt.Do(http.MethodPut, "/widgets/{id}", &testtransport.Request{Header: h})
+		require.NoError(err)
+		return resp
+	}
+
+	assert.Equal(http.StatusPreconditionRequired, put(http.Header{}).Status)
+
+	resp := put(http.Header{"If-Match": {`"v1"`}})
+	assert.Equal(http.StatusNoContent, resp.Status)
+	assert.Equal(`"v2"`, resp.Header.Get("ETag"))
+
+	// a lost update
+	assert.Equal(http.StatusPreconditionFailed, put(http.Header{"If-Match": {`"v1"`}}).Status)
+}
+
+func TestCurrentVersion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	version := resttransport.Version{ETag: `"v1"`}
+	calls := 0
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodPut, "/widgets/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		calls++
+		version.ETag = `"v2"`
+		return r.NoBody(http.StatusNoContent)
+	}, resttransport.CurrentVersion(func(ctx context.Context, r resttransport.RequestResponse) (resttransport.Version, error) {
+		var p struct {
+			ID string `path:"id"`
+		}
+		if err := r.BindPath(&p); err != nil {
+			return resttransport.Version{}, err
+		}
+		if p.ID != "1" {
+			return resttransport.Version{}, resttransport.NewError(http.StatusNotFound, "no widget")
+		}
+		return version, nil
+	})))
+
+	put := func(id string, h http.Header) int {
+		resp, err := tt.Do(http.MethodPut, "/widgets/{id}", &testtransport.Request{Path: map[string]string{"id": id}, Header: h})
+		require.NoError(err)
+		return resp.Status
+	}
+
+	assert.Equal(http.StatusPreconditionFailed, put("1", http.Header{"If-Match": {`"v0"`}}))
+	assert.Equal(http.StatusNotFound, put("2", http.Header{"If-Match": {`"v1"`}}))
+	assert.Equal(0, calls)
+
+	assert.Equal(http.StatusNoContent, put("1", http.Header{"If-Match": {`"v1"`}}))
+	assert.Equal(http.StatusPreconditionFailed, put("1", http.Header{"If-Match": {`"v1"`}}))
+	// preconditions aren't required
+	assert.Equal(http.StatusNoContent, put("1", http.Header{}))
+	assert.Equal(2, calls)
+}
@@ -0,0 +1,81 @@
+package resttransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoPrincipal is the cause of the error returned by UserAs and PrincipalAs when the request is
+// not authenticated.
+var ErrNoPrincipal = errors.New("no authenticated principal")
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx holding the authenticated principal (the value returned by
+// RequestResponse.User). Transports store it in the context passed to the Handler, so layers
+// without the RequestResponse can retrieve it with PrincipalAs.
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the principal stored in ctx by WithPrincipal.
+func Principal(ctx context.Context) (interface{}, bool) {
+	p := ctx.Value(principalKey{})
+	return p, p != nil
+}
+
+// PrincipalID identifies a principal in a way that stays the same across its credentials: the `sub`
+// claim of JWT claims, a fmt.Stringer or a string. Other principals return the empty string, as
+// formatting them would include values like a token's expiry that change on every refresh.
+func PrincipalID(principal interface{}) string {
+	switch p := principal.(type) {
+	case interface{ GetSubject() (string, error) }:
+		sub, err := p.GetSubject()
+		if err != nil {
+			return ""
+		}
+		return sub
+	case fmt.Stringer:
+		return p.String()
+	case string:
+		return p
+	}
+	return ""
+}
+
+// PrincipalAs returns the principal stored in ctx as a T. A missing principal is a 401 Error
+// caused by ErrNoPrincipal, and a principal of another type is an internal error.
+func PrincipalAs[T any](ctx context.Context) (T, error) {
+	p, _ := Principal(ctx)
+	return principalAs[T](p)
+}
+
+// UserAs returns RequestResponse.User as a T, with the same errors as PrincipalAs:
+//
+//	claims, err := resttransport.UserAs[*jwt.Claims](r)
+//	if err != nil {
+//		return err
+//	}
+func UserAs[T any](r RequestResponse) (T, error) {
+	return principalAs[T](r.User())
+}
+
+func principalAs[T any](p interface{}) (T, error) {
+	var zero T
+	if p == nil {
+		return zero, &Error{
+			Status: http.StatusUnauthorized,
+			Detail: "authentication required",
+			Err:    ErrNoPrincipal,
+		}
+	}
+	t, ok := p.(T)
+	if !ok {
+		return zero, fmt.Errorf("principal is a %T, not a %s", p, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return t, nil
+}
@@ -0,0 +1,82 @@
+package resttransport_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/auth/jwt"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type account struct {
+	ID string
+}
+
+func TestUserAs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodGet, "/me", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		a, err := resttransport.UserAs[*account](r)
+		if err != nil {
+			return err
+		}
+		fromCtx, err := resttransport.PrincipalAs[*account](ctx)
+		if err != nil {
+			return err
+		}
+		assert.Same(a, fromCtx)
+		return r.Body(http.StatusOK, a.ID)
+	}))
+
+	resp, err := tt.Do(http.MethodGet, "/me", &testtransport.Request{User: &account{ID: "a1"}})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK).Body("a1")
+
+	// missing
+	resp, err = tt.Do(http.MethodGet, "/me", nil)
+	require.NoError(err)
+	assert.Equal(http.StatusUnauthorized, resp.Status)
+	assert.True(errors.Is(resp.Err, resttransport.ErrNoPrincipal))
+
+	// mistyped
+	resp, err = tt.Do(http.MethodGet, "/me", &testtransport.Request{User: "a1"})
+	require.NoError(err)
+	assert.Equal(http.StatusInternalServerError, resp.Status)
+	assert.EqualError(resp.Err, "principal is a string, not a *resttransport_test.account")
+
+	_, err = resttransport.PrincipalAs[stringer](context.Background())
+	assert.True(errors.Is(err, resttransport.ErrNoPrincipal))
+}
+
+func TestPrincipalID(t *testing.T) {
+	assert := assert.New(t)
+
+	claims := &jwt.Claims{}
+	claims.Subject = "alice"
+	claims.ExpiresAt = gojwt.NewNumericDate(time.Now().Add(time.Hour))
+	assert.Equal("alice", resttransport.PrincipalID(claims))
+	assert.Equal("bob", resttransport.PrincipalID(named("bob")))
+	assert.Equal("carol", resttransport.PrincipalID("carol"))
+	assert.Empty(resttransport.PrincipalID(&account{ID: "a1"}))
+	assert.Empty(resttransport.PrincipalID(nil))
+}
+
+type named string
+
+func (n named) String() string {
+	return string(n)
+}
+
+type stringer interface {
+	String() string
+}
@@ -0,0 +1,175 @@
+package ratelimittransport
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of counting a request.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests left in the current period.
+	Remaining int
+	// Reset is the time until the full quota is available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request will be allowed, only set if not Allowed.
+	RetryAfter time.Duration
+}
+
+// Backend counts requests. Implementations must be safe for concurrent use, and can share state
+// between instances of a service, for example in Redis.
+type Backend interface {
+	// Allow counts a request for key, if it is allowed at rate.
+	Allow(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+// memory holds per key state in memory, dropping keys that have been idle for over a period.
+type memory struct {
+	sync.Mutex
+	now       func() time.Time
+	entries   map[string]interface{}
+	lastSweep time.Time
+}
+
+func (m *memory) sweep(now time.Time, period time.Duration, idle func(entry interface{}) bool) {
+	if now.Sub(m.lastSweep) < period {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if idle(e) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// TokenBucket is an in-memory Backend using the token bucket algorithm: clients can burst up to
+// Rate.Requests, which refill evenly over Rate.Period.
+type TokenBucket struct {
+	memory
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewTokenBucket returns an empty TokenBucket.
+func NewTokenBucket() *TokenBucket {
+	return &TokenBucket{memory{now: time.Now, entries: map[string]interface{}{}}}
+}
+
+// Allow implements Backend.
+func (b *TokenBucket) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	now := b.now()
+	capacity := float64(rate.Requests)
+	perToken := rate.Period / time.Duration(rate.Requests)
+	b.sweep(now, rate.Period, func(e interface{}) bool {
+		bk := e.(*bucket)
+		return now.Sub(bk.updated) > bk.period
+	})
+
+	bk, ok := b.entries[key].(*bucket)
+	if !ok {
+		bk = &bucket{tokens: capacity, updated: now}
+		b.entries[key] = bk
+	}
+	bk.period = rate.Period
+	bk.tokens = math.Min(capacity, bk.tokens+float64(now.Sub(bk.updated))/float64(perToken))
+	bk.updated = now
+
+	res := Result{Allowed: bk.tokens >= 1}
+	if res.Allowed {
+		bk.tokens--
+	} else {
+		res.RetryAfter = time.Duration((1 - bk.tokens) * float64(perToken))
+	}
+	res.Remaining = int(bk.tokens)
+	res.Reset = time.Duration((capacity - bk.tokens) * float64(perToken))
+	return res, nil
+}
+
+// SlidingWindow is an in-memory Backend using the sliding window counter algorithm: the count of
+// the previous fixed window is weighted by how much of it overlaps the window ending now, so at most
+// Rate.Requests are allowed in any period without bursts at window boundaries.
+type SlidingWindow struct {
+	memory
+}
+
+type window struct {
+	start    time.Time
+	previous int
+	current  int
+	period   time.Duration
+}
+
+// NewSlidingWindow returns an empty SlidingWindow.
+func NewSlidingWindow() *SlidingWindow {
+	return &SlidingWindow{memory{now: time.Now, entries: map[string]interface{}{}}}
+}
+
+// Allow implements Backend.
+func (s *SlidingWindow) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	s.sweep(now, rate.Period, func(e interface{}) bool {
+		w := e.(*window)
+		return now.Sub(w.start) > 2*w.period
+	})
+
+	start := now.Truncate(rate.Period)
+	w, ok := s.entries[key].(*window)
+	if !ok {
+		w = &window{start: start}
+		s.entries[key] = w
+	}
+	w.period = rate.Period
+	switch elapsedWindows := now.Sub(w.start) / rate.Period; {
+	case elapsedWindows == 1:
+		w.previous, w.current, w.start = w.current, 0, start
+	case elapsedWindows > 1:
+		w.previous, w.current, w.start = 0, 0, start
+	}
+
+	limit := float64(rate.Requests)
+	elapsed := now.Sub(w.start)
+	weight := 1 - float64(elapsed)/float64(rate.Period)
+	count := float64(w.previous)*weight + float64(w.current)
+
+	res := Result{Allowed: count+1 <= limit}
+	if res.Allowed {
+		w.current++
+		count++
+	} else {
+		res.RetryAfter = s.retryAfter(w, limit, elapsed, rate.Period)
+	}
+	res.Remaining = int(math.Max(0, math.Floor(limit-count)))
+	// the previous window has fully slid out by the end of the current one, the current one a
+	// period later
+	res.Reset = rate.Period - elapsed
+	if w.current > 0 {
+		res.Reset += rate.Period
+	}
+	return res, nil
+}
+
+// retryAfter returns the time until the weighted count drops enough to allow a request.
+func (s *SlidingWindow) retryAfter(w *window, limit float64, elapsed, period time.Duration) time.Duration {
+	current := float64(w.current)
+	if current+1 <= limit && w.previous > 0 {
+		// within this window, once the previous window's weight has dropped enough
+		weight := (limit - 1 - current) / float64(w.previous)
+		return time.Duration((1-weight)*float64(period)) - elapsed
+	}
+	// in the next window, when this window is the previous one
+	weight := (limit - 1) / current
+	return period - elapsed + time.Duration((1-weight)*float64(period))
+}
@@ -0,0 +1,224 @@
+// Package ratelimittransport limits the request rate of the handlers of a resttransport, per
+// operation and per client. Requests over the limit are answered with 429 Too Many Requests and a
+// `Retry-After` header, and every limited response carries the `RateLimit-Limit`,
+// `RateLimit-Remaining`, `RateLimit-Reset` and `RateLimit-Policy` headers.
+//
+// Limits apply to every operation with Config.Default, or per route with the Limit option, which
+// also declares the 429 response so doctransport documents it:
+//
+//	t := ratelimittransport.New(echotransport.New(nil), &ratelimittransport.Config{
+//		Default: &ratelimittransport.Rate{Requests: 100, Period: time.Minute},
+//	})
+//	t.RegisterHandler("POST", "/signup", nil, signup,
+//		ratelimittransport.Limit(ratelimittransport.Rate{Requests: 5, Period: time.Hour}))
+//
+// Clients are identified by IP address unless configured otherwise, see KeyFunc.
+package ratelimittransport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/routename"
+)
+
+// Rate is a number of requests allowed per period.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+// KeyFunc identifies the client a request is counted against. Returning the empty string exempts
+// the request from the limit.
+type KeyFunc func(ctx context.Context, r resttransport.RequestResponse) string
+
+// ByIP identifies clients by the host of RequestResponse.RemoteAddr.
+func ByIP(ctx context.Context, r resttransport.RequestResponse) string {
+	addr := r.RemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + addr
+}
+
+// ByUser identifies authenticated clients by id(User()), and unauthenticated clients, or users id
+// returns the empty string for, by IP. A nil id defaults to resttransport.PrincipalID.
+func ByUser(id func(user interface{}) string) KeyFunc {
+	if id == nil {
+		id = resttransport.PrincipalID
+	}
+	return func(ctx context.Context, r resttransport.RequestResponse) string {
+		user := r.User()
+		if user == nil {
+			return ByIP(ctx, r)
+		}
+		uid := id(user)
+		if uid == "" {
+			return ByIP(ctx, r)
+		}
+		return "user:" + uid
+	}
+}
+
+// ByHeader identifies clients by the value of a request header, for example an API key. Requests
+// without the header are identified by IP.
+func ByHeader(name string) KeyFunc {
+	return func(ctx context.Context, r resttransport.RequestResponse) string {
+		v := r.RequestHeader().Get(name)
+		if v == "" {
+			return ByIP(ctx, r)
+		}
+		return "header:" + v
+	}
+}
+
+type optionKey int
+
+const (
+	rateKey optionKey = iota
+	keyFuncKey
+)
+
+// Limit sets the rate of a route, overriding Config.Default, and declares its 429 response.
+func Limit(rate Rate) resttransport.RouteOption {
+	return func(r *resttransport.Route) {
+		resttransport.WithRouteValue(rateKey, rate)(r)
+		resttransport.ErrorResponses(http.StatusTooManyRequests)(r)
+	}
+}
+
+// LimitBy sets how clients of a route are identified, overriding Config.Key.
+func LimitBy(key KeyFunc) resttransport.RouteOption {
+	return resttransport.WithRouteValue(keyFuncKey, key)
+}
+
+// Config holds configuration for the rate limiting Transport. Backend defaults to an in-memory
+// NewTokenBucket, and Key to ByIP. Routes are only limited if Default is set or they are
+// registered with the Limit option.
+type Config struct {
+	Backend Backend
+	Key     KeyFunc
+	Default *Rate
+}
+
+type rateLimitTransport struct {
+	inner       resttransport.Transport
+	namer       routename.Namer
+	backend     Backend
+	key         KeyFunc
+	defaultRate *Rate
+}
+
+// New returns a new instance of a resttransport that limits request rates.
+func New(inner resttransport.Transport, c *Config) resttransport.Transport {
+	if c == nil {
+		c = &Config{}
+	}
+	backend := c.Backend
+	if backend == nil {
+		backend = NewTokenBucket()
+	}
+	key := c.Key
+	if key == nil {
+		key = ByIP
+	}
+	return &rateLimitTransport{
+		inner:       inner,
+		namer:       routename.New(),
+		backend:     backend,
+		key:         key,
+		defaultRate: c.Default,
+	}
+}
+
+func (t *rateLimitTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h, opts, err := t.wrapHandler(httpMethod, path, h, opts)
+	if err != nil {
+		return err
+	}
+	return t.inner.RegisterHandler(httpMethod, path, consumes, h, opts...)
+}
+
+func (t *rateLimitTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	h, opts, err := t.wrapHandler(httpMethod, path, h, opts)
+	if err != nil {
+		return err
+	}
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, h, opts...)
+}
+
+// RegisterWebSocketHandler registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport. WebSocket upgrades are not limited.
+func (t *rateLimitTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers the authenticated handler on the inner
+// transport, see RegisterWebSocketHandler.
+func (t *rateLimitTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, h, opts...)
+}
+
+// wrapHandler limits the handler if the route has a rate, declaring the 429 response to the inner
+// transport.
+func (t *rateLimitTransport) wrapHandler(httpMethod, path string, inner resttransport.Handler, opts []resttransport.RouteOption) (resttransport.Handler, []resttransport.RouteOption, error) {
+	route := resttransport.NewRoute(opts...)
+	rate, ok := route.Value(rateKey).(Rate)
+	if !ok {
+		if t.defaultRate == nil {
+			return inner, opts, nil
+		}
+		rate = *t.defaultRate
+		opts = append(opts, resttransport.ErrorResponses(http.StatusTooManyRequests))
+	}
+	if rate.Requests <= 0 || rate.Period <= 0 {
+		return nil, nil, errors.Errorf("invalid rate %d per %s for %s %s", rate.Requests, rate.Period, httpMethod, path)
+	}
+	keyFunc, ok := route.Value(keyFuncKey).(KeyFunc)
+	if !ok {
+		keyFunc = t.key
+	}
+
+	operation := t.namer.Name(httpMethod, path)
+	policy := fmt.Sprintf("%d;w=%d", rate.Requests, int(math.Ceil(rate.Period.Seconds())))
+
+	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
+		key := keyFunc(ctx, reqres)
+		if key == "" {
+			return inner(ctx, reqres)
+		}
+
+		res, err := t.backend.Allow(ctx, operation+":"+key, rate)
+		if err != nil {
+			return errors.Wrapf(err, "unable to check rate limit for %s", operation)
+		}
+
+		h := reqres.ResponseHeader()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(rate.Requests))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			h.Set("Retry-After", seconds(res.RetryAfter))
+			return &resttransport.Error{
+				Status: http.StatusTooManyRequests,
+				Code:   "rate_limited",
+				Detail: fmt.Sprintf("rate limit of %d requests per %s exceeded", rate.Requests, rate.Period),
+			}
+		}
+		return inner(ctx, reqres)
+	}, opts, nil
+}
+
+// seconds formats a duration as whole seconds, rounding up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,207 @@
+package ratelimittransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/auth/jwt"
+	"github.com/paultyng/resttransport/doctransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func TestTokenBucket(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &clock{t: time.Unix(1000, 0)}
+	b := NewTokenBucket()
+	b.now = c.now
+	rate := Rate{Requests: 2, Period: 10 * time.Second}
+
+	res, _ := b.Allow(context.Background(), "a", rate)
+	assert.Equal(Result{Allowed: true, Remaining: 1, Reset: 5 * time.Second}, res)
+	res, _ = b.Allow(context.Background(), "a", rate)
+	assert.Equal(Result{Allowed: true, Remaining: 0, Reset: 10 * time.Second}, res)
+	res, _ = b.Allow(context.Background(), "a", rate)
+	assert.Equal(Result{Allowed: false, Remaining: 0, Reset: 10 * time.Second, RetryAfter: 5 * time.Second}, res)
+
+	// other keys have their own bucket
+	res, _ = b.Allow(context.Background(), "b", rate)
+	assert.True(res.Allowed)
+
+	c.t = c.t.Add(5 * time.Second)
+	res, _ = b.Allow(context.Background(), "a", rate)
+	assert.True(res.Allowed)
+
+	// idle keys are dropped
+	c.t = c.t.Add(time.Minute)
+	_, _ = b.Allow(context.Background(), "a", rate)
+	assert.Len(b.entries, 1)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &clock{t: time.Unix(1000, 0)}
+	s := NewSlidingWindow()
+	s.now = c.now
+	rate := Rate{Requests: 4, Period: 10 * time.Second}
+
+	for i := 0; i < 4; i++ {
+		res, _ := s.Allow(context.Background(), "a", rate)
+		assert.True(res.Allowed)
+		assert.Equal(3-i, res.Remaining)
+	}
+	res, _ := s.Allow(context.Background(), "a", rate)
+	assert.False(res.Allowed)
+	// the next window starts in 10s, the previous count must drop from 4 to 3
+	assert.Equal(12500*time.Millisecond, res.RetryAfter)
+
+	// halfway through the next window the previous window counts for 2
+	c.t = c.t.Add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		res, _ = s.Allow(context.Background(), "a", rate)
+		assert.True(res.Allowed)
+	}
+	res, _ = s.Allow(context.Background(), "a", rate)
+	assert.False(res.Allowed)
+	assert.Equal(2500*time.Millisecond, res.RetryAfter)
+
+	c.t = c.t.Add(res.RetryAfter)
+	res, _ = s.Allow(context.Background(), "a", rate)
+	assert.True(res.Allowed)
+}
+
+func TestTransport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	dt := doctransport.New(tt)
+	rt := New(dt, &Config{Default: &Rate{Requests: 100, Period: time.Minute}})
+
+	h := func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.NoBody(http.StatusNoContent)
+	}
+	require.NoError(rt.RegisterHandler(http.MethodPost, "/signup", nil, h, Limit(Rate{Requests: 1, Period: time.Hour})))
+	require.NoError(rt.RegisterHandler(http.MethodPost, "/widgets", nil, h, LimitBy(ByHeader("X-API-Key"))))
+	require.Error(rt.RegisterHandler(http.MethodGet, "/widgets", nil, h, Limit(Rate{})))
+
+	resp, err := tt.Do(http.MethodPost, "/signup", nil)
+	require.NoError(err)
+	assert.Equal(http.StatusNoContent, resp.Status)
+	assert.Equal("1", resp.Header.Get("RateLimit-Limit"))
+	assert.Equal("0", resp.Header.Get("RateLimit-Remaining"))
+	assert.Equal("3600", resp.Header.Get("RateLimit-Reset"))
+	assert.Equal("1;w=3600", resp.Header.Get("RateLimit-Policy"))
+
+	resp, err = tt.Do(http.MethodPost, "/signup", nil)
+	require.NoError(err)
+	assert.Equal(http.StatusTooManyRequests, resp.Status)
+	assert.Equal("3600", resp.Header.Get("Retry-After"))
+
+	// another client
+	resp, err = tt.Do(http.MethodPost, "/signup", &testtransport.Request{RemoteAddr: "198.51.100.7:5555"})
+	require.NoError(err)
+	assert.Equal(http.StatusNoContent, resp.Status)
+
+	resp, err = tt.Do(http.MethodPost, "/widgets", &testtransport.Request{Header: http.Header{"X-Api-Key": {"k1"}}})
+	require.NoError(err)
+	assert.Equal("100", resp.Header.Get("RateLimit-Limit"))
+	assert.Equal("99", resp.Header.Get("RateLimit-Remaining"))
+
+	// the 429 is documented whether it comes from the option or the default
+	s, err := dt.Generate()
+	require.NoError(err)
+	assert.Contains(s.Paths.Paths["/signup"].Post.Responses.StatusCodeResponses, http.StatusTooManyRequests)
+	assert.Contains(s.Paths.Paths["/widgets"].Post.Responses.StatusCodeResponses, http.StatusTooManyRequests)
+}
+
+type account struct {
+	id   string
+	name string
+}
+
+func (a account) String() string {
+	return a.id
+}
+
+func TestByUser(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	rt := New(tt, &Config{Key: ByUser(nil), Default: &Rate{Requests: 1, Period: time.Hour}})
+	require.NoError(rt.RegisterHandler(http.MethodPost, "/widgets", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.NoBody(http.StatusNoContent)
+	}))
+
+	do := func(user interface{}, remoteAddr string) int {
+		resp, err := tt.Do(http.MethodPost, "/widgets", &testtransport.Request{User: user, RemoteAddr: remoteAddr})
+		require.NoError(err)
+		return resp.Status
+	}
+	claims := func(iat time.Time) *jwt.Claims {
+		c := &jwt.Claims{}
+		c.Subject = "alice"
+		c.IssuedAt = gojwt.NewNumericDate(iat)
+		c.ExpiresAt = gojwt.NewNumericDate(iat.Add(time.Hour))
+		return c
+	}
+
+	// a refreshed token counts against the same subject
+	now := time.Now()
+	assert.Equal(http.StatusNoContent, do(claims(now), "192.0.2.1:1"))
+	assert.Equal(http.StatusTooManyRequests, do(claims(now.Add(time.Minute)), "192.0.2.2:1"))
+
+	assert.Equal(http.StatusNoContent, do(account{id: "bob", name: "Bob"}, "192.0.2.1:1"))
+	assert.Equal(http.StatusTooManyRequests, do(account{id: "bob", name: "Robert"}, "192.0.2.2:1"))
+
+	// users without an id are identified by IP
+	assert.Equal(http.StatusNoContent, do(struct{}{}, "192.0.2.3:1"))
+	assert.Equal(http.StatusTooManyRequests, do(struct{}{}, "192.0.2.3:1"))
+	assert.Equal(http.StatusNoContent, do(struct{}{}, "192.0.2.4:1"))
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	rt := New(tt, &Config{Default: &Rate{Requests: 100, Period: time.Minute}})
+
+	reply := func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		var m string
+		if err := conn.Read(&m); err != nil {
+			return err
+		}
+		return conn.Write(m)
+	}
+	require.NoError(resttransport.RegisterWebSocketHandler(rt, "/rooms", reply))
+	require.NoError(resttransport.RegisterAuthenticatedWebSocketHandler(rt, "/private", reply))
+
+	for path, user := range map[string]interface{}{"/rooms": nil, "/private": "alice"} {
+		client, err := tt.WebSocket(path, &testtransport.Request{User: user})
+		require.NoError(err)
+		require.NoError(client.Send("hi"))
+		var m string
+		require.NoError(client.Receive(&m))
+		assert.Equal("hi", m)
+		code, _, _ := client.Wait()
+		assert.Equal(resttransport.CloseNormal, code)
+	}
+}
@@ -0,0 +1,101 @@
+package resttransport
+
+import (
+	"context"
+	"reflect"
+)
+
+// TypedHandler is a handler that receives its bound path parameters, query parameters and request
+// body and returns its response body. Use struct{} for any part that is not used.
+type TypedHandler[Path, Query, Req, Resp any] func(ctx context.Context, path Path, query Query, req Req) (Resp, error)
+
+// Register adapts a TypedHandler and registers it with the transport. The adapter binds the path,
+// query and body (skipping any declared as struct{}) and sends the returned value with
+// Route.SuccessStatus, or no body if Resp is struct{}. The types are declared as route options, so
+// transports such as doctransport know them at registration.
+//
+//	type fooPath struct {
+//		ID string `path:"id"`
+//	}
+//
+//	err := resttransport.Register(t, http.MethodGet, "/foos/{id}", nil,
+//		func(ctx context.Context, p fooPath, _ struct{}, _ struct{}) (Foo, error) {
+//			return getFoo(ctx, p.ID)
+//		})
+func Register[Path, Query, Req, Resp any](t Transport, httpMethod, path string, consumes []string, fn TypedHandler[Path, Query, Req, Resp], opts ...RouteOption) error {
+	h, opts := Adapt(fn, opts...)
+	return t.RegisterHandler(httpMethod, path, consumes, h, opts...)
+}
+
+// RegisterAuthenticated is Register for an authenticated handler.
+func RegisterAuthenticated[Path, Query, Req, Resp any](t Transport, httpMethod, path string, consumes []string, fn TypedHandler[Path, Query, Req, Resp], opts ...RouteOption) error {
+	h, opts := Adapt(fn, opts...)
+	return t.RegisterAuthenticatedHandler(httpMethod, path, consumes, h, opts...)
+}
+
+// Adapt converts a TypedHandler to a Handler. The returned options declare the handler's types
+// followed by opts, and should be passed when registering it.
+func Adapt[Path, Query, Req, Resp any](fn TypedHandler[Path, Query, Req, Resp], opts ...RouteOption) (Handler, []RouteOption) {
+	pathType := reflect.TypeOf((*Path)(nil)).Elem()
+	queryType := reflect.TypeOf((*Query)(nil)).Elem()
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	respType := reflect.TypeOf((*Resp)(nil)).Elem()
+
+	bindPath, bindQuery, bindBody, hasBody := !isEmpty(pathType), !isEmpty(queryType), !isEmpty(reqType), !isEmpty(respType)
+	status := NewRoute(opts...).successStatus(hasBody)
+
+	declared := []RouteOption{}
+	if bindPath {
+		declared = append(declared, PathParams(pathType))
+	}
+	if bindQuery {
+		declared = append(declared, QueryParams(queryType))
+	}
+	if bindBody {
+		declared = append(declared, RequestBody(reqType))
+	}
+	if hasBody {
+		declared = append(declared, Response(status, respType))
+	} else {
+		declared = append(declared, Response(status, nil))
+	}
+
+	h := func(ctx context.Context, r RequestResponse) error {
+		var (
+			p Path
+			q Query
+			b Req
+		)
+		if bindPath {
+			if err := r.BindPath(&p); err != nil {
+				return err
+			}
+		}
+		if bindQuery {
+			if err := r.BindQuery(&q); err != nil {
+				return err
+			}
+		}
+		if bindBody {
+			if err := r.BindBody(&b); err != nil {
+				return err
+			}
+		}
+
+		resp, err := fn(ctx, p, q, b)
+		if err != nil {
+			return err
+		}
+		if !hasBody {
+			return r.NoBody(status)
+		}
+		return r.Body(status, resp)
+	}
+
+	return h, append(declared, opts...)
+}
+
+// isEmpty reports whether t is a struct without fields, such as struct{}.
+func isEmpty(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
@@ -0,0 +1,99 @@
+package resttransport_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type widgetPath struct {
+	ID string `path:"id"`
+}
+
+type widgetQuery struct {
+	Verbose bool `query:"verbose"`
+}
+
+type widget struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Verbose bool   `json:"verbose"`
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	err := resttransport.Register(tt, http.MethodPut, "/widgets/{id}", nil,
+		func(ctx context.Context, p widgetPath, q widgetQuery, w widget) (widget, error) {
+			w.ID = p.ID
+			w.Verbose = q.Verbose
+			return w, nil
+		}, resttransport.SuccessStatus(http.StatusCreated))
+	require.NoError(err)
+
+	reg, ok := tt.Lookup(http.MethodPut, "/widgets/{id}")
+	require.True(ok)
+	assert.Equal(reflect.TypeOf(widgetPath{}), reg.Route.PathParams)
+	assert.Equal(reflect.TypeOf(widgetQuery{}), reg.Route.QueryParams)
+	assert.Equal(reflect.TypeOf(widget{}), reg.Route.RequestBody)
+	assert.Equal(map[int]reflect.Type{http.StatusCreated: reflect.TypeOf(widget{})}, reg.Route.Responses)
+
+	resp, err := tt.Do(http.MethodPut, "/widgets/{id}", &testtransport.Request{
+		Path:  map[string]string{"id": "1"},
+		Query: map[string][]string{"verbose": {"true"}},
+		Body:  widget{Name: "foo"},
+	})
+	require.NoError(err)
+	resp.Expect(t).
+		NoError().
+		Status(http.StatusCreated).
+		Body(widget{ID: "1", Name: "foo", Verbose: true})
+}
+
+func TestRegister_Empty(t *testing.T) {
+	require := require.New(t)
+
+	tt := testtransport.New()
+	called := false
+	err := resttransport.RegisterAuthenticated(tt, http.MethodDelete, "/widgets", nil,
+		func(ctx context.Context, _ struct{}, _ struct{}, _ struct{}) (struct{}, error) {
+			called = true
+			return struct{}{}, nil
+		})
+	require.NoError(err)
+
+	reg, ok := tt.Lookup(http.MethodDelete, "/widgets")
+	require.True(ok)
+	require.True(reg.Authenticated)
+	require.Nil(reg.Route.RequestBody)
+
+	// no body is bound, so none is required
+	resp, err := tt.Do(http.MethodDelete, "/widgets", &testtransport.Request{User: "someone"})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusNoContent)
+	require.True(called)
+}
+
+func TestRegister_Error(t *testing.T) {
+	tt := testtransport.New()
+	err := resttransport.Register(tt, http.MethodGet, "/widgets/{id}", nil,
+		func(ctx context.Context, p widgetPath, _ struct{}, _ struct{}) (widget, error) {
+			return widget{}, resttransport.NewError(http.StatusNotFound, "no widget "+p.ID)
+		})
+	require.NoError(t, err)
+
+	resp, err := tt.Do(http.MethodGet, "/widgets/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "1"},
+	})
+	require.NoError(t, err)
+	resp.Expect(t).Error().Status(http.StatusNotFound)
+}
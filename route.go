@@ -0,0 +1,146 @@
+package resttransport
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// RouteOption configures a route when its handler is registered. Transports ignore the options
+// they have no use for, and wrapping transports must pass them on to the transport they wrap.
+type RouteOption func(*Route)
+
+// Route holds the options a handler was registered with. It declares the types a handler binds
+// and responds with, so they are known without the handler being invoked.
+type Route struct {
+	// PathParams, QueryParams and RequestBody are the struct types passed to BindPath, BindQuery
+	// and BindBody, nil if the handler does not bind them.
+	PathParams  reflect.Type
+	QueryParams reflect.Type
+	RequestBody reflect.Type
+	// Responses maps status codes to response body types, a nil type is a response without a body.
+	Responses map[int]reflect.Type
+	// SuccessStatus is the status sent by handlers registered with Register, defaults to 200 (or
+	// 204 when there is no response body).
+	SuccessStatus int
+	// ResponseHeaders maps status codes to the headers sent with them, by name with a description.
+	ResponseHeaders map[int]map[string]string
+	// Streams maps status codes of streamed responses to their content type, see StreamResponse.
+	Streams map[int]string
+	// RequirePreconditions is set by the RequirePreconditions option.
+	RequirePreconditions bool
+	// CurrentVersion is set by the CurrentVersion option.
+	CurrentVersion VersionFunc
+	// Security lists the requirements that grant access to the route, any one of them is enough.
+	Security []SecurityRequirement
+
+	values map[interface{}]interface{}
+}
+
+// NewRoute applies options to an empty Route.
+func NewRoute(opts ...RouteOption) *Route {
+	r := &Route{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Value returns the value set for key with WithRouteValue, or nil. This lets transports define
+// their own options.
+func (r *Route) Value(key interface{}) interface{} {
+	return r.values[key]
+}
+
+// WithRouteValue sets a transport specific value on the route. Like context keys, keys should be
+// of an unexported type to avoid collisions.
+func WithRouteValue(key, val interface{}) RouteOption {
+	return func(r *Route) {
+		if r.values == nil {
+			r.values = map[interface{}]interface{}{}
+		}
+		r.values[key] = val
+	}
+}
+
+func typeOf(v interface{}) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// PathParams declares the struct bound with BindPath, either a value or a reflect.Type.
+func PathParams(v interface{}) RouteOption {
+	return func(r *Route) {
+		r.PathParams = typeOf(v)
+	}
+}
+
+// QueryParams declares the struct bound with BindQuery, either a value or a reflect.Type.
+func QueryParams(v interface{}) RouteOption {
+	return func(r *Route) {
+		r.QueryParams = typeOf(v)
+	}
+}
+
+// RequestBody declares the type bound with BindBody, either a value or a reflect.Type.
+func RequestBody(v interface{}) RouteOption {
+	return func(r *Route) {
+		r.RequestBody = typeOf(v)
+	}
+}
+
+// Response declares a response status and its body type, either a value or a reflect.Type. Use a
+// nil body for a response without one.
+func Response(status int, body interface{}) RouteOption {
+	return func(r *Route) {
+		if r.Responses == nil {
+			r.Responses = map[int]reflect.Type{}
+		}
+		r.Responses[status] = typeOf(body)
+	}
+}
+
+// ResponseHeader declares a header sent with responses of a status.
+func ResponseHeader(status int, name, description string) RouteOption {
+	return func(r *Route) {
+		if r.ResponseHeaders == nil {
+			r.ResponseHeaders = map[int]map[string]string{}
+		}
+		if r.ResponseHeaders[status] == nil {
+			r.ResponseHeaders[status] = map[string]string{}
+		}
+		r.ResponseHeaders[status][name] = description
+	}
+}
+
+// ErrorResponses declares error statuses the handler may respond with, each with a Problem body.
+func ErrorResponses(statuses ...int) RouteOption {
+	return func(r *Route) {
+		for _, status := range statuses {
+			Response(status, Problem{})(r)
+		}
+	}
+}
+
+// SuccessStatus sets the status sent by a handler registered with Register, for example
+// http.StatusCreated.
+func SuccessStatus(status int) RouteOption {
+	return func(r *Route) {
+		r.SuccessStatus = status
+	}
+}
+
+func (r *Route) successStatus(hasBody bool) int {
+	switch {
+	case r.SuccessStatus != 0:
+		return r.SuccessStatus
+	case hasBody:
+		return http.StatusOK
+	}
+	return http.StatusNoContent
+}
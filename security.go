@@ -0,0 +1,182 @@
+package resttransport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SecuritySchemeType is the kind of credentials a SecurityScheme accepts.
+type SecuritySchemeType string
+
+const (
+	// BasicAuth is HTTP basic authentication, credentials are `user:password`.
+	BasicAuth SecuritySchemeType = "basic"
+	// BearerAuth is a bearer token in the Authorization header, see SecurityScheme.BearerFormat.
+	BearerAuth SecuritySchemeType = "bearer"
+	// APIKeyAuth is an API key sent in a header, query parameter or cookie.
+	APIKeyAuth SecuritySchemeType = "apiKey"
+	// OAuth2 is an OAuth2 access token sent as a bearer token.
+	OAuth2 SecuritySchemeType = "oauth2"
+)
+
+// Locations of an API key.
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+	APIKeyInCookie = "cookie"
+)
+
+// OAuthFlowType is an OAuth2 grant type.
+type OAuthFlowType string
+
+// OAuth2 flows.
+const (
+	ImplicitFlow          OAuthFlowType = "implicit"
+	PasswordFlow          OAuthFlowType = "password"
+	ClientCredentialsFlow OAuthFlowType = "clientCredentials"
+	AuthorizationCodeFlow OAuthFlowType = "authorizationCode"
+)
+
+// OAuthFlow describes how a client obtains an OAuth2 access token.
+type OAuthFlow struct {
+	Type             OAuthFlowType
+	AuthorizationURL string
+	TokenURL         string
+	RefreshURL       string
+	// Scopes maps the scope names to their descriptions.
+	Scopes map[string]string
+}
+
+// SecurityScheme describes an authentication mechanism. Schemes are identified by Name, which must
+// be unique within an API.
+type SecurityScheme struct {
+	Name        string
+	Type        SecuritySchemeType
+	Description string
+	// BearerFormat hints at the format of bearer tokens, for example `JWT`.
+	BearerFormat string
+	// In and ParamName locate an API key, for example APIKeyInHeader and `X-API-Key`.
+	In        string
+	ParamName string
+	// Flows are the supported OAuth2 flows.
+	Flows []OAuthFlow
+}
+
+// Credential extracts the credential for the scheme from a request: the decoded `user:password`
+// for basic authentication, the token for bearer and OAuth2 schemes, or the API key. It returns
+// false if the request has none.
+func (s *SecurityScheme) Credential(r *http.Request) (string, bool) {
+	switch s.Type {
+	case BasicAuth:
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return "", false
+		}
+		return user + ":" + password, true
+	case BearerAuth, OAuth2:
+		const prefix = "bearer "
+		h := r.Header.Get("Authorization")
+		if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+			return "", false
+		}
+		return strings.TrimSpace(h[len(prefix):]), true
+	case APIKeyAuth:
+		var key string
+		switch s.In {
+		case APIKeyInHeader:
+			key = r.Header.Get(s.ParamName)
+		case APIKeyInQuery:
+			key = r.URL.Query().Get(s.ParamName)
+		case APIKeyInCookie:
+			if c, err := r.Cookie(s.ParamName); err == nil {
+				key = c.Value
+			}
+		}
+		return key, key != ""
+	}
+	return "", false
+}
+
+// Challenge returns the `WWW-Authenticate` challenge for the scheme, or the empty string if it
+// doesn't have one.
+func (s *SecurityScheme) Challenge() string {
+	switch s.Type {
+	case BasicAuth:
+		return "Basic"
+	case BearerAuth, OAuth2:
+		return "Bearer"
+	}
+	return ""
+}
+
+// SecurityRequirement is a scheme, and the scopes within it, that grants access to a route.
+type SecurityRequirement struct {
+	Scheme *SecurityScheme
+	Scopes []string
+}
+
+// Security declares a scheme, and the scopes the credentials need, that grants access to the route.
+// When used more than once, any one of the requirements grants access. Transports that enforce
+// security treat a route with requirements as authenticated however it was registered.
+func Security(scheme *SecurityScheme, scopes ...string) RouteOption {
+	return func(r *Route) {
+		r.Security = append(r.Security, SecurityRequirement{
+			Scheme: scheme,
+			Scopes: scopes,
+		})
+	}
+}
+
+// Authenticator verifies the credential for a scheme (see SecurityScheme.Credential) and returns
+// the user. It should return a 401 Error for invalid credentials, and a 403 Error if they are
+// valid but lack the required scopes.
+type Authenticator func(ctx context.Context, scheme *SecurityScheme, credential string, scopes []string) (interface{}, error)
+
+// Authenticate tries the requirements in order, calling the authenticator registered for the scheme
+// if the request has a credential for it, and returns the first user authenticated. If none is,
+// the first authenticator error is returned, or a 401 Error if the request had no credentials.
+func Authenticate(ctx context.Context, r *http.Request, reqs []SecurityRequirement, authenticators map[string]Authenticator) (interface{}, error) {
+	var firstErr error
+	for _, req := range reqs {
+		auth, ok := authenticators[req.Scheme.Name]
+		if !ok {
+			return nil, errors.Errorf("no authenticator for security scheme '%s'", req.Scheme.Name)
+		}
+		cred, ok := req.Scheme.Credential(r)
+		if !ok {
+			continue
+		}
+		user, err := auth(ctx, req.Scheme, cred, req.Scopes)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, NewError(http.StatusUnauthorized, "authentication required")
+}
+
+// Challenges returns the distinct `WWW-Authenticate` challenges of the requirements.
+func Challenges(reqs []SecurityRequirement) []string {
+	var challenges []string
+	seen := map[string]bool{}
+	for _, req := range reqs {
+		c := req.Scheme.Challenge()
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		challenges = append(challenges, c)
+	}
+	return challenges
+}
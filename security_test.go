@@ -0,0 +1,88 @@
+package resttransport_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paultyng/resttransport"
+)
+
+func TestSecuritySchemeCredential(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/widgets?api_key=query-key", nil)
+	r.SetBasicAuth("user", "p:ss")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "cookie-key"})
+
+	cred, ok := (&resttransport.SecurityScheme{Type: resttransport.BasicAuth}).Credential(r)
+	assert.True(ok)
+	assert.Equal("user:p:ss", cred)
+
+	_, ok = (&resttransport.SecurityScheme{Type: resttransport.BearerAuth}).Credential(r)
+	assert.False(ok)
+
+	cred, ok = (&resttransport.SecurityScheme{Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInQuery, ParamName: "api_key"}).Credential(r)
+	assert.True(ok)
+	assert.Equal("query-key", cred)
+
+	cred, ok = (&resttransport.SecurityScheme{Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInCookie, ParamName: "session"}).Credential(r)
+	assert.True(ok)
+	assert.Equal("cookie-key", cred)
+
+	r.Header.Set("Authorization", "bearer abc.def")
+	cred, ok = (&resttransport.SecurityScheme{Type: resttransport.OAuth2}).Credential(r)
+	assert.True(ok)
+	assert.Equal("abc.def", cred)
+}
+
+func TestAuthenticate(t *testing.T) {
+	assert := assert.New(t)
+
+	bearer := &resttransport.SecurityScheme{Name: "jwt", Type: resttransport.BearerAuth}
+	apiKey := &resttransport.SecurityScheme{Name: "key", Type: resttransport.APIKeyAuth, In: resttransport.APIKeyInHeader, ParamName: "X-API-Key"}
+	reqs := resttransport.NewRoute(
+		resttransport.Security(bearer, "widgets:write"),
+		resttransport.Security(apiKey),
+	).Security
+
+	authenticators := map[string]resttransport.Authenticator{
+		"jwt": func(ctx context.Context, s *resttransport.SecurityScheme, cred string, scopes []string) (interface{}, error) {
+			assert.Equal([]string{"widgets:write"}, scopes)
+			if cred != "good" {
+				return nil, resttransport.NewError(http.StatusForbidden, "insufficient scope")
+			}
+			return "jwt-user", nil
+		},
+		"key": func(ctx context.Context, s *resttransport.SecurityScheme, cred string, scopes []string) (interface{}, error) {
+			return "key-user", nil
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := resttransport.Authenticate(context.Background(), r, reqs, authenticators)
+	e, ok := resttransport.AsError(err)
+	assert.True(ok)
+	assert.Equal(http.StatusUnauthorized, e.Status)
+	assert.Equal([]string{"Bearer"}, resttransport.Challenges(reqs))
+
+	r.Header.Set("Authorization", "Bearer bad")
+	_, err = resttransport.Authenticate(context.Background(), r, reqs, authenticators)
+	e, ok = resttransport.AsError(err)
+	assert.True(ok)
+	assert.Equal(http.StatusForbidden, e.Status)
+
+	// any requirement is enough
+	r.Header.Set("X-API-Key", "k")
+	user, err := resttransport.Authenticate(context.Background(), r, reqs, authenticators)
+	assert.NoError(err)
+	assert.Equal("key-user", user)
+
+	r.Header.Set("Authorization", "Bearer good")
+	user, err = resttransport.Authenticate(context.Background(), r, reqs, authenticators)
+	assert.NoError(err)
+	assert.Equal("jwt-user", user)
+}
@@ -0,0 +1,216 @@
+package resttransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EventStreamContentType is the media type of Server-Sent Events.
+	EventStreamContentType = "text/event-stream"
+	// DefaultEventKeepAlive is how often transports send a comment on an idle event stream by
+	// default, so proxies don't time out the connection.
+	DefaultEventKeepAlive = 15 * time.Second
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID sets the last event ID of the client, which it sends back in the `Last-Event-ID` header
+	// when reconnecting.
+	ID string
+	// Event is the type of the event, `message` if empty.
+	Event string
+	// Data is sent as is if it is a string or []byte, and marshaled to JSON otherwise.
+	Data interface{}
+	// Retry sets how long the client waits before reconnecting, if not zero.
+	Retry time.Duration
+}
+
+// EventSink sends Server-Sent Events, see RequestResponse.Events.
+type EventSink interface {
+	// Send writes an event and flushes it to the client.
+	Send(Event) error
+	// Close ends the stream. Transports close it when the handler returns.
+	Close() error
+	// Done is closed when the stream ends, because it was closed or the client went away.
+	Done() <-chan struct{}
+}
+
+// StreamResponse declares a response status streamed with the given content type, for example
+// EventStreamContentType for handlers that send events.
+func StreamResponse(status int, contentType string) RouteOption {
+	return func(r *Route) {
+		if r.Streams == nil {
+			r.Streams = map[int]string{}
+		}
+		r.Streams[status] = contentType
+	}
+}
+
+// WriteStream copies r to w, calling flush after every write, until r is exhausted. It is meant for
+// Transport implementations of RequestResponse.Stream.
+func WriteStream(w io.Writer, flush func(), r io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return errors.Wrap(werr, "unable to write stream")
+			}
+			flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "unable to read stream")
+		}
+	}
+}
+
+// StartEventStream sets the headers of an event stream, writes them with a 200 status, and
+// returns an EventSink writing to w. A comment is sent every keepAlive while the stream is idle,
+// unless it is zero. The sink is closed when ctx is done. It is meant for Transport implementations
+// of RequestResponse.Events, which must close the sink when the handler returns.
+func StartEventStream(ctx context.Context, w http.ResponseWriter, flush func(), keepAlive time.Duration) EventSink {
+	h := w.Header()
+	h.Set("Content-Type", EventStreamContentType)
+	h.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush()
+
+	s := &eventSink{
+		w:     w,
+		flush: flush,
+		done:  make(chan struct{}),
+	}
+	go s.run(ctx, keepAlive)
+	return s
+}
+
+type eventSink struct {
+	sync.Mutex
+	w      io.Writer
+	flush  func()
+	done   chan struct{}
+	closed bool
+	// sent is the time of the last write, for keep alives
+	sent time.Time
+}
+
+// run sends keep alives, and closes the sink once ctx is done.
+func (s *eventSink) run(ctx context.Context, keepAlive time.Duration) {
+	var tick <-chan time.Time
+	if keepAlive > 0 {
+		t := time.NewTicker(keepAlive)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			s.Close()
+			return
+		case now := <-tick:
+			s.Lock()
+			if now.Sub(s.sent) >= keepAlive {
+				_ = s.write([]byte(": keep-alive\n\n"))
+			}
+			s.Unlock()
+		}
+	}
+}
+
+func (s *eventSink) write(b []byte) error {
+	if s.closed {
+		return errors.New("event stream is closed")
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return errors.Wrap(err, "unable to write event")
+	}
+	s.flush()
+	s.sent = time.Now()
+	return nil
+}
+
+func (s *eventSink) Send(e Event) error {
+	b, err := FormatEvent(e)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.write(b)
+}
+
+func (s *eventSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	return nil
+}
+
+func (s *eventSink) Done() <-chan struct{} {
+	return s.done
+}
+
+// FormatEvent encodes an event in the `text/event-stream` format.
+func FormatEvent(e Event) ([]byte, error) {
+	if strings.ContainsAny(e.ID, "\r\n\x00") {
+		return nil, errors.Errorf("invalid event ID %q", e.ID)
+	}
+	if strings.ContainsAny(e.Event, "\r\n") {
+		return nil, errors.Errorf("invalid event type %q", e.Event)
+	}
+
+	var data []byte
+	switch d := e.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		var err error
+		data, err = json.Marshal(d)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal event data")
+		}
+	}
+
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if e.Data != nil {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+		data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			buf.WriteString("data: ")
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
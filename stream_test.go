@@ -0,0 +1,73 @@
+package resttransport_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+)
+
+func TestFormatEvent(t *testing.T) {
+	for name, c := range map[string]struct {
+		event    resttransport.Event
+		expected string
+	}{
+		"empty":     {resttransport.Event{}, "\n"},
+		"string":    {resttransport.Event{Data: "hello"}, "data: hello\n\n"},
+		"multiline": {resttransport.Event{Data: []byte("a\r\nb\nc")}, "data: a\ndata: b\ndata: c\n\n"},
+		"json":      {resttransport.Event{Event: "progress", Data: map[string]int{"percent": 50}}, "event: progress\ndata: {\"percent\":50}\n\n"},
+		"all": {
+			resttransport.Event{ID: "7", Event: "done", Data: "", Retry: 3 * time.Second},
+			"id: 7\nevent: done\nretry: 3000\ndata: \n\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, err := resttransport.FormatEvent(c.event)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, string(b))
+		})
+	}
+
+	_, err := resttransport.FormatEvent(resttransport.Event{ID: "a\nb"})
+	assert.Error(t, err)
+	_, err = resttransport.FormatEvent(resttransport.Event{Event: "a\rb"})
+	assert.Error(t, err)
+}
+
+func TestStartEventStream(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	flushes := 0
+	events := resttransport.StartEventStream(ctx, w, func() { flushes++ }, 10*time.Millisecond)
+	assert.Equal(resttransport.EventStreamContentType, w.Header().Get("Content-Type"))
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+
+	require.NoError(events.Send(resttransport.Event{Data: "first"}))
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	select {
+	case <-events.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stream not closed when the context was done")
+	}
+	assert.Error(events.Send(resttransport.Event{Data: "second"}))
+	assert.NoError(events.Close())
+
+	body := w.Body.String()
+	assert.True(strings.HasPrefix(body, "data: first\n\n"))
+	assert.Contains(body, ": keep-alive\n\n")
+	assert.NotContains(body, "second")
+	assert.Greater(flushes, 1)
+}
@@ -0,0 +1,136 @@
+package testtransport
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+)
+
+// Attachment records a call to RequestResponse.Attachment.
+type Attachment struct {
+	File        string
+	Name        string
+	ContentType string
+}
+
+// Response captures what a handler sent back through the RequestResponse.
+type Response struct {
+	// Sent is true once the handler called Body, NoBody, Blob, Stream, Events, Redirect or
+	// Attachment.
+	Sent   bool
+	Status int
+	Header http.Header
+	// Body is the value passed to RequestResponse.Body (or the []byte passed to Blob, or read from
+	// the reader passed to Stream), see DecodeBody for the marshaled form.
+	Body       interface{}
+	Attachment *Attachment
+	// Events holds the events sent through RequestResponse.Events.
+	Events []resttransport.Event
+	// Err is the error returned by the handler. If the handler had not sent a response, the error is
+	// also captured as a resttransport.Problem body with its status.
+	Err error
+}
+
+// Location returns the Location header set by a redirect.
+func (r *Response) Location() string {
+	return r.Header.Get("Location")
+}
+
+// DecodeBody round trips the response body through JSON into v, similar to how a client would
+// receive it. A Blob body is unmarshaled as is.
+func (r *Response) DecodeBody(v interface{}) error {
+	if b, ok := r.Body.([]byte); ok {
+		return json.Unmarshal(b, v)
+	}
+	raw, err := json.Marshal(r.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal response body")
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Expect starts a chain of assertions against the response, reporting failures to t.
+func (r *Response) Expect(t testing.TB) *Expectation {
+	return &Expectation{t: t, r: r}
+}
+
+// Expectation is a fluent set of assertions against a Response.
+type Expectation struct {
+	t testing.TB
+	r *Response
+}
+
+// NoError asserts the handler did not return an error.
+func (e *Expectation) NoError() *Expectation {
+	e.t.Helper()
+	if e.r.Err != nil {
+		e.t.Errorf("expected no handler error, got: %v", e.r.Err)
+	}
+	return e
+}
+
+// Error asserts the handler returned an error.
+func (e *Expectation) Error() *Expectation {
+	e.t.Helper()
+	if e.r.Err == nil {
+		e.t.Errorf("expected a handler error")
+	}
+	return e
+}
+
+// Status asserts the response status code.
+func (e *Expectation) Status(status int) *Expectation {
+	e.t.Helper()
+	if !e.r.Sent {
+		e.t.Errorf("expected status %d, but no response was sent", status)
+		return e
+	}
+	if e.r.Status != status {
+		e.t.Errorf("expected status %d, got %d", status, e.r.Status)
+	}
+	return e
+}
+
+// Header asserts the value of a response header.
+func (e *Expectation) Header(name, value string) *Expectation {
+	e.t.Helper()
+	if actual := e.r.Header.Get(name); actual != value {
+		e.t.Errorf("expected header %s to be %q, got %q", name, value, actual)
+	}
+	return e
+}
+
+// Location asserts the redirect location.
+func (e *Expectation) Location(location string) *Expectation {
+	e.t.Helper()
+	return e.Header("Location", location)
+}
+
+// Body asserts the response body, decoded into a new value of the same type as expected, equals
+// expected.
+func (e *Expectation) Body(expected interface{}) *Expectation {
+	e.t.Helper()
+	actual := reflect.New(reflect.TypeOf(expected))
+	if err := e.r.DecodeBody(actual.Interface()); err != nil {
+		e.t.Errorf("unable to decode response body: %v", err)
+		return e
+	}
+	if !reflect.DeepEqual(expected, actual.Elem().Interface()) {
+		e.t.Errorf("expected body %#v, got %#v", expected, actual.Elem().Interface())
+	}
+	return e
+}
+
+// DecodeBody decodes the response body into v for further assertions.
+func (e *Expectation) DecodeBody(v interface{}) *Expectation {
+	e.t.Helper()
+	if err := e.r.DecodeBody(v); err != nil {
+		e.t.Errorf("unable to decode response body: %v", err)
+	}
+	return e
+}
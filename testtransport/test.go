@@ -0,0 +1,431 @@
+// Package testtransport provides an in-memory resttransport implementation for unit testing
+// handlers. Registrations are recorded and can be invoked directly with a fake request, and
+// whatever the handler sends back is captured for assertions without starting an HTTP server.
+package testtransport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/internal/bind"
+	"github.com/paultyng/resttransport/mediatype"
+)
+
+// Registration records a single RegisterHandler, RegisterAuthenticatedHandler or WebSocket handler
+// registration.
+type Registration struct {
+	Method        string
+	Path          string
+	Consumes      []string
+	Authenticated bool
+	Handler       resttransport.Handler
+	// Route holds the route options the handler was registered with.
+	Route *resttransport.Route
+	// WebSocketHandler is set for handlers registered with RegisterWebSocketHandler or
+	// RegisterAuthenticatedWebSocketHandler, whose Handler answers requests that aren't upgraded.
+	WebSocketHandler resttransport.WebSocketHandler
+}
+
+// Transport is a resttransport.Transport that records registrations in memory.
+type Transport struct {
+	mu            sync.Mutex
+	registrations []Registration
+}
+
+// New returns an empty in-memory Transport.
+func New() *Transport {
+	return &Transport{}
+}
+
+// RegisterHandler records an unauthenticated handler.
+func (t *Transport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.register(false, httpMethod, path, consumes, h, opts)
+}
+
+// RegisterAuthenticatedHandler records an authenticated handler.
+func (t *Transport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.register(true, httpMethod, path, consumes, h, opts)
+}
+
+func (t *Transport) register(auth bool, httpMethod, path string, consumes []string, h resttransport.Handler, opts []resttransport.RouteOption) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.lookup(httpMethod, path); ok {
+		return errors.Errorf("handler already registered for %s %s", httpMethod, path)
+	}
+
+	t.registrations = append(t.registrations, Registration{
+		Method:        httpMethod,
+		Path:          path,
+		Consumes:      consumes,
+		Authenticated: auth,
+		Handler:       h,
+		Route:         resttransport.NewRoute(opts...),
+	})
+	return nil
+}
+
+// Registrations returns the recorded registrations in the order they were made.
+func (t *Transport) Registrations() []Registration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Registration(nil), t.registrations...)
+}
+
+// Lookup returns the registration for a method and registered path (for example `/foos/{id}`).
+func (t *Transport) Lookup(httpMethod, path string) (Registration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lookup(httpMethod, path)
+}
+
+func (t *Transport) lookup(httpMethod, path string) (Registration, bool) {
+	for _, r := range t.registrations {
+		if r.Method == httpMethod && r.Path == path {
+			return r, true
+		}
+	}
+	return Registration{}, false
+}
+
+// DefaultRemoteAddr is the client address of a Request without one, the same as httptest uses.
+const DefaultRemoteAddr = "192.0.2.1:1234"
+
+// Request holds the fake request data passed to a handler by Do.
+type Request struct {
+	Context context.Context
+	// Path holds path parameter values keyed by the name used in the registered path.
+	Path   map[string]string
+	Query  url.Values
+	Header http.Header
+	// Body is marshaled to JSON and unmarshaled into the value passed to BindBody. A []byte or
+	// json.RawMessage is used as is. The Content-Type header defaults to `application/json` when
+	// checking it against the registered consumes.
+	Body  interface{}
+	Files map[string]*multipart.FileHeader
+	// RemoteAddr is returned from RequestResponse.RemoteAddr, defaults to DefaultRemoteAddr.
+	RemoteAddr string
+	// User is returned from RequestResponse.User. Authenticated handlers, and handlers registered with
+	// security requirements, respond with 401 if it is nil, simulating authentication middleware.
+	User interface{}
+}
+
+// Do invokes the handler registered for the method and path (for example `/foos/{id}`) and returns
+// the captured response. The returned error is only non-nil if no handler was registered; errors
+// returned by the handler are captured in Response.Err.
+func (t *Transport) Do(httpMethod, path string, req *Request) (*Response, error) {
+	reg, ok := t.Lookup(httpMethod, path)
+	if !ok {
+		return nil, errors.Errorf("no handler registered for %s %s", httpMethod, path)
+	}
+
+	if req == nil {
+		req = &Request{}
+	}
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	rr := &testRequestResponse{
+		path: reg.Path,
+		req:  req,
+		resp: &Response{Header: http.Header{}},
+	}
+
+	if (reg.Authenticated || len(reg.Route.Security) > 0) && req.User == nil {
+		rr.resp.Status = http.StatusUnauthorized
+		rr.resp.Sent = true
+		return rr.resp, nil
+	}
+
+	if len(reg.Consumes) > 0 && req.Body != nil && !mediatype.Matches(reg.Consumes, rr.contentType()) {
+		rr.renderProblem(resttransport.Errorf(http.StatusUnsupportedMediaType, "unsupported content type '%s'", rr.contentType()))
+		return rr.resp, nil
+	}
+
+	if req.User != nil {
+		ctx = resttransport.WithPrincipal(ctx, req.User)
+	}
+	rr.resp.Err = resttransport.EnforcePreconditions(reg.Method, reg.Route, reg.Handler)(ctx, rr)
+	if rr.events != nil {
+		rr.events.Close()
+	}
+	if rr.resp.Err != nil && !rr.resp.Sent {
+		rr.renderProblem(rr.resp.Err)
+	}
+	return rr.resp, nil
+}
+
+// renderProblem captures an error response the way the HTTP transports render it.
+func (rr *testRequestResponse) renderProblem(err error) {
+	p := resttransport.NewProblem(err)
+	rr.resp.Sent = true
+	rr.resp.Status = p.Status
+	rr.resp.Header.Set("Content-Type", resttransport.ProblemContentType)
+	rr.resp.Body = p
+}
+
+type testRequestResponse struct {
+	path   string
+	req    *Request
+	resp   *Response
+	events *eventSink
+}
+
+func (rr *testRequestResponse) RequestHeader() http.Header {
+	if rr.req.Header == nil {
+		rr.req.Header = http.Header{}
+	}
+	return rr.req.Header
+}
+
+func (rr *testRequestResponse) RemoteAddr() string {
+	if rr.req.RemoteAddr == "" {
+		return DefaultRemoteAddr
+	}
+	return rr.req.RemoteAddr
+}
+
+// URL builds the URL from the registered path, the Path values and the Query.
+func (rr *testRequestResponse) URL() *url.URL {
+	p := rr.path
+	for name, v := range rr.req.Path {
+		p = strings.Replace(p, "{"+name+"}", url.PathEscape(v), 1)
+	}
+	return &url.URL{
+		Path:     p,
+		RawQuery: rr.req.Query.Encode(),
+	}
+}
+
+func (rr *testRequestResponse) Preconditions() resttransport.Preconditions {
+	return resttransport.ParsePreconditions(rr.req.Header)
+}
+
+func (rr *testRequestResponse) ResponseHeader() http.Header {
+	return rr.resp.Header
+}
+
+func (rr *testRequestResponse) contentType() string {
+	if ct := rr.req.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/json"
+}
+
+func (rr *testRequestResponse) BindQuery(v interface{}) error {
+	return bind.Query(v, rr.req.Query)
+}
+
+// RawBody returns the Body marshaled to JSON, or nil if there is none.
+func (rr *testRequestResponse) RawBody() ([]byte, error) {
+	switch b := rr.req.Body.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return b, nil
+	case json.RawMessage:
+		return b, nil
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal request body")
+		}
+		return raw, nil
+	}
+}
+
+func (rr *testRequestResponse) BindBody(v interface{}) error {
+	if rr.req.Body == nil {
+		return resttransport.NewError(http.StatusBadRequest, "request body can't be empty")
+	}
+	raw, err := rr.RawBody()
+	if err != nil {
+		return err
+	}
+	return bind.Body(v, json.Unmarshal(raw, v))
+}
+
+func (rr *testRequestResponse) BindPath(v interface{}) error {
+	values := map[string][]string{}
+	for n, pv := range rr.req.Path {
+		values[n] = []string{pv}
+	}
+	return bind.Path(v, values)
+}
+
+func (rr *testRequestResponse) User() interface{} {
+	return rr.req.User
+}
+
+func (rr *testRequestResponse) FormFile(name string) (*multipart.FileHeader, error) {
+	fh, ok := rr.req.Files[name]
+	if !ok {
+		return nil, http.ErrMissingFile
+	}
+	return fh, nil
+}
+
+func (rr *testRequestResponse) send(status int) error {
+	if rr.resp.Sent {
+		return errors.Errorf("response already sent with status %d", rr.resp.Status)
+	}
+	rr.resp.Sent = true
+	rr.resp.Status = status
+	return nil
+}
+
+func (rr *testRequestResponse) Attachment(file, name, contentType string) error {
+	if err := rr.send(http.StatusOK); err != nil {
+		return err
+	}
+	rr.resp.Header.Set("Content-Type", contentType)
+	rr.resp.Attachment = &Attachment{
+		File:        file,
+		Name:        name,
+		ContentType: contentType,
+	}
+	return nil
+}
+
+func (rr *testRequestResponse) Redirect(status int, location string) error {
+	if err := rr.send(status); err != nil {
+		return err
+	}
+	rr.resp.Header.Set("Location", location)
+	return nil
+}
+
+func (rr *testRequestResponse) Body(status int, body interface{}) error {
+	if err := rr.send(status); err != nil {
+		return err
+	}
+	rr.resp.Body = body
+	return nil
+}
+
+func (rr *testRequestResponse) Blob(status int, contentType string, b []byte) error {
+	if err := rr.send(status); err != nil {
+		return err
+	}
+	rr.resp.Header.Set("Content-Type", contentType)
+	rr.resp.Body = b
+	return nil
+}
+
+func (rr *testRequestResponse) Stream(status int, contentType string, r io.Reader) error {
+	if err := rr.send(status); err != nil {
+		return err
+	}
+	rr.resp.Header.Set("Content-Type", contentType)
+	b, err := io.ReadAll(r)
+	rr.resp.Body = b
+	return errors.Wrap(err, "unable to read stream")
+}
+
+// Events records the events sent in Response.Events. The sink is closed when the handler returns.
+func (rr *testRequestResponse) Events(ctx context.Context) resttransport.EventSink {
+	s := &eventSink{
+		resp: rr.resp,
+		done: make(chan struct{}),
+	}
+	if err := rr.send(http.StatusOK); err != nil {
+		s.err = err
+	}
+	rr.resp.Header.Set("Content-Type", resttransport.EventStreamContentType)
+	rr.events = s
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+type eventSink struct {
+	mu     sync.Mutex
+	resp   *Response
+	err    error
+	done   chan struct{}
+	closed bool
+}
+
+func (s *eventSink) Send(e resttransport.Event) error {
+	if _, err := resttransport.FormatEvent(e); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return errors.New("event stream is closed")
+	}
+	s.resp.Events = append(s.resp.Events, e)
+	return nil
+}
+
+func (s *eventSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	return nil
+}
+
+func (s *eventSink) Done() <-chan struct{} {
+	return s.done
+}
+
+// ResponseSize returns the size of the body as sent by a transport encoding it as JSON, or of the
+// attachment or events.
+func (rr *testRequestResponse) ResponseSize() int64 {
+	switch {
+	case rr.resp.Attachment != nil:
+		fi, err := os.Stat(rr.resp.Attachment.File)
+		if err != nil {
+			return 0
+		}
+		return fi.Size()
+	case rr.resp.Events != nil:
+		var n int64
+		for _, e := range rr.resp.Events {
+			b, _ := resttransport.FormatEvent(e)
+			n += int64(len(b))
+		}
+		return n
+	}
+	switch b := rr.resp.Body.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return int64(len(b))
+	default:
+		enc, err := json.Marshal(b)
+		if err != nil {
+			return 0
+		}
+		return int64(len(enc))
+	}
+}
+
+func (rr *testRequestResponse) NoBody(status int) error {
+	return rr.send(status)
+}
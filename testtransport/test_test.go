@@ -0,0 +1,140 @@
+package testtransport_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+type foo struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required,max=10"`
+	Page int    `json:"page"`
+}
+
+func updateFoo(ctx context.Context, r resttransport.RequestResponse) error {
+	pathParams := struct {
+		ID string `path:"id"`
+	}{}
+	if err := r.BindPath(&pathParams); err != nil {
+		return err
+	}
+	queryParams := struct {
+		Page int `query:"page"`
+	}{}
+	if err := r.BindQuery(&queryParams); err != nil {
+		return err
+	}
+	body := foo{}
+	if err := r.BindBody(&body); err != nil {
+		return err
+	}
+	body.ID = pathParams.ID
+	body.Page = queryParams.Page
+	return r.Body(http.StatusOK, body)
+}
+
+func TestTransportDo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tt := testtransport.New()
+	require.NoError(tt.RegisterAuthenticatedHandler(http.MethodPut, "/foos/{id}", []string{"application/json"}, updateFoo))
+	require.Error(tt.RegisterHandler(http.MethodPut, "/foos/{id}", nil, updateFoo))
+
+	regs := tt.Registrations()
+	require.Len(regs, 1)
+	assert.True(regs[0].Authenticated)
+	assert.Equal([]string{"application/json"}, regs[0].Consumes)
+
+	resp, err := tt.Do(http.MethodPut, "/foos/{id}", &testtransport.Request{
+		Path:  map[string]string{"id": "123"},
+		Query: url.Values{"page": []string{"2"}},
+		Body:  map[string]string{"name": "bar"},
+		User:  "user",
+	})
+	require.NoError(err)
+	resp.Expect(t).
+		NoError().
+		Status(http.StatusOK).
+		Body(foo{ID: "123", Name: "bar", Page: 2})
+
+	resp, err = tt.Do(http.MethodPut, "/foos/{id}", &testtransport.Request{})
+	require.NoError(err)
+	resp.Expect(t).Status(http.StatusUnauthorized)
+
+	_, err = tt.Do(http.MethodGet, "/foos/{id}", nil)
+	assert.Error(err)
+}
+
+func TestTransportDo_Redirect(t *testing.T) {
+	require := require.New(t)
+
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodGet, "/old", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		if err := r.Redirect(http.StatusMovedPermanently, "/new"); err != nil {
+			return err
+		}
+		return r.NoBody(http.StatusOK)
+	}))
+
+	resp, err := tt.Do(http.MethodGet, "/old", nil)
+	require.NoError(err)
+	resp.Expect(t).
+		Error().
+		Status(http.StatusMovedPermanently).
+		Location("/new")
+}
+
+func TestTransportDo_Consumes(t *testing.T) {
+	require := require.New(t)
+
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodPut, "/foos/{id}", []string{"application/*"}, updateFoo))
+
+	resp, err := tt.Do(http.MethodPut, "/foos/{id}", &testtransport.Request{
+		Header: http.Header{"Content-Type": []string{"text/plain"}},
+		Body:   []byte(`{"name":"bar"}`),
+	})
+	require.NoError(err)
+	resp.Expect(t).Status(http.StatusUnsupportedMediaType)
+
+	resp, err = tt.Do(http.MethodPut, "/foos/{id}", &testtransport.Request{
+		Path:   map[string]string{"id": "1"},
+		Header: http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+		Body:   []byte(`{"name":"bar"}`),
+	})
+	require.NoError(err)
+	resp.Expect(t).NoError().Status(http.StatusOK)
+}
+
+func TestTransportDo_Validation(t *testing.T) {
+	require := require.New(t)
+
+	tt := testtransport.New()
+	require.NoError(tt.RegisterHandler(http.MethodPut, "/foos/{id}", nil, updateFoo))
+
+	resp, err := tt.Do(http.MethodPut, "/foos/{id}", &testtransport.Request{
+		Path: map[string]string{"id": "123"},
+		Body: map[string]string{"name": "far too long a name"},
+	})
+	require.NoError(err)
+	resp.Expect(t).
+		Error().
+		Status(http.StatusUnprocessableEntity).
+		Body(resttransport.Problem{
+			Title:  "Unprocessable Entity",
+			Status: http.StatusUnprocessableEntity,
+			Detail: "request validation failed",
+			Errors: []resttransport.FieldError{
+				{Field: "name", In: "body", Detail: "length must be at most 10"},
+			},
+		})
+}
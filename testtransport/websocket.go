@@ -0,0 +1,193 @@
+package testtransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/paultyng/resttransport"
+)
+
+// messageBuffer is the number of messages either side can send before the other reads them.
+const messageBuffer = 64
+
+// RegisterWebSocketHandler records an unauthenticated WebSocket handler, see WebSocket.
+func (t *Transport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return t.registerWebSocket(false, path, h, opts)
+}
+
+// RegisterAuthenticatedWebSocketHandler records an authenticated WebSocket handler, see WebSocket.
+func (t *Transport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return t.registerWebSocket(true, path, h, opts)
+}
+
+// registerWebSocket records a GET registration whose Handler answers requests that aren't upgraded.
+func (t *Transport) registerWebSocket(auth bool, path string, h resttransport.WebSocketHandler, opts []resttransport.RouteOption) error {
+	notUpgraded := func(ctx context.Context, r resttransport.RequestResponse) error {
+		return resttransport.NewError(http.StatusUpgradeRequired, "expected a WebSocket upgrade request")
+	}
+	if err := t.register(auth, http.MethodGet, path, nil, notUpgraded, opts); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.registrations[len(t.registrations)-1].WebSocketHandler = h
+	return nil
+}
+
+// WebSocket runs the WebSocket handler registered for a path (for example `/foos/{id}/events`)
+// with an in-memory connection, and returns the client side of it. Messages are marshaled to JSON.
+// Authenticated handlers, and handlers registered with security requirements, fail with a 401
+// Error if Request.User is nil.
+func (t *Transport) WebSocket(path string, req *Request) (*WebSocketClient, error) {
+	reg, ok := t.Lookup(http.MethodGet, path)
+	if !ok || reg.WebSocketHandler == nil {
+		return nil, errors.Errorf("no WebSocket handler registered for %s", path)
+	}
+	if req == nil {
+		req = &Request{}
+	}
+	if (reg.Authenticated || len(reg.Route.Security) > 0) && req.User == nil {
+		return nil, resttransport.NewError(http.StatusUnauthorized, "")
+	}
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if req.User != nil {
+		ctx = resttransport.WithPrincipal(ctx, req.User)
+	}
+
+	conn := &testWebSocketConn{
+		testRequestResponse: &testRequestResponse{
+			path: reg.Path,
+			req:  req,
+			resp: &Response{Header: http.Header{}},
+		},
+		toServer: make(chan []byte, messageBuffer),
+		toClient: make(chan []byte, messageBuffer),
+		closed:   make(chan struct{}),
+	}
+	client := &WebSocketClient{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(client.done)
+		client.err = reg.WebSocketHandler(ctx, conn)
+		conn.Close(resttransport.CloseStatus(client.err))
+	}()
+	return client, nil
+}
+
+// WebSocketClient is the client side of an in-memory WebSocket connection.
+type WebSocketClient struct {
+	conn *testWebSocketConn
+	done chan struct{}
+	err  error
+	once sync.Once
+}
+
+// Send marshals v to JSON and sends it to the handler.
+func (c *WebSocketClient) Send(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal message")
+	}
+	select {
+	case <-c.conn.closed:
+		return resttransport.ErrWebSocketClosed
+	case c.conn.toServer <- b:
+		return nil
+	}
+}
+
+// Receive unmarshals the next message sent by the handler into v, returning
+// resttransport.ErrWebSocketClosed once the handler has closed the connection.
+func (c *WebSocketClient) Receive(v interface{}) error {
+	b, ok := <-c.conn.toClient
+	if !ok {
+		return resttransport.ErrWebSocketClosed
+	}
+	return errors.Wrap(json.Unmarshal(b, v), "unable to unmarshal message")
+}
+
+// Close closes the connection from the client side, so the handler reads
+// resttransport.ErrWebSocketClosed. Send must not be called after Close.
+func (c *WebSocketClient) Close() {
+	c.once.Do(func() { close(c.conn.toServer) })
+}
+
+// Wait waits for the handler to return, and returns the close code and reason it closed the
+// connection with, and its error.
+func (c *WebSocketClient) Wait() (int, string, error) {
+	<-c.done
+	return c.conn.code, c.conn.reason, c.err
+}
+
+type testWebSocketConn struct {
+	*testRequestResponse
+	toServer chan []byte
+	toClient chan []byte
+	// mu guards writes to toClient against closing it
+	mu     sync.Mutex
+	closed chan struct{}
+	code   int
+	reason string
+}
+
+func (conn *testWebSocketConn) Read(v interface{}) error {
+	select {
+	case <-conn.closed:
+		return resttransport.ErrWebSocketClosed
+	case b, ok := <-conn.toServer:
+		if !ok {
+			return resttransport.ErrWebSocketClosed
+		}
+		if err := json.Unmarshal(b, v); err != nil {
+			return &resttransport.Error{
+				Status: http.StatusBadRequest,
+				Detail: "unable to decode message",
+				Err:    err,
+			}
+		}
+		return nil
+	}
+}
+
+func (conn *testWebSocketConn) Write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode message")
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	select {
+	case <-conn.closed:
+		return errors.New("websocket is closed")
+	default:
+	}
+	select {
+	case conn.toClient <- b:
+		return nil
+	default:
+		return errors.Errorf("more than %d messages not received by the client", messageBuffer)
+	}
+}
+
+func (conn *testWebSocketConn) Close(code int, reason string) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	select {
+	case <-conn.closed:
+	default:
+		conn.code = code
+		conn.reason = reason
+		close(conn.closed)
+		close(conn.toClient)
+	}
+	return nil
+}
@@ -1,60 +1,147 @@
+// Package tracetransport creates OpenTelemetry server spans for the handlers of a resttransport.
 package tracetransport
 
 import (
 	"context"
+	"net/http"
 
-	"cloud.google.com/go/trace"
-	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/internal/observe"
 	"github.com/paultyng/resttransport/routename"
 )
 
+const instrumentationName = "github.com/paultyng/resttransport/tracetransport"
+
+// propagator reads and writes W3C `traceparent` and `tracestate` headers.
+var propagator = propagation.TraceContext{}
+
 type tracingTransport struct {
 	inner  resttransport.Transport
 	namer  routename.Namer
-	tracer *trace.Client
+	tracer trace.Tracer
 }
 
-// New returns a new instance of a resttransport that implements opentracing.
-func New(tracer *trace.Client, inner resttransport.Transport) resttransport.Transport {
+// New returns a new instance of a resttransport that implements OpenTelemetry tracing. Incoming
+// W3C trace context headers are used as the parent of each server span.
+func New(tp trace.TracerProvider, inner resttransport.Transport) resttransport.Transport {
 	return &tracingTransport{
 		inner:  inner,
 		namer:  routename.New(),
-		tracer: tracer,
+		tracer: tp.Tracer(instrumentationName),
 	}
 }
 
-func (t *tracingTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
-	return t.inner.RegisterHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h))
+// Inject writes the W3C trace context of ctx to the headers of an outgoing request, so downstream
+// services continue the trace.
+func Inject(ctx context.Context, h http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(h))
 }
 
-func (t *tracingTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler) error {
-	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h))
+func (t *tracingTransport) RegisterHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
 }
 
-// https://github.com/GoogleCloudPlatform/google-cloud-go/blob/32a444f1bdd6d9313e6c82d90e66b599a2caa285/trace/trace.go#L171
-const (
-	httpHeader = `X-Cloud-Trace-Context`
-)
+func (t *tracingTransport) RegisterAuthenticatedHandler(httpMethod, path string, consumes []string, h resttransport.Handler, opts ...resttransport.RouteOption) error {
+	return t.inner.RegisterAuthenticatedHandler(httpMethod, path, consumes, t.wrapHandler(httpMethod, path, h), opts...)
+}
 
 func (t *tracingTransport) wrapHandler(httpMethod, path string, inner resttransport.Handler) resttransport.Handler {
+	spanName := t.namer.Name(httpMethod, path)
+
 	return func(ctx context.Context, reqres resttransport.RequestResponse) error {
-		spanName := t.namer.Name(httpMethod, path)
-		span := t.tracer.SpanFromHeader(spanName, reqres.RequestHeader().Get(httpHeader))
-		defer span.Finish()
+		ctx = propagator.Extract(ctx, propagation.HeaderCarrier(reqres.RequestHeader()))
+		ctx, span := t.tracer.Start(ctx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(httpMethod),
+				semconv.HTTPRoute(path),
+			),
+		)
+		rec := observe.New(reqres)
+		defer func() {
+			// event streams are closed by the transport after the handler returns
+			if events := rec.EventStream(); events != nil {
+				go func() {
+					<-events.Done()
+					span.End()
+				}()
+				return
+			}
+			span.End()
+		}()
 
-		span.SetLabel(trace.LabelHTTPMethod, httpMethod)
-		span.SetLabel(trace.LabelHTTPURL, path)
+		if ua := reqres.RequestHeader().Get("User-Agent"); ua != "" {
+			span.SetAttributes(semconv.UserAgentOriginal(ua))
+		}
 
-		ctx = trace.NewContext(ctx, span)
+		err := inner(ctx, rec)
 
-		err := inner(ctx, reqres)
+		status := rec.Status(err)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
 		if err != nil {
-			span.SetLabel("err", err.Error())
-			span.SetLabel("cause", errors.Cause(err).Error())
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+		} else if status >= http.StatusInternalServerError {
+			// per the semantic conventions, only server errors mark a written response as failed
+			span.SetStatus(codes.Error, http.StatusText(status))
 		}
 
 		return err
 	}
 }
+
+func errorType(err error) string {
+	if e, ok := resttransport.AsError(err); ok {
+		if e.Code != "" {
+			return e.Code
+		}
+	}
+	return semconv.ErrorTypeOther.Value.AsString()
+}
+
+// RegisterWebSocketHandler traces WebSocket connections, from the upgrade until the handler
+// returns, and registers the handler on the inner transport, which must implement
+// resttransport.WebSocketTransport.
+func (t *tracingTransport) RegisterWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterWebSocketHandler(t.inner, path, t.wrapWebSocketHandler(path, h), opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler traces authenticated WebSocket connections, see
+// RegisterWebSocketHandler.
+func (t *tracingTransport) RegisterAuthenticatedWebSocketHandler(path string, h resttransport.WebSocketHandler, opts ...resttransport.RouteOption) error {
+	return resttransport.RegisterAuthenticatedWebSocketHandler(t.inner, path, t.wrapWebSocketHandler(path, h), opts...)
+}
+
+func (t *tracingTransport) wrapWebSocketHandler(path string, inner resttransport.WebSocketHandler) resttransport.WebSocketHandler {
+	spanName := t.namer.Name(http.MethodGet, path)
+
+	return func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		ctx = propagator.Extract(ctx, propagation.HeaderCarrier(conn.RequestHeader()))
+		ctx, span := t.tracer.Start(ctx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(http.MethodGet),
+				semconv.HTTPRoute(path),
+				semconv.HTTPResponseStatusCode(http.StatusSwitchingProtocols),
+			),
+		)
+		defer span.End()
+
+		err := inner(ctx, conn)
+		code, _ := resttransport.CloseStatus(err)
+		span.SetAttributes(attribute.Int("websocket.close_code", code))
+		if code == resttransport.CloseInternalError {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "websocket handler failed")
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+		}
+		return err
+	}
+}
@@ -1 +1,166 @@
-package tracetransport
+package tracetransport_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+	"github.com/paultyng/resttransport/tracetransport"
+)
+
+func attributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTransport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	tt := testtransport.New()
+	tr := tracetransport.New(tp, tt)
+
+	var handlerSpan trace.SpanContext
+	require.NoError(tr.RegisterHandler(http.MethodPost, "/foos", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return r.Body(http.StatusCreated, map[string]string{"id": "1"})
+	}))
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/foos/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return errors.New("boom")
+	}))
+	require.NoError(tr.RegisterHandler(http.MethodDelete, "/foos/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return resttransport.NewError(http.StatusNotFound, "no such foo")
+	}))
+	require.NoError(tr.RegisterHandler(http.MethodPut, "/foos/{id}", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.NoBody(http.StatusConflict)
+	}))
+
+	_, err := tt.Do(http.MethodPost, "/foos", &testtransport.Request{
+		Header: http.Header{
+			"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		},
+	})
+	require.NoError(err)
+	for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodPut} {
+		_, err = tt.Do(method, "/foos/{id}", nil)
+		require.NoError(err)
+	}
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(spans, 4)
+
+	created := spans[0]
+	assert.Equal("createFoo", created.Name())
+	assert.Equal(trace.SpanKindServer, created.SpanKind())
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", created.SpanContext().TraceID().String())
+	assert.Equal("00f067aa0ba902b7", created.Parent().SpanID().String())
+	assert.True(created.Parent().IsRemote())
+	assert.Equal(created.SpanContext().SpanID(), handlerSpan.SpanID())
+	attrs := attributes(created)
+	assert.Equal("POST", attrs["http.request.method"].AsString())
+	assert.Equal("/foos", attrs["http.route"].AsString())
+	assert.Equal(int64(http.StatusCreated), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(codes.Unset, created.Status().Code)
+
+	failed := spans[1]
+	assert.Equal("getFoo", failed.Name())
+	assert.False(failed.Parent().IsValid())
+	assert.Equal(int64(http.StatusInternalServerError), attributes(failed)["http.response.status_code"].AsInt64())
+	assert.Equal(codes.Error, failed.Status().Code)
+	require.Len(failed.Events(), 1)
+	assert.Equal("exception", failed.Events()[0].Name)
+
+	// returned errors fail the span whatever their status, written client errors don't
+	notFound := spans[2]
+	assert.Equal(int64(http.StatusNotFound), attributes(notFound)["http.response.status_code"].AsInt64())
+	assert.Equal(codes.Error, notFound.Status().Code)
+	assert.Equal("404 Not Found: no such foo", notFound.Status().Description)
+	assert.Equal(codes.Unset, spans[3].Status().Code)
+}
+
+type eventsRequestResponse struct {
+	resttransport.RequestResponse
+	done chan struct{}
+}
+
+func (r *eventsRequestResponse) RequestHeader() http.Header                         { return http.Header{} }
+func (r *eventsRequestResponse) Events(ctx context.Context) resttransport.EventSink { return r }
+func (r *eventsRequestResponse) Send(resttransport.Event) error                     { return nil }
+func (r *eventsRequestResponse) Close() error                                       { close(r.done); return nil }
+func (r *eventsRequestResponse) Done() <-chan struct{}                              { return r.done }
+
+func TestEventStream(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	tt := testtransport.New()
+	tr := tracetransport.New(tp, tt)
+	require.NoError(tr.RegisterHandler(http.MethodGet, "/jobs/{id}/events", nil, func(ctx context.Context, r resttransport.RequestResponse) error {
+		return r.Events(ctx).Send(resttransport.Event{Data: "started"})
+	}))
+	reg, ok := tt.Lookup(http.MethodGet, "/jobs/{id}/events")
+	require.True(ok)
+
+	rr := &eventsRequestResponse{done: make(chan struct{})}
+	require.NoError(reg.Handler(context.Background(), rr))
+	// the span stays open until the transport closes the stream
+	assert.Empty(exporter.GetSpans())
+
+	require.NoError(rr.Close())
+	require.Eventually(func() bool { return len(exporter.GetSpans()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(int64(http.StatusOK), attributes(exporter.GetSpans().Snapshots()[0])["http.response.status_code"].AsInt64())
+}
+
+func TestWebSocket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	tt := testtransport.New()
+	tr := tracetransport.New(tp, tt)
+	require.NoError(resttransport.RegisterWebSocketHandler(tr, "/rooms/{id}", func(ctx context.Context, conn resttransport.WebSocketConn) error {
+		var m string
+		if err := conn.Read(&m); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}))
+
+	client, err := tt.WebSocket("/rooms/{id}", &testtransport.Request{Path: map[string]string{"id": "1"}})
+	require.NoError(err)
+	require.NoError(client.Send("hi"))
+	code, _, err := client.Wait()
+	assert.Error(err)
+	assert.Equal(resttransport.CloseInternalError, code)
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(spans, 1)
+	assert.Equal("getRoom", spans[0].Name())
+	attrs := attributes(spans[0])
+	assert.Equal(int64(http.StatusSwitchingProtocols), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(int64(resttransport.CloseInternalError), attrs["websocket.close_code"].AsInt64())
+	assert.Equal(codes.Error, spans[0].Status().Code)
+}
@@ -0,0 +1,128 @@
+package validate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TagName is the struct tag holding validation rules.
+const TagName = "validate"
+
+// Rule names.
+const (
+	Required  = "required"
+	OmitEmpty = "omitempty"
+	Min       = "min"
+	Max       = "max"
+	Len       = "len"
+	Pattern   = "pattern"
+	Enum      = "enum"
+	Email     = "email"
+	UUID      = "uuid"
+	Dive      = "dive"
+)
+
+// Rule is a single parsed validation rule, for example `min=1`.
+type Rule struct {
+	Name  string
+	Param string
+}
+
+// Number returns the rule parameter as a number (for min, max and len).
+func (r Rule) Number() (float64, error) {
+	n, err := strconv.ParseFloat(r.Param, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s parameter '%s'", r.Name, r.Param)
+	}
+	return n, nil
+}
+
+// Values returns the `|` separated values of an enum rule.
+func (r Rule) Values() []string {
+	return strings.Split(r.Param, "|")
+}
+
+// Rules is the set of rules for a field. Rules after a `dive` apply to the elements of a slice,
+// array or map, and are held in Elem.
+type Rules struct {
+	Rules []Rule
+	Elem  *Rules
+}
+
+// Has returns the rule with the given name, if present.
+func (rs *Rules) Has(name string) (Rule, bool) {
+	if rs == nil {
+		return Rule{}, false
+	}
+	for _, r := range rs.Rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// ParseTag parses a `validate` struct tag. Rules are comma separated, and since patterns may
+// contain commas, a `pattern` rule consumes the remainder of the tag (or of the current dive level
+// if it is followed by `,dive`, which can not appear in the pattern itself). Unknown rules are
+// skipped, see Validator.ParseTag to reject them.
+func ParseTag(tag string) (*Rules, error) {
+	return Validator{}.ParseTag(tag)
+}
+
+// ParseTag parses a `validate` struct tag like the ParseTag function, failing on unknown rules if
+// v is Strict.
+func (v Validator) ParseTag(tag string) (*Rules, error) {
+	rules := &Rules{}
+	current := rules
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, Pattern+"=") {
+			part, tag = tag, ""
+			if i := strings.Index(part, ","+Dive); i >= 0 {
+				part, tag = part[:i], part[i+1:]
+			}
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+
+		name, param := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			name, param = part[:i], part[i+1:]
+		}
+
+		switch name {
+		case "":
+			continue
+		case Dive:
+			current.Elem = &Rules{}
+			current = current.Elem
+			continue
+		case Min, Max, Len:
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return nil, errors.Errorf("invalid %s parameter '%s'", name, param)
+			}
+		case Pattern:
+			if _, err := regexp.Compile(param); err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern '%s'", param)
+			}
+		case Enum:
+			if param == "" {
+				return nil, errors.New("enum requires values")
+			}
+		case Required, OmitEmpty, Email, UUID:
+		default:
+			if v.Strict {
+				return nil, errors.Errorf("unknown validation rule '%s'", name)
+			}
+			continue
+		}
+		current.Rules = append(current.Rules, Rule{Name: name, Param: param})
+	}
+	return rules, nil
+}
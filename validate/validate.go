@@ -0,0 +1,331 @@
+// Package validate checks structs against `validate` struct tags. The same tags are documented as
+// JSON Schema keywords by doctransport.
+//
+// Supported rules are `required`, `omitempty`, `min=N`, `max=N`, `len=N`, `pattern=REGEX`,
+// `enum=a|b|c`, `email`, `uuid` and `dive`. Other rules are ignored, unless validating with a
+// Strict Validator. For numbers min and max bound the value, for strings, slices and maps they bound
+// the length. Rules following `dive` apply to each element of a slice, array or map, and nested
+// structs are always validated.
+//
+// Nil pointers are only checked by `required`, so optional fields should be pointers. Other values
+// are checked as they are, including empty strings, matching the JSON Schema doctransport generates,
+// where non-pointer fields are required. `omitempty` skips the other rules for a zero value, such as
+// an omitted query parameter, and such fields are documented as optional:
+//
+//	type Order struct {
+//		ID     string   `json:"id" validate:"required,uuid"`
+//		Items  []Item   `json:"items" validate:"min=1,dive"`
+//		Tags   []string `json:"tags" validate:"max=5,dive,pattern=^[a-z]+$"`
+//		Coupon string   `json:"coupon" validate:"omitempty,len=8"`
+//	}
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	patternCache sync.Map
+)
+
+// FieldError is a single validation failure.
+type FieldError struct {
+	// Path is the path to the field using names from the name tag, for example `items[0].name`.
+	Path   string
+	Rule   string
+	Param  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Path, e.Reason)
+}
+
+// Errors is the list of validation failures for a value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+// Validator validates structs. The zero value ignores unknown rules, so structs tagged for other
+// validators sharing the `validate` tag still bind.
+type Validator struct {
+	// Strict fails on rules the package doesn't know, for example in tests to catch typos in tags.
+	Strict bool
+}
+
+// Struct validates v, a struct or pointer to a struct, with the zero Validator. Other values, such
+// as maps or slices decoded from a body, have no tags to check and are valid. Field paths use the
+// names from nameTag (for example `json` or `query`), falling back to the Go field name. The
+// returned error is an Errors if validation fails.
+func Struct(v interface{}, nameTag string) error {
+	return Validator{}.Struct(v, nameTag)
+}
+
+// Struct validates x like the Struct function, with the rules parsed by v.
+func (v Validator) Struct(x interface{}, nameTag string) error {
+	rv := reflect.ValueOf(x)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	w := &walker{validator: v, nameTag: nameTag}
+	if err := w.walkStruct("", rv); err != nil {
+		return err
+	}
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+type walker struct {
+	validator Validator
+	nameTag   string
+	errs      Errors
+}
+
+func (w *walker) fail(path string, r Rule, reason string, args ...interface{}) {
+	w.errs = append(w.errs, FieldError{
+		Path:   path,
+		Rule:   r.Name,
+		Param:  r.Param,
+		Reason: fmt.Sprintf(reason, args...),
+	})
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func (w *walker) fieldName(f reflect.StructField) string {
+	name := f.Tag.Get(w.nameTag)
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func (w *walker) walkStruct(path string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+
+		if f.Anonymous && f.Tag.Get(TagName) == "" {
+			// embedded structs are flattened like JSON marshaling
+			if err := w.walkNested(path, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rules, err := w.validator.ParseTag(f.Tag.Get(TagName))
+		if err != nil {
+			return fmt.Errorf("validate: field %s of %s: %v", f.Name, t, err)
+		}
+		if err := w.walkValue(join(path, w.fieldName(f)), fv, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkNested validates nested structs (through pointers) that have no rules of their own.
+func (w *walker) walkNested(path string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		return w.walkStruct(path, v)
+	}
+	return nil
+}
+
+func (w *walker) walkValue(path string, v reflect.Value, rules *Rules) error {
+	if r, ok := rules.Has(Required); ok && isZero(v) {
+		w.fail(path, r, "is required")
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			// optional and missing, nothing else to check
+			return nil
+		}
+		v = v.Elem()
+	}
+	if _, ok := rules.Has(OmitEmpty); ok && isZero(v) {
+		return nil
+	}
+
+	for _, r := range rules.Rules {
+		if err := w.check(path, v, r); err != nil {
+			return err
+		}
+	}
+
+	if v.Kind() == reflect.Struct {
+		if err := w.walkStruct(path, v); err != nil {
+			return err
+		}
+	}
+
+	if rules.Elem == nil {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.walkValue(fmt.Sprintf("%s[%d]", path, i), v.Index(i), rules.Elem); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		// sorted so failures are reported in a stable order
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := w.walkValue(fmt.Sprintf("%s[%v]", path, k.Interface()), v.MapIndex(k), rules.Elem); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("validate: dive on %s of kind %s", path, v.Kind())
+	}
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.IsNil() || v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// size returns the value compared by min, max and len: the number for numeric kinds, otherwise the
+// length.
+func size(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), false, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), false, true
+	}
+	return 0, false, false
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// nolint: gocyclo
+func (w *walker) check(path string, v reflect.Value, r Rule) error {
+	switch r.Name {
+	case Min, Max, Len:
+		n, err := r.Number()
+		if err != nil {
+			return err
+		}
+		actual, numeric, ok := size(v)
+		if !ok {
+			return fmt.Errorf("validate: %s does not apply to %s of kind %s", r.Name, path, v.Kind())
+		}
+		what := "length"
+		if numeric {
+			what = "value"
+		}
+		switch {
+		case r.Name == Min && actual < n:
+			w.fail(path, r, "%s must be at least %s", what, r.Param)
+		case r.Name == Max && actual > n:
+			w.fail(path, r, "%s must be at most %s", what, r.Param)
+		case r.Name == Len && actual != n:
+			w.fail(path, r, "%s must be exactly %s", what, r.Param)
+		}
+	case Pattern, Email, UUID:
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("validate: %s does not apply to %s of kind %s", r.Name, path, v.Kind())
+		}
+		s := v.String()
+		switch r.Name {
+		case Pattern:
+			re, err := compile(r.Param)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(s) {
+				w.fail(path, r, "must match pattern %s", r.Param)
+			}
+		case Email:
+			if !emailRegex.MatchString(s) {
+				w.fail(path, r, "must be an email address")
+			}
+		case UUID:
+			if !uuidRegex.MatchString(s) {
+				w.fail(path, r, "must be a UUID")
+			}
+		}
+	case Enum:
+		s := fmt.Sprint(v.Interface())
+		for _, e := range r.Values() {
+			if s == e {
+				return nil
+			}
+		}
+		w.fail(path, r, "must be one of %s", strings.Join(r.Values(), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type order struct {
+	ID       string            `json:"id" validate:"required,uuid"`
+	Email    string            `json:"email" validate:"email"`
+	Quantity int               `json:"quantity" validate:"min=1,max=10"`
+	Status   string            `json:"status" validate:"enum=open|closed"`
+	Code     *string           `json:"code" validate:"len=3"`
+	Items    []item            `json:"items" validate:"min=1,dive"`
+	Tags     []string          `json:"tags" validate:"dive,pattern=^[a-z]{1,3}$"`
+	Labels   map[string]string `json:"labels" validate:"dive,required"`
+	Nested   *item             `json:"nested"`
+}
+
+func TestStruct(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	valid := order{
+		ID:       "0a0ecf4c-0c5c-4a1e-8e23-4bb4a4aa1b43",
+		Email:    "someone@example.com",
+		Quantity: 3,
+		Status:   "open",
+		Items:    []item{{Name: "a"}},
+		Tags:     []string{"abc"},
+		Labels:   map[string]string{"k": "v"},
+	}
+	assert.NoError(Struct(&valid, "json"))
+
+	code := "toolong"
+	err := Struct(order{
+		ID:       "nope",
+		Email:    "nope",
+		Quantity: 11,
+		Status:   "pending",
+		Code:     &code,
+		Items:    []item{{Name: ""}, {Name: "toolong"}},
+		Tags:     []string{"abcd"},
+		Labels:   map[string]string{"b": "", "a": ""},
+		Nested:   &item{},
+	}, "json")
+	require.Error(err)
+
+	verrs, ok := err.(Errors)
+	require.True(ok)
+
+	paths := []string{}
+	for _, fe := range verrs {
+		paths = append(paths, fe.Path+" "+fe.Rule)
+	}
+	assert.Equal([]string{
+		"id uuid",
+		"email email",
+		"quantity max",
+		"status enum",
+		"code len",
+		"items[0].name required",
+		"items[1].name max",
+		"tags[0] pattern",
+		"labels[a] required",
+		"labels[b] required",
+		"nested.name required",
+	}, paths)
+	assert.Equal("value must be at most 10", verrs[2].Reason)
+	assert.Equal("length must be exactly 3", verrs[4].Reason)
+
+	err = Struct(order{}, "json")
+	require.Error(err)
+	verrs = err.(Errors)
+	assert.Equal("id", verrs[0].Path)
+	assert.Equal("items", verrs[len(verrs)-1].Path)
+}
+
+func TestStruct_EmptyValues(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	empty := ""
+	err := Struct(struct {
+		Name   string  `json:"name" validate:"min=1"`
+		Code   *string `json:"code" validate:"pattern=^[A-Z]+$"`
+		Coupon string  `json:"coupon" validate:"omitempty,len=8"`
+		Page   int     `json:"page" validate:"omitempty,min=1"`
+		Absent *string `json:"absent" validate:"min=1"`
+	}{Code: &empty}, "json")
+	require.Error(err)
+
+	verrs, ok := err.(Errors)
+	require.True(ok)
+	paths := []string{}
+	for _, fe := range verrs {
+		paths = append(paths, fe.Path+" "+fe.Rule)
+	}
+	// empty strings are checked, as they are in the documented JSON Schema, unless omitempty
+	assert.Equal([]string{"name min", "code pattern"}, paths)
+
+	assert.NoError(Struct(map[string]string{}, "json"))
+}
+
+func TestStruct_UnknownRules(t *testing.T) {
+	// rules of other validators sharing the tag are ignored
+	v := struct {
+		Age  int    `json:"age" validate:"gte=0,min=1"`
+		Kind string `json:"kind" validate:"oneof=a b"`
+	}{}
+	err := Struct(v, "json")
+	require.Error(t, err)
+	verrs, ok := err.(Errors)
+	require.True(t, ok)
+	assert.Len(t, verrs, 1)
+	assert.Equal(t, "age", verrs[0].Path)
+
+	err = Validator{Strict: true}.Struct(v, "json")
+	require.Error(t, err)
+	_, ok = err.(Errors)
+	assert.False(t, ok)
+}
+
+func TestParseTag(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rules, err := ParseTag("min=1,max=3,dive,required,pattern=^a{1,2}$")
+	require.NoError(err)
+	assert.Equal([]Rule{{Name: Min, Param: "1"}, {Name: Max, Param: "3"}}, rules.Rules)
+	require.NotNil(rules.Elem)
+	assert.Equal([]Rule{{Name: Required}, {Name: Pattern, Param: "^a{1,2}$"}}, rules.Elem.Rules)
+
+	rules, err = ParseTag("omitempty,gte=0")
+	require.NoError(err)
+	assert.Equal([]Rule{{Name: OmitEmpty}}, rules.Rules)
+	_, err = Validator{Strict: true}.ParseTag("omitempty,gte=0")
+	assert.Error(err)
+
+	_, err = ParseTag("min=x")
+	assert.Error(err)
+	_, err = ParseTag("pattern=[")
+	assert.Error(err)
+}
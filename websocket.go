@@ -0,0 +1,129 @@
+package resttransport
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// WebSocket close codes, see RFC 6455 section 7.4.1.
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	ClosePolicy        = 1008
+	CloseInternalError = 1011
+)
+
+// ErrWebSocketClosed is returned by WebSocketConn.Read once the connection is gone: the peer closed
+// it, with any close code, dropped it or stopped responding. Handlers can return it as is, or
+// wrapped.
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// WebSocketConn is an upgraded WebSocket connection. Messages are encoded by the transport, as JSON
+// text messages unless configured otherwise.
+type WebSocketConn interface {
+	RequestHeader() http.Header
+	RemoteAddr() string
+	// URL returns the URL of the upgrade request, with the path parameter values in its path.
+	URL() *url.URL
+	// BindQuery binds a struct to query string variables extracted from the requested URL.
+	BindQuery(interface{}) error
+	// BindPath binds a struct to path variables extracted from the requested URL.
+	BindPath(interface{}) error
+	// User returns current user state/context, as for RequestResponse.User.
+	User() interface{}
+
+	// Read reads the next message into v, returning ErrWebSocketClosed once the connection is gone.
+	Read(v interface{}) error
+	// Write sends v as a message. It is safe to call concurrently with Read, but not with itself.
+	Write(v interface{}) error
+	// Close sends a close message with a close code and reason, then closes the connection.
+	Close(code int, reason string) error
+}
+
+// WebSocketHandler handles a WebSocket connection until it returns. The connection is closed when
+// it returns: normally if it returns nil or an error wrapping ErrWebSocketClosed, with code
+// `4000 + status` for an Error with a 4xx status, and CloseInternalError otherwise.
+type WebSocketHandler func(context.Context, WebSocketConn) error
+
+// WebSocketTransport is implemented by Transports that can upgrade GET requests to WebSocket
+// connections. Path parameters follow the form of Transport, and authenticated handlers are
+// authenticated before the upgrade, the same way as with RegisterAuthenticatedHandler.
+type WebSocketTransport interface {
+	RegisterWebSocketHandler(path string, h WebSocketHandler, opts ...RouteOption) error
+	RegisterAuthenticatedWebSocketHandler(path string, h WebSocketHandler, opts ...RouteOption) error
+}
+
+// RegisterWebSocketHandler registers a WebSocket handler on t, if it implements
+// WebSocketTransport.
+func RegisterWebSocketHandler(t Transport, path string, h WebSocketHandler, opts ...RouteOption) error {
+	wt, ok := t.(WebSocketTransport)
+	if !ok {
+		return errors.Errorf("transport %T does not support WebSocket", t)
+	}
+	return wt.RegisterWebSocketHandler(path, h, opts...)
+}
+
+// RegisterAuthenticatedWebSocketHandler registers an authenticated WebSocket handler on t, if it
+// implements WebSocketTransport.
+func RegisterAuthenticatedWebSocketHandler(t Transport, path string, h WebSocketHandler, opts ...RouteOption) error {
+	wt, ok := t.(WebSocketTransport)
+	if !ok {
+		return errors.Errorf("transport %T does not support WebSocket", t)
+	}
+	return wt.RegisterAuthenticatedWebSocketHandler(path, h, opts...)
+}
+
+// CloseStatus returns the close code and reason a WebSocket connection is closed with when its
+// handler returns err, see WebSocketHandler.
+func CloseStatus(err error) (int, string) {
+	if err == nil || isCause(err, ErrWebSocketClosed) {
+		return CloseNormal, ""
+	}
+	if e, ok := AsError(err); ok && e.Status >= 400 && e.Status < 500 {
+		p := NewProblem(err)
+		reason := p.Detail
+		if reason == "" {
+			reason = p.Title
+		}
+		return 4000 + e.Status, TruncateCloseReason(reason)
+	}
+	return CloseInternalError, http.StatusText(http.StatusInternalServerError)
+}
+
+// maxCloseReasonLength is the longest reason a close frame holds, as frames are limited to 125
+// bytes including the code.
+const maxCloseReasonLength = 123
+
+// TruncateCloseReason shortens a close reason to fit in a close frame, without splitting a UTF-8
+// encoded rune.
+func TruncateCloseReason(reason string) string {
+	if len(reason) <= maxCloseReasonLength {
+		return reason
+	}
+	n := maxCloseReasonLength
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
+
+// isCause reports whether err is target or wraps it, following both Unwrap (see errors.Is) and the
+// Cause of github.com/pkg/errors wrappers.
+func isCause(err, target error) bool {
+	for err != nil {
+		if stderrors.Is(err, target) {
+			return true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package resttransport_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paultyng/resttransport"
+	"github.com/paultyng/resttransport/testtransport"
+)
+
+func TestCloseStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	code, reason := resttransport.CloseStatus(nil)
+	assert.Equal(resttransport.CloseNormal, code)
+	assert.Empty(reason)
+	code, _ = resttransport.CloseStatus(resttransport.ErrWebSocketClosed)
+	assert.Equal(resttransport.CloseNormal, code)
+	code, _ = resttransport.CloseStatus(errors.Wrap(resttransport.ErrWebSocketClosed, "unable to read"))
+	assert.Equal(resttransport.CloseNormal, code)
+	code, _ = resttransport.CloseStatus(fmt.Errorf("join: %w", resttransport.ErrWebSocketClosed))
+	assert.Equal(resttransport.CloseNormal, code)
+
+	code, reason = resttransport.CloseStatus(errors.Wrap(resttransport.NewError(http.StatusForbidden, "not a member"), "join"))
+	assert.Equal(4403, code)
+	assert.Equal("not a member", reason)
+	code, reason = resttransport.CloseStatus(resttransport.NewError(http.StatusNotFound, strings.Repeat("x", 200)))
+	assert.Equal(4404, code)
+	assert.Len(reason, 123)
+	code, reason = resttransport.CloseStatus(resttransport.NewError(http.StatusNotFound, strings.Repeat("é", 100)))
+	assert.Equal(4404, code)
+	assert.Len(reason, 122)
+	assert.True(utf8.ValidString(reason))
+
+	code, reason = resttransport.CloseStatus(errors.New("database is down"))
+	assert.Equal(resttransport.CloseInternalError, code)
+	assert.NotContains(reason, "database")
+}
+
+func TestRegisterWebSocketHandler(t *testing.T) {
+	assert.Error(t, resttransport.RegisterWebSocketHandler(struct{ resttransport.Transport }{testtransport.New()}, "/rooms", nil))
+	assert.NoError(t, resttransport.RegisterWebSocketHandler(testtransport.New(), "/rooms", nil))
+}